@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"subscriptions/internal/config"
+	"subscriptions/internal/notify"
+	"subscriptions/internal/notify/smtpstub"
+	"subscriptions/internal/outbox"
+	"subscriptions/internal/reminders"
+	"subscriptions/internal/webhooks"
+)
+
+// Запускает планировщик напоминаний. Возвращаемая функция ждёт завершения планировщика
+// после отмены ctx и останавливает заглушку SMTP, если она была поднята
+
+func startReminders(ctx context.Context, cfg config.RemindersConfig, store reminders.Store) (func(), error) {
+	if !cfg.Enabled {
+		return func() {}, nil
+	}
+
+	var stub *smtpstub.Server
+	var notifier reminders.Notifier
+
+	switch cfg.Notifier {
+	case "webhook":
+		notifier = notify.NewWebhook(cfg.Webhook.URL, cfg.Webhook.Timeout)
+	case "smtp":
+		if cfg.SMTP.Stub {
+			var err error
+			if stub, err = smtpstub.Start(cfg.SMTP.Addr); err != nil {
+				return nil, err
+			}
+			slog.Info("smtp stub listening", "addr", stub.Addr())
+		}
+		notifier = notify.NewSMTP(cfg.SMTP)
+	default:
+		notifier = notify.NewLog(slog.Default())
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		reminders.NewScheduler(store, notifier, cfg).Run(ctx)
+	}()
+
+	slog.Info("reminders scheduler started", "notifier", cfg.Notifier, "interval", cfg.Interval, "window", cfg.Window)
+
+	return func() {
+		<-done
+		if stub != nil {
+			stub.Close()
+		}
+	}, nil
+}
+
+// Запускает отправку событий на вебхуки, возвращаемая функция ждёт её завершения после отмены ctx
+
+func startWebhooks(ctx context.Context, cfg config.WebhooksConfig, store webhooks.Store) func() {
+	if !cfg.Enabled {
+		return func() {}
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		webhooks.NewDispatcher(store, cfg).Run(ctx)
+	}()
+
+	slog.Info("webhooks dispatcher started", "poll_interval", cfg.PollInterval, "max_attempts", cfg.MaxAttempts)
+
+	return func() { <-done }
+}
+
+// Запускает публикацию событий outbox в брокер. Возвращаемая функция ждёт завершения после отмены ctx
+// и закрывает соединение с брокером
+
+func startEvents(ctx context.Context, cfg config.EventsConfig, store outbox.Store) (func(), error) {
+	if !cfg.Enabled {
+		return func() {}, nil
+	}
+
+	var pub outbox.Publisher
+	closePub := func() {}
+
+	switch cfg.Publisher {
+	case "memory":
+		pub = outbox.NewMemory()
+	default:
+		n, err := outbox.NewNATS(cfg.NATSURL)
+		if err != nil {
+			return nil, err
+		}
+		pub = n
+		closePub = func() {
+			if err := n.Close(); err != nil {
+				slog.Error("error during nats connection close", "error", err)
+			}
+		}
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		outbox.NewRelay(store, pub, cfg).Run(ctx)
+	}()
+
+	slog.Info("events relay started", "publisher", cfg.Publisher, "subject_prefix", cfg.SubjectPrefix)
+
+	return func() {
+		<-done
+		closePub()
+	}, nil
+}
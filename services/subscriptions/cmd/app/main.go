@@ -6,45 +6,187 @@
 package main
 
 import (
-	"log"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
 	"net/http"
 	"os"
+	"os/signal"
+	"subscriptions/internal/cache"
+	"subscriptions/internal/config"
+	_ "subscriptions/internal/docs"
 	"subscriptions/internal/handlers"
+	"subscriptions/internal/health"
+	"subscriptions/internal/logger"
+	"subscriptions/internal/metrics"
+	"subscriptions/internal/middleware"
+	"subscriptions/internal/ratelimit"
 	"subscriptions/internal/router"
 	"subscriptions/internal/service"
-	"subscriptions/internal/storage"
-	_ "subscriptions/internal/docs"
+	"subscriptions/internal/tracing"
+	"subscriptions/internal/webhooks"
+	"syscall"
+	"time"
+
 	httpSwagger "github.com/swaggo/http-swagger"
 )
 
 func main() {
-	
-	ports := os.Getenv("LISTEN_AND_SERVE_PORTS")
+	cfg, err := config.Load()
+	if err != nil {
+		slog.Error("error during config loading", "error", err)
+		os.Exit(1)
+	}
+
+	slog.SetDefault(logger.New(cfg.Log.Level))
 
-	storage := storage.NewStorage()
+	slog.Info("effective config", "config", cfg)
+
+	// Без аргументов запускается HTTP сервер, как и раньше
+
+	args := os.Args[1:]
+	if len(args) == 0 {
+		args = []string{"serve"}
+	}
+
+	switch args[0] {
+	case "serve":
+		serve(cfg)
+	case "migrate":
+		if err := runMigrate(cfg, args[1:]); err != nil {
+			slog.Error("migrate failed", "error", err)
+			os.Exit(1)
+		}
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n%s", args[0], usage)
+		os.Exit(2)
+	}
+}
 
-	log.Print("connected to db")
+const usage = `Usage:
+  app [serve]                 start HTTP server
+  app migrate up [N]          apply all or N up migrations
+  app migrate down [N]        roll back N migrations (1 by default)
+  app migrate version         print current migration version
+  app migrate force VERSION   set version without running migrations, clears dirty state
+`
 
-	storage.RunMigrations()
+func serve(cfg config.Config) {
+	shutdownTracing, err := tracing.Init(context.Background(), cfg.Tracing.Exporter)
+	if err != nil {
+		slog.Error("error during tracing initialization", "error", err)
+		os.Exit(1)
+	}
+	defer shutdownTracing(context.Background())
 
-	log.Print("migrations accepted")
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	st, err := openStorage(ctx, cfg.Database)
+	if err != nil {
+		slog.Error("error during storage initialization", "error", err)
+		os.Exit(1)
+	}
+
+	slog.Info("storage initialized", "driver", cfg.Database.Backend())
+
+	m := metrics.NewMetrics()
+	if i, ok := st.(instrumented); ok {
+		i.RegisterMetrics(m)
+	}
+
+	mig, hasMigrations := st.(migrator)
+
+	if cfg.Database.AutoMigrate && hasMigrations {
+		if err := mig.MigrateUp(0); err != nil {
+			slog.Error("error during migrations", "error", err)
+			os.Exit(1)
+		}
+
+		slog.Info("migrations accepted")
+	}
 
 	mux := http.NewServeMux()
 
-	s := service.NewService(storage)
+	var s service.Service = service.NewService(st)
+
+	// Кэш встаёт между пулом воркеров и сервисом, поэтому записи через пул сразу сбрасывают кэш
+	if cfg.Cache.Size > 0 {
+		s = service.NewCachedService(s, cache.NewLRU(cfg.Cache.Size), cfg.Cache.TTL, m)
+	}
 
-	w := service.StartWorkerPool(6, s)
+	w := service.StartWorkerPool(cfg.Workers.Count, cfg.Workers.QueueSize, s, m)
 
-	h := handlers.NewHandler(w)
+	stopReminders, err := startReminders(ctx, cfg.Reminders, st)
+	if err != nil {
+		slog.Error("error during reminders initialization", "error", err)
+		os.Exit(1)
+	}
 
-	router := router.NewRouter(h)
+	stopWebhooks := startWebhooks(ctx, cfg.Webhooks, st)
+
+	stopEvents, err := startEvents(ctx, cfg.Events, st)
+	if err != nil {
+		slog.Error("error during events relay initialization", "error", err)
+		os.Exit(1)
+	}
+
+	router := router.NewRouter(handlers.NewHandler(w), handlers.NewHandlerV2(w, webhooks.NewGuard(cfg.Webhooks)))
 	router.InitRoutes(mux)
 	mux.Handle("/swagger/", httpSwagger.WrapHandler)
-	wrapped := router.WrapMiddle(mux)
+	mux.Handle("/metrics", m.Handler())
 
-	log.Printf("listening on %v", ports)
-	if err := http.ListenAndServe(ports, wrapped); err != nil {
-    log.Fatalf("HTTP server failed: %v", err)
-}
+	checker := health.NewChecker(cfg.Server.HealthCheckTimeout)
+	checker.AddReadinessCheck("database", st.Ping)
+	if hasMigrations {
+		checker.AddReadinessCheck("migrations", mig.CheckMigrations)
+	}
+	checker.AddReadinessCheck("worker_pool", w.CheckSaturation)
+	mux.HandleFunc("/healthz", checker.Liveness)
+	mux.HandleFunc("/readyz", checker.Readiness)
+
+	var limiter *middleware.RateLimiter
+	if cfg.RateLimit.Enabled {
+		limiter = middleware.NewRateLimiter(ratelimit.NewMemory(), cfg.RateLimit)
+	}
+
+	wrapped := router.WrapMiddle(mux, m, limiter, middleware.NewCORS(cfg.CORS), middleware.NewAdminAuth(cfg.Admin.Token))
+
+	srv := &http.Server{Addr: cfg.Server.Addr, Handler: wrapped}
+
+	go func() {
+		slog.Info("listening", "addr", cfg.Server.Addr)
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			slog.Error("HTTP server failed", "error", err)
+			os.Exit(1)
+		}
+	}()
+
+	<-ctx.Done()
+
+	slog.Info("shutting down")
+
+	// Сначала отдаём 503 на /readyz, чтобы балансировщик успел убрать инстанс, и только потом закрываем соединения
+	checker.SetShuttingDown()
+	time.Sleep(cfg.Server.ReadinessDrainTime)
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
+	defer cancel()
+
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		slog.Error("error during HTTP server shutdown", "error", err)
+	}
+
+	w.Stop()
+
+	stopReminders()
+	stopWebhooks()
+	stopEvents()
+
+	if err := st.Close(); err != nil {
+		slog.Error("error during database close", "error", err)
+	}
 
+	slog.Info("server stopped")
 }
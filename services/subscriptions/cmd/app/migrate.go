@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"subscriptions/internal/config"
+)
+
+// Подкоманды migrate позволяют менять схему отдельно от запуска сервиса
+
+func runMigrate(cfg config.Config, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("migrate: subcommand is required\n\n%s", usage)
+	}
+
+	backend, err := openStorage(context.Background(), cfg.Database)
+	if err != nil {
+		return err
+	}
+	defer backend.Close()
+
+	st, ok := backend.(migrator)
+	if !ok {
+		return fmt.Errorf("migrate: storage driver %q has no migrations", cfg.Database.Backend())
+	}
+
+	switch args[0] {
+	case "up":
+		steps, err := optionalSteps(args[1:], 0)
+		if err != nil {
+			return err
+		}
+		if err := st.MigrateUp(steps); err != nil {
+			return err
+		}
+	case "down":
+		steps, err := optionalSteps(args[1:], 1)
+		if err != nil {
+			return err
+		}
+		if err := st.MigrateDown(steps); err != nil {
+			return err
+		}
+	case "version":
+	case "force":
+		if len(args) != 2 {
+			return fmt.Errorf("migrate force: VERSION is required")
+		}
+		version, err := strconv.Atoi(args[1])
+		if err != nil {
+			return fmt.Errorf("migrate force: invalid version %q", args[1])
+		}
+		if err := st.ForceMigration(version); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("migrate: unknown subcommand %q\n\n%s", args[0], usage)
+	}
+
+	version, dirty, err := st.MigrationVersion()
+	if err != nil {
+		return err
+	}
+
+	if dirty {
+		fmt.Printf("%d (dirty)\n", version)
+	} else {
+		fmt.Printf("%d\n", version)
+	}
+
+	return nil
+}
+
+func optionalSteps(args []string, def int) (int, error) {
+	if len(args) == 0 {
+		return def, nil
+	}
+
+	steps, err := strconv.Atoi(args[0])
+	if err != nil || steps <= 0 {
+		return 0, fmt.Errorf("migrate: N must be a positive number, got %q", args[0])
+	}
+
+	return steps, nil
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"context"
+	"subscriptions/internal/config"
+	"subscriptions/internal/metrics"
+	"subscriptions/internal/outbox"
+	"subscriptions/internal/reminders"
+	"subscriptions/internal/storage"
+	"subscriptions/internal/storage/memory"
+	"subscriptions/internal/storage/sqlite"
+	"subscriptions/internal/store"
+	"subscriptions/internal/webhooks"
+)
+
+// Хранилище, выбранное в конфигурации. Миграции и метрики пула соединений есть не у всех реализаций,
+// поэтому они проверяются через приведение к интерфейсам ниже
+
+type backend interface {
+	store.Storage
+	reminders.Store
+	webhooks.Store
+	outbox.Store
+	Ping(ctx context.Context) error
+	Close() error
+}
+
+type migrator interface {
+	MigrateUp(steps int) error
+	MigrateDown(steps int) error
+	MigrationVersion() (uint, bool, error)
+	ForceMigration(version int) error
+	CheckMigrations(ctx context.Context) error
+}
+
+type instrumented interface {
+	RegisterMetrics(m *metrics.Metrics)
+}
+
+// ctx ограничивает ожидание базы при старте, отмена прерывает повторные попытки подключения
+
+func openStorage(ctx context.Context, cfg config.DatabaseConfig) (backend, error) {
+	switch cfg.Backend() {
+	case "memory":
+		return memory.NewStorage(), nil
+	case "sqlite":
+		return sqlite.NewStorage(ctx, cfg)
+	default:
+		return storage.NewStorage(ctx, cfg)
+	}
+}
@@ -0,0 +1,7 @@
+package cache
+
+import "time"
+
+func (c *LRU) SetClock(now func() time.Time) {
+	c.now = now
+}
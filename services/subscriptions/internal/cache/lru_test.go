@@ -0,0 +1,52 @@
+package cache_test
+
+import (
+	"context"
+	"subscriptions/internal/cache"
+	"testing"
+	"time"
+)
+
+func TestLRU(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2025, 7, 1, 0, 0, 0, 0, time.UTC)
+
+	c := cache.NewLRU(2)
+	c.SetClock(func() time.Time { return now })
+
+	c.Set(ctx, "a", []byte("1"), time.Minute)
+	c.Set(ctx, "b", []byte("2"), time.Minute)
+	c.Get(ctx, "a")
+	c.Set(ctx, "c", []byte("3"), time.Minute)
+
+	if _, ok, _ := c.Get(ctx, "b"); ok {
+		t.Fatal("b must be evicted as least recently used")
+	}
+	if v, ok, _ := c.Get(ctx, "a"); !ok || string(v) != "1" {
+		t.Fatalf("a = %q, %v", v, ok)
+	}
+
+	now = now.Add(2 * time.Minute)
+
+	if _, ok, _ := c.Get(ctx, "c"); ok {
+		t.Fatal("c must expire after ttl")
+	}
+}
+
+func TestLRUDeletePrefix(t *testing.T) {
+	ctx := context.Background()
+	c := cache.NewLRU(10)
+
+	c.Set(ctx, "subs:1:all", []byte("1"), time.Minute)
+	c.Set(ctx, "subs:1:sum:Netflix", []byte("2"), time.Minute)
+	c.Set(ctx, "subs:2:all", []byte("3"), time.Minute)
+
+	c.DeletePrefix(ctx, "subs:1:")
+
+	if c.Len() != 1 {
+		t.Fatalf("len = %d, want 1", c.Len())
+	}
+	if _, ok, _ := c.Get(ctx, "subs:2:all"); !ok {
+		t.Fatal("other user's entry must stay")
+	}
+}
@@ -0,0 +1,285 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"subscriptions/internal/models"
+	"subscriptions/internal/service"
+)
+
+// Бюджеты есть только в v2. Пользователь берётся из Authorization, чужой бюджет выглядит как отсутствующий
+
+const budgetsPrefix = "/api/v2/budgets/"
+
+// Тело запроса на создание и обновление бюджета
+
+type budgetRequest struct {
+	ServiceName  string `json:"service_name" example:"Netflix"`
+	MonthlyLimit *int   `json:"monthly_limit" example:"1000"`
+}
+
+func decodeBudget(r *http.Request) (models.Budget, error) {
+	var in budgetRequest
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		return models.Budget{}, fmt.Errorf("request body is not valid JSON")
+	}
+
+	if in.MonthlyLimit == nil {
+		return models.Budget{}, fmt.Errorf("monthly_limit is required")
+	}
+	if *in.MonthlyLimit < 0 {
+		return models.Budget{}, fmt.Errorf("monthly_limit must not be negative")
+	}
+
+	return models.Budget{ServiceName: in.ServiceName, MonthlyLimit: *in.MonthlyLimit}, nil
+}
+
+// Бюджет по id из пути, если он принадлежит пользователю из Authorization. Ответ с ошибкой уже записан, если ok false
+
+func (h *HandlersV2) ownBudget(w http.ResponseWriter, r *http.Request) (*models.Budget, bool) {
+	userId, err := getUserUuid(r)
+	if err != nil {
+		writeErrorV2(w, http.StatusUnauthorized, codeUnauthorized, "Authorization header must contain user UUID")
+		return nil, false
+	}
+
+	id, err := getSubId(w, r)
+	if err != nil {
+		writeErrorV2(w, http.StatusBadRequest, codeInvalidRequest, "id must be a number")
+		return nil, false
+	}
+
+	b, err := h.w.AsyncReadBudget(r.Context(), models.Budget{Id: id})
+	if err == nil && b.UserId != userId {
+		err = service.ErrBudgetNotFound
+	}
+	if err != nil {
+		writeServiceErrorV2(w, err)
+		return nil, false
+	}
+
+	return b, true
+}
+
+// CreateBudget godoc
+// @Summary     Создать бюджет
+// @Description Месячный бюджет пользователя из Authorization: общий, если service_name пуст, или по одному сервису. Бюджеты проверяются после каждого создания и изменения подписки.
+// @Tags        budgets v2
+// @Accept      json
+// @Produce     json
+// @Param       Authorization header string        true "User UUID"
+// @Param       budget        body   budgetRequest true "Бюджет"
+// @Success     201           {object} models.Budget
+// @Failure     400           {object} models.ErrorV2
+// @Failure     401           {object} models.ErrorV2
+// @Failure     409           {object} models.ErrorV2
+// @Failure     500           {object} models.ErrorV2
+// @Router      /api/v2/budgets [post]
+func (h *HandlersV2) CreateBudget(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	userId, err := getUserUuid(r)
+	if err != nil {
+		writeErrorV2(w, http.StatusUnauthorized, codeUnauthorized, "Authorization header must contain user UUID")
+		return
+	}
+
+	b, err := decodeBudget(r)
+	if err != nil {
+		writeErrorV2(w, http.StatusBadRequest, codeInvalidRequest, err.Error())
+		return
+	}
+	b.UserId = userId
+
+	// Имя сервиса в созданной записи могло замениться именем из каталога
+	created, err := h.w.AsyncCreateBudget(ctx, b)
+	if err != nil {
+		writeServiceErrorV2(w, err)
+		slog.ErrorContext(ctx, "CreateBudgetV2: error during AsyncCreateBudget request", "error", err)
+		return
+	}
+
+	slog.InfoContext(ctx, "CreateBudgetV2: budget record created", "id", created.Id, "service_name", created.ServiceName)
+
+	w.Header().Set("Location", budgetsPrefix+strconv.Itoa(created.Id))
+
+	err = writeJSON(w, http.StatusCreated, created)
+	if err != nil {
+		slog.ErrorContext(ctx, "CreateBudgetV2: error during writeJSON", "error", err)
+	}
+}
+
+// ReadBudget godoc
+// @Summary     Получить бюджет по ID
+// @Tags        budgets v2
+// @Produce     json
+// @Param       Authorization header string true "User UUID"
+// @Param       id            path   int    true "Budget ID"
+// @Success     200           {object} models.Budget
+// @Failure     400           {object} models.ErrorV2
+// @Failure     401           {object} models.ErrorV2
+// @Failure     404           {object} models.ErrorV2
+// @Failure     500           {object} models.ErrorV2
+// @Router      /api/v2/budgets/{id} [get]
+func (h *HandlersV2) ReadBudget(w http.ResponseWriter, r *http.Request) {
+	b, ok := h.ownBudget(w, r)
+	if !ok {
+		return
+	}
+
+	err := writeJSON(w, http.StatusOK, b)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "ReadBudgetV2: error during writeJSON", "error", err)
+	}
+}
+
+// ReadBudgets godoc
+// @Summary     Получить все бюджеты пользователя
+// @Tags        budgets v2
+// @Produce     json
+// @Param       Authorization header string true "User UUID"
+// @Success     200           {array}  models.Budget
+// @Failure     401           {object} models.ErrorV2
+// @Failure     500           {object} models.ErrorV2
+// @Router      /api/v2/budgets [get]
+func (h *HandlersV2) ReadBudgets(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	userId, err := getUserUuid(r)
+	if err != nil {
+		writeErrorV2(w, http.StatusUnauthorized, codeUnauthorized, "Authorization header must contain user UUID")
+		return
+	}
+
+	budgets, err := h.w.AsyncReadBudgets(ctx, models.Budget{UserId: userId})
+	if err != nil {
+		writeServiceErrorV2(w, err)
+		slog.ErrorContext(ctx, "ReadBudgetsV2: error during AsyncReadBudgets request", "error", err)
+		return
+	}
+
+	if budgets == nil {
+		budgets = []models.Budget{}
+	}
+
+	err = writeJSON(w, http.StatusOK, budgets)
+	if err != nil {
+		slog.ErrorContext(ctx, "ReadBudgetsV2: error during writeJSON", "error", err)
+	}
+}
+
+// UpdateBudget godoc
+// @Summary     Обновить бюджет
+// @Tags        budgets v2
+// @Accept      json
+// @Produce     json
+// @Param       Authorization header string        true "User UUID"
+// @Param       id            path   int           true "Budget ID"
+// @Param       budget        body   budgetRequest true "Новые данные бюджета"
+// @Success     200           {object} models.Budget
+// @Failure     400           {object} models.ErrorV2
+// @Failure     401           {object} models.ErrorV2
+// @Failure     404           {object} models.ErrorV2
+// @Failure     409           {object} models.ErrorV2
+// @Failure     500           {object} models.ErrorV2
+// @Router      /api/v2/budgets/{id} [put]
+func (h *HandlersV2) UpdateBudget(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	old, ok := h.ownBudget(w, r)
+	if !ok {
+		return
+	}
+
+	b, err := decodeBudget(r)
+	if err != nil {
+		writeErrorV2(w, http.StatusBadRequest, codeInvalidRequest, err.Error())
+		return
+	}
+	b.Id, b.UserId = old.Id, old.UserId
+
+	updated, err := h.w.AsyncUpdateBudget(ctx, b)
+	if err != nil {
+		writeServiceErrorV2(w, err)
+		slog.ErrorContext(ctx, "UpdateBudgetV2: error during AsyncUpdateBudget request", "id", b.Id, "error", err)
+		return
+	}
+
+	slog.InfoContext(ctx, "UpdateBudgetV2: budget record updated", "id", b.Id)
+
+	err = writeJSON(w, http.StatusOK, updated)
+	if err != nil {
+		slog.ErrorContext(ctx, "UpdateBudgetV2: error during writeJSON", "error", err)
+	}
+}
+
+// DeleteBudget godoc
+// @Summary     Удалить бюджет
+// @Description Вместе с бюджетом удаляются его предупреждения.
+// @Tags        budgets v2
+// @Param       Authorization header string true "User UUID"
+// @Param       id            path   int    true "Budget ID"
+// @Success     204
+// @Failure     400           {object} models.ErrorV2
+// @Failure     401           {object} models.ErrorV2
+// @Failure     404           {object} models.ErrorV2
+// @Failure     500           {object} models.ErrorV2
+// @Router      /api/v2/budgets/{id} [delete]
+func (h *HandlersV2) DeleteBudget(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	b, ok := h.ownBudget(w, r)
+	if !ok {
+		return
+	}
+
+	err := h.w.AsyncDeleteBudget(ctx, *b)
+	if err != nil {
+		writeServiceErrorV2(w, err)
+		slog.ErrorContext(ctx, "DeleteBudgetV2: error during AsyncDeleteBudget request", "id", b.Id, "error", err)
+		return
+	}
+
+	slog.InfoContext(ctx, "DeleteBudgetV2: budget record deleted", "id", b.Id)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ReadAlerts godoc
+// @Summary     Получить предупреждения о превышении бюджетов
+// @Description Одно предупреждение на бюджет и месяц с последней посчитанной суммой, сначала новые месяцы.
+// @Tags        budgets v2
+// @Produce     json
+// @Param       Authorization header string true "User UUID"
+// @Success     200           {array}  models.BudgetAlert
+// @Failure     401           {object} models.ErrorV2
+// @Failure     500           {object} models.ErrorV2
+// @Router      /api/v2/budgets/alerts [get]
+func (h *HandlersV2) ReadAlerts(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	userId, err := getUserUuid(r)
+	if err != nil {
+		writeErrorV2(w, http.StatusUnauthorized, codeUnauthorized, "Authorization header must contain user UUID")
+		return
+	}
+
+	alerts, err := h.w.AsyncReadAlerts(ctx, models.Subscription{UserId: userId})
+	if err != nil {
+		writeServiceErrorV2(w, err)
+		slog.ErrorContext(ctx, "ReadAlertsV2: error during AsyncReadAlerts request", "error", err)
+		return
+	}
+
+	if alerts == nil {
+		alerts = []models.BudgetAlert{}
+	}
+
+	err = writeJSON(w, http.StatusOK, alerts)
+	if err != nil {
+		slog.ErrorContext(ctx, "ReadAlertsV2: error during writeJSON", "error", err)
+	}
+}
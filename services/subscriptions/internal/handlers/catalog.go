@@ -0,0 +1,237 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"subscriptions/internal/models"
+)
+
+// Каталог сервисов есть только в v2. Чтение открыто всем, изменение — под /api/v2/admin с токеном администратора.
+// Имя подписки или бюджета, совпавшее с именем или псевдонимом из каталога, записывается каноническим именем
+
+const catalogPrefix = "/api/v2/services/"
+
+// Тело запроса на создание и обновление записи каталога
+
+type catalogRequest struct {
+	Name         string   `json:"name" example:"Netflix"`
+	Aliases      []string `json:"aliases" example:"netflix.com,NFLX"`
+	Category     string   `json:"category" example:"video"`
+	DefaultPrice int      `json:"default_price" example:"400"`
+	LogoURL      string   `json:"logo_url" example:"https://cdn.example.com/logos/netflix.png"`
+}
+
+func decodeCatalogEntry(r *http.Request) (models.CatalogEntry, error) {
+	var in catalogRequest
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		return models.CatalogEntry{}, fmt.Errorf("request body is not valid JSON")
+	}
+
+	if strings.TrimSpace(in.Name) == "" {
+		return models.CatalogEntry{}, fmt.Errorf("name is required")
+	}
+	if in.DefaultPrice < 0 {
+		return models.CatalogEntry{}, fmt.Errorf("default_price must not be negative")
+	}
+	if in.LogoURL != "" {
+		if u, err := url.Parse(in.LogoURL); err != nil || u.Scheme != "http" && u.Scheme != "https" || u.Host == "" {
+			return models.CatalogEntry{}, fmt.Errorf("logo_url must be an absolute http or https URL")
+		}
+	}
+
+	return models.CatalogEntry{
+		Name:         in.Name,
+		Aliases:      in.Aliases,
+		Category:     in.Category,
+		DefaultPrice: in.DefaultPrice,
+		LogoURL:      in.LogoURL,
+	}, nil
+}
+
+// ReadCatalog godoc
+// @Summary     Каталог сервисов
+// @Description Записи каталога по алфавиту. Имя подписки, совпавшее с name или одним из aliases без учёта регистра, сохраняется как name.
+// @Tags        catalog v2
+// @Produce     json
+// @Success     200 {array}  models.CatalogEntry
+// @Failure     500 {object} models.ErrorV2
+// @Router      /api/v2/services [get]
+func (h *HandlersV2) ReadCatalog(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	entries, err := h.w.AsyncReadCatalog(ctx)
+	if err != nil {
+		writeServiceErrorV2(w, err)
+		slog.ErrorContext(ctx, "ReadCatalogV2: error during AsyncReadCatalog request", "error", err)
+		return
+	}
+
+	if entries == nil {
+		entries = []models.CatalogEntry{}
+	}
+
+	err = writeJSON(w, http.StatusOK, entries)
+	if err != nil {
+		slog.ErrorContext(ctx, "ReadCatalogV2: error during writeJSON", "error", err)
+	}
+}
+
+// ReadCatalogEntry godoc
+// @Summary     Получить запись каталога по ID
+// @Tags        catalog v2
+// @Produce     json
+// @Param       id  path     int true "Catalog entry ID"
+// @Success     200 {object} models.CatalogEntry
+// @Failure     400 {object} models.ErrorV2
+// @Failure     404 {object} models.ErrorV2
+// @Failure     500 {object} models.ErrorV2
+// @Router      /api/v2/services/{id} [get]
+func (h *HandlersV2) ReadCatalogEntry(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	id, err := getSubId(w, r)
+	if err != nil {
+		writeErrorV2(w, http.StatusBadRequest, codeInvalidRequest, "id must be a number")
+		return
+	}
+
+	e, err := h.w.AsyncReadCatalogEntry(ctx, models.CatalogEntry{Id: id})
+	if err != nil {
+		writeServiceErrorV2(w, err)
+		slog.WarnContext(ctx, "ReadCatalogEntryV2: error during AsyncReadCatalogEntry request", "id", id, "error", err)
+		return
+	}
+
+	err = writeJSON(w, http.StatusOK, e)
+	if err != nil {
+		slog.ErrorContext(ctx, "ReadCatalogEntryV2: error during writeJSON", "error", err)
+	}
+}
+
+// CreateCatalogEntry godoc
+// @Summary     Добавить сервис в каталог
+// @Description Пробелы в имени и псевдонимах нормализуются, повторы псевдонимов отбрасываются. Имя и псевдонимы не должны совпадать с именем или псевдонимом другой записи. Уже созданные подписки не переименовываются.
+// @Tags        catalog v2
+// @Accept      json
+// @Produce     json
+// @Param       Authorization header string         true "Bearer <admin token>"
+// @Param       entry         body   catalogRequest true "Запись каталога"
+// @Success     201           {object} models.CatalogEntry
+// @Failure     400           {object} models.ErrorV2
+// @Failure     401           {object} models.ErrorV2
+// @Failure     403           {object} models.ErrorV2
+// @Failure     409           {object} models.ErrorV2
+// @Failure     500           {object} models.ErrorV2
+// @Router      /api/v2/admin/services [post]
+func (h *HandlersV2) CreateCatalogEntry(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	e, err := decodeCatalogEntry(r)
+	if err != nil {
+		writeErrorV2(w, http.StatusBadRequest, codeInvalidRequest, err.Error())
+		return
+	}
+
+	created, err := h.w.AsyncCreateCatalogEntry(ctx, e)
+	if err != nil {
+		writeServiceErrorV2(w, err)
+		slog.ErrorContext(ctx, "CreateCatalogEntryV2: error during AsyncCreateCatalogEntry request", "error", err)
+		return
+	}
+
+	slog.InfoContext(ctx, "CreateCatalogEntryV2: catalog record created", "id", created.Id, "name", created.Name)
+
+	w.Header().Set("Location", catalogPrefix+strconv.Itoa(created.Id))
+
+	err = writeJSON(w, http.StatusCreated, created)
+	if err != nil {
+		slog.ErrorContext(ctx, "CreateCatalogEntryV2: error during writeJSON", "error", err)
+	}
+}
+
+// UpdateCatalogEntry godoc
+// @Summary     Обновить запись каталога
+// @Description Запись заменяется целиком. Подписки, записанные под прежним именем, не переименовываются.
+// @Tags        catalog v2
+// @Accept      json
+// @Produce     json
+// @Param       Authorization header string         true "Bearer <admin token>"
+// @Param       id            path   int            true "Catalog entry ID"
+// @Param       entry         body   catalogRequest true "Запись каталога"
+// @Success     200           {object} models.CatalogEntry
+// @Failure     400           {object} models.ErrorV2
+// @Failure     401           {object} models.ErrorV2
+// @Failure     403           {object} models.ErrorV2
+// @Failure     404           {object} models.ErrorV2
+// @Failure     409           {object} models.ErrorV2
+// @Failure     500           {object} models.ErrorV2
+// @Router      /api/v2/admin/services/{id} [put]
+func (h *HandlersV2) UpdateCatalogEntry(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	id, err := getSubId(w, r)
+	if err != nil {
+		writeErrorV2(w, http.StatusBadRequest, codeInvalidRequest, "id must be a number")
+		return
+	}
+
+	e, err := decodeCatalogEntry(r)
+	if err != nil {
+		writeErrorV2(w, http.StatusBadRequest, codeInvalidRequest, err.Error())
+		return
+	}
+	e.Id = id
+
+	updated, err := h.w.AsyncUpdateCatalogEntry(ctx, e)
+	if err != nil {
+		writeServiceErrorV2(w, err)
+		slog.ErrorContext(ctx, "UpdateCatalogEntryV2: error during AsyncUpdateCatalogEntry request", "id", id, "error", err)
+		return
+	}
+
+	slog.InfoContext(ctx, "UpdateCatalogEntryV2: catalog record updated", "id", id)
+
+	err = writeJSON(w, http.StatusOK, updated)
+	if err != nil {
+		slog.ErrorContext(ctx, "UpdateCatalogEntryV2: error during writeJSON", "error", err)
+	}
+}
+
+// DeleteCatalogEntry godoc
+// @Summary     Удалить запись каталога
+// @Description Подписки сохраняют имя сервиса, новые имена больше не приводятся к этой записи.
+// @Tags        catalog v2
+// @Param       Authorization header string true "Bearer <admin token>"
+// @Param       id            path   int    true "Catalog entry ID"
+// @Success     204
+// @Failure     400           {object} models.ErrorV2
+// @Failure     401           {object} models.ErrorV2
+// @Failure     403           {object} models.ErrorV2
+// @Failure     404           {object} models.ErrorV2
+// @Failure     500           {object} models.ErrorV2
+// @Router      /api/v2/admin/services/{id} [delete]
+func (h *HandlersV2) DeleteCatalogEntry(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	id, err := getSubId(w, r)
+	if err != nil {
+		writeErrorV2(w, http.StatusBadRequest, codeInvalidRequest, "id must be a number")
+		return
+	}
+
+	err = h.w.AsyncDeleteCatalogEntry(ctx, models.CatalogEntry{Id: id})
+	if err != nil {
+		writeServiceErrorV2(w, err)
+		slog.ErrorContext(ctx, "DeleteCatalogEntryV2: error during AsyncDeleteCatalogEntry request", "id", id, "error", err)
+		return
+	}
+
+	slog.InfoContext(ctx, "DeleteCatalogEntryV2: catalog record deleted", "id", id)
+
+	w.WriteHeader(http.StatusNoContent)
+}
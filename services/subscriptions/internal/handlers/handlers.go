@@ -1,13 +1,19 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
 	"strconv"
-	"strings"
 	"subscriptions/internal/models"
+	"subscriptions/internal/service"
+	"subscriptions/internal/storage"
+	"time"
+
+	"github.com/google/uuid"
 )
 
 const (
@@ -16,12 +22,30 @@ const (
 )
 
 type WorkerPool interface {
-	AsyncCreateSub(sub models.Subscription) (string, error)
-	AsyncUpdateSub(sub models.Subscription) error
-	AsyncDeleteSub(sub models.Subscription) error
-	AsyncReadSub(sub models.Subscription) (*models.Subscription, error)
-	AsyncReadSubs(sub models.Subscription) ([]models.Subscription, error)
-	AsyncShowSubscSum(sub models.Subscription) ([]models.Subscription, error)
+	AsyncCreateSub(ctx context.Context, sub models.Subscription) (*models.Subscription, error)
+	AsyncUpdateSub(ctx context.Context, sub models.Subscription) (*models.Subscription, error)
+	AsyncDeleteSub(ctx context.Context, sub models.Subscription) error
+	AsyncReadSub(ctx context.Context, sub models.Subscription) (*models.Subscription, error)
+	AsyncReadSubs(ctx context.Context, sub models.Subscription) ([]models.Subscription, error)
+	AsyncShowSubscSum(ctx context.Context, sub models.Subscription) (*models.SubscriptionSum, error)
+	AsyncMonthlySpending(ctx context.Context, sub models.Subscription) ([]models.MonthlySpending, error)
+	AsyncForecast(ctx context.Context, sub models.Subscription) (*models.Forecast, error)
+	AsyncCreateBudget(ctx context.Context, b models.Budget) (*models.Budget, error)
+	AsyncUpdateBudget(ctx context.Context, b models.Budget) (*models.Budget, error)
+	AsyncDeleteBudget(ctx context.Context, b models.Budget) error
+	AsyncReadBudget(ctx context.Context, b models.Budget) (*models.Budget, error)
+	AsyncReadBudgets(ctx context.Context, b models.Budget) ([]models.Budget, error)
+	AsyncReadAlerts(ctx context.Context, sub models.Subscription) ([]models.BudgetAlert, error)
+	AsyncCreateWebhook(ctx context.Context, wh models.Webhook) (*models.Webhook, error)
+	AsyncReadWebhook(ctx context.Context, wh models.Webhook) (*models.Webhook, error)
+	AsyncReadWebhooks(ctx context.Context, wh models.Webhook) ([]models.Webhook, error)
+	AsyncDeleteWebhook(ctx context.Context, wh models.Webhook) error
+	AsyncReadDeliveries(ctx context.Context, wh models.Webhook) ([]models.WebhookDelivery, error)
+	AsyncCreateCatalogEntry(ctx context.Context, e models.CatalogEntry) (*models.CatalogEntry, error)
+	AsyncReadCatalogEntry(ctx context.Context, e models.CatalogEntry) (*models.CatalogEntry, error)
+	AsyncReadCatalog(ctx context.Context) ([]models.CatalogEntry, error)
+	AsyncUpdateCatalogEntry(ctx context.Context, e models.CatalogEntry) (*models.CatalogEntry, error)
+	AsyncDeleteCatalogEntry(ctx context.Context, e models.CatalogEntry) error
 }
 
 type Handlers struct {
@@ -37,143 +61,120 @@ func NewHandler(a WorkerPool) *Handlers {
 // Функция для сериализации в JSON любого типа данных с помощью пустого интерфейса и его запись в http.ResponseWriter
 
 func writeJSON(w http.ResponseWriter, statusCode int, v interface{}) error {
-	log.Print("writeJSON method: start of request")
-
 	w.Header().Set("Content-type", "application/json")
 	w.WriteHeader(statusCode)
 
-	log.Printf("writeJSON method: start of encoding - header:%v, body for encoding:%v", w.Header(), v)
-
 	err := json.NewEncoder(w).Encode(v)
 	if err != nil {
-		log.Print(err.Error(), " writeJSON method")
-		return err
+		return fmt.Errorf("writeJSON method: %w", err)
 	}
 
-	log.Printf("writeJSON method: encoding complited")
-
 	return nil
 }
 
 // Функция для получения id записи
 
 func getSubId(w http.ResponseWriter, r *http.Request) (subIdi int, err error) {
-	log.Printf("getSubId method: start of method, path: %v", r.URL.Path)
-
-	log.Printf("getSubId method: start of TrimPrefix")
-
-	id := strings.TrimPrefix(r.URL.Path, "/subscriptions/")
-
-	log.Printf("getSubId method: TrimPrefix complited id = %v", id)
+	id := r.PathValue("id")
 
 	if id == "" {
-		log.Printf("getSubId method: error during id extraction, id is empty")
 		return 0, fmt.Errorf("getSubId method: error during id extraction, id is empty")
 	}
 
-	log.Printf("getSubId method: start of convertion id type string to type int")
-
 	subId, err := strconv.Atoi(id)
 	if err != nil {
-		log.Printf("getSubId method: id must be number, incorrect format, error:%v", err.Error())
 		return 0, fmt.Errorf("getSubId method: id must be number, incorrect format")
 	}
 
-	log.Printf("getSubId method: successful convertion id to type int")
-
-	log.Printf("getSubId method: successful request complited, subscription id = %v", subId)
-
 	return subId, nil
 }
 
 func getService(r *http.Request) (serviceName string, err error) {
-	log.Printf("getService method: start of method, path: %v", r.URL.Path)
-	log.Printf("getService method: start of TrimPrefix")
-
-	name := strings.TrimPrefix(r.URL.Path, "/subscriptions/sum/")
-
-	log.Printf("getService method: TrimPrefix complited name = %v", name)
+	name := r.PathValue("service")
 
 	if name == "" {
-		log.Printf("getService method: error during service name extraction, service name is empty, PATH = %v", r.URL.Path)
 		return "", fmt.Errorf("getService method: error during service name extraction, service name is empty, PATH = %v", r.URL.Path)
 	}
 
-	log.Printf("getService method: successful request complited, serviceName = %v", name)
 	return name, nil
 }
 
 // Функция для получения uuid пользователя
 
-func getUserUuid(r *http.Request) (uuid string, err error) {
-	log.Printf("getUserUuid method: start of method, path: %v", r.URL.Path)
-	log.Printf("getUserUuid method: start of header extraction, path: %v", r.URL.Path)
-
+func getUserUuid(r *http.Request) (userId string, err error) {
 	userUuid := r.Header.Get("Authorization")
 
-	log.Printf("getUserUuid method: extraction of userUuid complited, userUuid = %v", userUuid)
-
 	if userUuid == "" {
-		log.Print("getUserUuid method: error during userUuid extraction, empty userUuid in header Authorization")
 		return "", fmt.Errorf("getUserUuid method: error during userUuid extraction, empty userUuid in header Authorization")
 	}
 
-	log.Printf("getUserUuid method: successful request complited, userUuid = %v", userUuid)
+	if _, err := uuid.Parse(userUuid); err != nil {
+		return "", fmt.Errorf("getUserUuid method: userUuid in header Authorization is not a valid UUID")
+	}
 
 	return userUuid, nil
 }
 
+// Проверка данных подписки до обращения к базе: user_id хранится как UUID, цена не может быть отрицательной
+
+func validateSub(sub models.Subscription) error {
+	if _, err := uuid.Parse(sub.UserId); err != nil {
+		return fmt.Errorf("validateSub method: user_id is not a valid UUID")
+	}
+
+	if sub.Price < 0 {
+		return fmt.Errorf("validateSub method: price must not be negative")
+	}
+
+	return nil
+}
+
 // CreateSub godoc
 // @Summary     Создать подписку
 // @Description Создаёт новую подписку. Все данные, включая user_id, передаются в теле запроса.
-// @Tags        subscriptions
+// @Tags        subscriptions v1
+// @Deprecated
 // @Accept      json
 // @Produce     json
 // @Param       subscription  body   models.Subscription true "Данные новой подписки"
 // @Success     200           {string} string           "Подписка создана"
 // @Failure     400           {object} map[string]string "Bad Request"
 // @Failure     500           {object} map[string]string "Internal Server Error"
-// @Router      /subscriptions [post]
+// @Router      /api/v1/subscriptions [post]
 func (h *Handlers) CreateSub(w http.ResponseWriter, r *http.Request) {
-
-	log.Printf("CreateSub: method=%s url=%s", r.Method, r.URL.Path)
+	ctx := r.Context()
 
 	var sub models.Subscription
 
-	log.Printf("CreateSub: start of sub decoding")
-
 	err := json.NewDecoder(r.Body).Decode(&sub)
-
-	log.Printf("CreateSub: sub decoding complited, sub: %v", sub)
 	if err != nil {
 		http.Error(w, dataStructError, http.StatusBadRequest)
-		log.Print("CreateSub method: error durind decoding of json body ", err.Error())
+		slog.WarnContext(ctx, "CreateSub: error during decoding of json body", "error", err)
 		return
 	}
 
-	log.Printf("CreateSub: created sub %v ", sub)
+	err = validateSub(sub)
+	if err != nil {
+		http.Error(w, dataStructError, http.StatusBadRequest)
+		slog.WarnContext(ctx, "CreateSub: invalid subscription", "error", err)
+		return
+	}
 
-	userId, err := h.w.AsyncCreateSub(sub)
+	_, err = h.w.AsyncCreateSub(ctx, sub)
 	if err != nil {
 		http.Error(w, "error during creation of a subscription record", http.StatusInternalServerError)
-		log.Print("CreateSub method: error during AsyncCreateSub request ", err.Error())
+		slog.ErrorContext(ctx, "CreateSub: error during AsyncCreateSub request", "error", err)
 		return
 	}
 
-	log.Printf("CreateSub method: creation of subscription record complited - userId: %v ", userId)
+	slog.InfoContext(ctx, "CreateSub: subscription record created", "service_name", sub.ServiceName)
 
-	answer := fmt.Sprintf("the subscription record has been successfully registered, user id = %v", userId)
-
-	log.Printf("CreateSub method: start of request to writeJSON, answer = %v", answer)
+	answer := fmt.Sprintf("the subscription record has been successfully registered, user id = %v", sub.UserId)
 
 	err = writeJSON(w, http.StatusOK, answer)
 	if err != nil {
-		http.Error(w, "error during writing answer", http.StatusInternalServerError)
-		log.Print("CreateSub method: error during writeJSON ", err.Error())
-		return
+		slog.ErrorContext(ctx, "CreateSub: error during writeJSON", "error", err)
 	}
-
-	log.Print("CreateSub method: successful request complited")
 }
 
 // Хендлер для чтения записи о подписке
@@ -181,58 +182,43 @@ func (h *Handlers) CreateSub(w http.ResponseWriter, r *http.Request) {
 // ReadSub godoc
 // @Summary     Получить подписку по ID
 // @Description Возвращает данные одной подписки по её ID.
-// @Tags        subscriptions
+// @Tags        subscriptions v1
+// @Deprecated
 // @Produce     json
 // @Param       id   path      int    true  "Subscription ID"
 // @Success     200  {object}  models.Subscription "Данные подписки"
 // @Failure     400  {object}  map[string]string   "Bad Request"
 // @Failure     404  {object}  map[string]string   "Not Found"
 // @Failure     500  {object}  map[string]string   "Internal Server Error"
-// @Router      /subscriptions/{id} [get]
+// @Router      /api/v1/subscriptions/{id} [get]
 func (h *Handlers) ReadSub(w http.ResponseWriter, r *http.Request) {
-
-	log.Printf("ReadSub: method=%v url=%v", r.Method, r.URL.Path)
-
-	log.Printf("ReadSub: start of request to getSubId")
+	ctx := r.Context()
 
 	id, err := getSubId(w, r)
-
-	log.Printf("ReadSub: request to getSubId method complited, id = %v", id)
 	if err != nil {
 		http.Error(w, missedLinkError, http.StatusBadRequest)
-		log.Print("ReadSub method: error during getSubId request ", err.Error())
+		slog.WarnContext(ctx, "ReadSub: error during getSubId request", "error", err)
 		return
 	}
-	log.Printf("ReadSub: request to AsyncReadSub method, id = %v", id)
-
-	sub, err := h.w.AsyncReadSub(models.Subscription{Id: id})
-
-	log.Printf("ReadSub: request to AsyncReadSub method complited, sub = %v", sub)
 
+	sub, err := h.w.AsyncReadSub(ctx, models.Subscription{Id: id})
 	if err != nil {
 		http.Error(w, "error during read of subscription record", http.StatusInternalServerError)
-		log.Print("ReadSub method: error during AsyncReadSub request ", err.Error())
+		slog.ErrorContext(ctx, "ReadSub: error during AsyncReadSub request", "id", id, "error", err)
 		return
 	}
 
-	log.Printf("ReadSub method: start of request to writeJSON, sub = %v", sub)
-
 	err = writeJSON(w, http.StatusOK, sub)
-
 	if err != nil {
-		http.Error(w, "error during writing answer", http.StatusInternalServerError)
-		log.Print("ReadSub method: error during writeJSON ", err.Error())
-		return
+		slog.ErrorContext(ctx, "ReadSub: error during writeJSON", "error", err)
 	}
-
-	log.Print("ReadSub method: successful request complited")
 }
 
-
 // UpdateSub godoc
 // @Summary     Обновить подписку
 // @Description Обновляет запись подписки: указывается ID в пути и новые данные в теле запроса.
-// @Tags        subscriptions
+// @Tags        subscriptions v1
+// @Deprecated
 // @Accept      json
 // @Produce     json
 // @Param       id            path   int                 true  "Subscription ID"
@@ -240,57 +226,49 @@ func (h *Handlers) ReadSub(w http.ResponseWriter, r *http.Request) {
 // @Success     200           {string} string           "Подписка обновлена"
 // @Failure     400           {object} map[string]string "Bad Request"
 // @Failure     500           {object} map[string]string "Internal Server Error"
-// @Router      /subscriptions/{id} [put]
+// @Router      /api/v1/subscriptions/{id} [put]
 func (h *Handlers) UpdateSub(w http.ResponseWriter, r *http.Request) {
-	log.Printf("UpdateSub: method=%v url=%v", r.Method, r.URL.Path)
+	ctx := r.Context()
 
 	var sub models.Subscription
-	log.Printf("UpdateSub: start of sub decoding")
 
 	err := json.NewDecoder(r.Body).Decode(&sub)
-	log.Printf("UpdateSub: sub decoding complited, sub: %v", sub)
-
 	if err != nil {
 		http.Error(w, dataStructError, http.StatusBadRequest)
-		log.Print("UpdateSub method: error durind decoding of json body ", err.Error())
+		slog.WarnContext(ctx, "UpdateSub: error during decoding of json body", "error", err)
 		return
 	}
 
-	log.Printf("UpdateSub: start of request to getSubId")
-
 	id, err := getSubId(w, r)
-
-	log.Printf("UpdateSub: request to getSubId method complited, id = %v", id)
 	if err != nil {
 		http.Error(w, missedLinkError, http.StatusBadRequest)
-		log.Print("UpdateSub method: error during getSubId request ", err.Error())
+		slog.WarnContext(ctx, "UpdateSub: error during getSubId request", "error", err)
 		return
 	}
 
 	sub.Id = id
 
-	log.Printf("UpdateSub: request to AsyncUpdateSub method, id = %v", id)
-
-	err = h.w.AsyncUpdateSub(sub)
-
-	log.Printf("UpdateSub: request to AsyncUpdateSub method complited")
-
+	err = validateSub(sub)
 	if err != nil {
+		http.Error(w, dataStructError, http.StatusBadRequest)
+		slog.WarnContext(ctx, "UpdateSub: invalid subscription", "error", err)
+		return
+	}
+
+	// v1 не сообщал об отсутствии записи при изменении и удалении, ответ остаётся прежним
+	_, err = h.w.AsyncUpdateSub(ctx, sub)
+	if err != nil && !errors.Is(err, service.ErrNotFound) {
 		http.Error(w, "error during subscription record update", http.StatusInternalServerError)
-		log.Print("UpdateSub method: error during AsyncUpdateSub request ", err.Error())
+		slog.ErrorContext(ctx, "UpdateSub: error during AsyncUpdateSub request", "id", id, "error", err)
 		return
 	}
 
-	log.Printf("UpdateSub method: start of request to writeJSON")
+	slog.InfoContext(ctx, "UpdateSub: subscription record updated", "id", id)
 
 	err = writeJSON(w, http.StatusOK, "subscrription record updated successfuly")
 	if err != nil {
-		http.Error(w, "error during writing answer", http.StatusInternalServerError)
-		log.Print("UpdateSub method: error during writeJSON ", err.Error())
-		return
+		slog.ErrorContext(ctx, "UpdateSub: error during writeJSON", "error", err)
 	}
-
-	log.Print("UpdateSub method: successful request complited")
 }
 
 // Хендлер для удаления записи о подписке
@@ -298,52 +276,39 @@ func (h *Handlers) UpdateSub(w http.ResponseWriter, r *http.Request) {
 // DeleteSub godoc
 // @Summary     Удалить подписку
 // @Description Удаляет запись подписки по её ID.
-// @Tags        subscriptions
+// @Tags        subscriptions v1
+// @Deprecated
 // @Produce     json
 // @Param       id   path      int    true  "Subscription ID"
 // @Success     200  {string}  string "Подписка удалена"
 // @Failure     400  {object}  map[string]string "Bad Request"
 // @Failure     500  {object}  map[string]string "Internal Server Error"
-// @Router      /subscriptions/{id} [delete]
+// @Router      /api/v1/subscriptions/{id} [delete]
 func (h *Handlers) DeleteSub(w http.ResponseWriter, r *http.Request) {
-	log.Printf("DeleteSub: method=%v url=%v", r.Method, r.URL.Path)
+	ctx := r.Context()
 
 	//удаляем часть пути для получения только ссылки на подписку
 
-	log.Printf("DeleteSub: start of request to getSubId")
-
 	id, err := getSubId(w, r)
-
-	log.Printf("DeleteSub: request to getUserUuid method complited, id = %v", id)
-
 	if err != nil {
 		http.Error(w, missedLinkError, http.StatusBadRequest)
-		log.Print(err.Error(), " DeleteSub method")
+		slog.WarnContext(ctx, "DeleteSub: error during getSubId request", "error", err)
 		return
 	}
 
-	log.Printf("DeleteSub: request to AsyncDeleteSub method, id = %v", id)
-
-	err = h.w.AsyncDeleteSub(models.Subscription{Id: id})
-
-	log.Printf("DeleteSub: request to AsyncDeleteSub method complited")
-
-	if err != nil {
+	err = h.w.AsyncDeleteSub(ctx, models.Subscription{Id: id})
+	if err != nil && !errors.Is(err, service.ErrNotFound) {
 		http.Error(w, "error during deletion of subscription record", http.StatusInternalServerError)
-		log.Printf("DeleteSub: error during request to AsyncDeleteSub, error = %v", err)
+		slog.ErrorContext(ctx, "DeleteSub: error during AsyncDeleteSub request", "id", id, "error", err)
 		return
 	}
 
-	log.Printf("DeleteSub method: start of request to writeJSON")
+	slog.InfoContext(ctx, "DeleteSub: subscription record deleted", "id", id)
 
 	err = writeJSON(w, http.StatusOK, "subscription record deleted successfuly")
 	if err != nil {
-		http.Error(w, "error during writing answer", http.StatusInternalServerError)
-		log.Print("DeleteSub method: error during writeJSON ", err.Error())
-		return
+		slog.ErrorContext(ctx, "DeleteSub: error during writeJSON", "error", err)
 	}
-
-	log.Print("DeleteSub method: successful request complited")
 }
 
 // Метод для чтения записей о подписках с общей суммой (показывает все подписки со всеми сервисами для конкретного пользователя)
@@ -351,57 +316,94 @@ func (h *Handlers) DeleteSub(w http.ResponseWriter, r *http.Request) {
 // ReadSubs godoc
 // @Summary     Получить все подписки пользователя
 // @Description Возвращает список подписок для пользователя, UUID берётся из заголовка Authorization.
-// @Tags        subscriptions
+// @Tags        subscriptions v1
+// @Deprecated
 // @Produce     json
 // @Param       Authorization header string true "User UUID"
 // @Success     200 {array} models.Subscription "Список подписок"
 // @Failure     400 {object} map[string]string      "Bad Request"
 // @Failure     500 {object} map[string]string      "Internal Server Error"
-// @Router      /subscriptions [get]
+// @Router      /api/v1/subscriptions [get]
 func (h *Handlers) ReadSubs(w http.ResponseWriter, r *http.Request) {
-	log.Printf("ReadSubs: method=%v url=%v", r.Method, r.URL.Path)
-
-	log.Printf("ReadSubs: start of request to getUserUuid")
+	ctx := r.Context()
 
 	uuid, err := getUserUuid(r)
-
-	log.Printf("ReadSubs: request to getUserUuid method complited, uuid = %v", uuid)
-
 	if err != nil {
 		http.Error(w, missedLinkError, http.StatusInternalServerError)
-		log.Printf("ReadSubs: error during request to getUserUuid method, uuid = %v, error = %v", uuid, err.Error())
+		slog.WarnContext(ctx, "ReadSubs: error during getUserUuid request", "error", err)
 		return
 	}
 
-	log.Printf("ReadSubs: request to AsyncReadSubs method, uuid = %v", uuid)
+	subs, err := h.w.AsyncReadSubs(ctx, models.Subscription{UserId: uuid})
+	if err != nil {
+		http.Error(w, "error during subs extraction", http.StatusInternalServerError)
+		slog.ErrorContext(ctx, "ReadSubs: error during AsyncReadSubs request", "error", err)
+		return
+	}
 
-	subs, err := h.w.AsyncReadSubs(models.Subscription{UserId: uuid})
+	err = writeJSON(w, http.StatusOK, subs)
+	if err != nil {
+		slog.ErrorContext(ctx, "ReadSubs: error during writeJSON", "error", err)
+	}
+}
 
-	log.Printf("ReadSubs: request to AsyncReadSubs method complited, subs = %v", subs)
+// Summary godoc
+// @Summary     Получить подписки и их сумму за период
+// @Description Параметры передаются в строке запроса, user UUID — в заголовке Authorization. Без service суммируются все сервисы пользователя.
+// @Tags        subscriptions v1
+// @Deprecated
+// @Produce     json
+// @Param       Authorization header string  true  "User UUID"
+// @Param       service       query  string  false "Service name (например, Netflix)"
+// @Param       from          query  string  true  "Начало периода, например 2025-01-01"
+// @Param       to            query  string  true  "Конец периода, например 2025-12-31"
+// @Success     200           {object} models.SubscriptionSum
+// @Failure     400           {object} map[string]string "Bad Request"
+// @Failure     500           {object} map[string]string "Internal Server Error"
+// @Router      /api/v1/subscriptions/summary [get]
+func (h *Handlers) Summary(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
 
+	uuid, err := getUserUuid(r)
 	if err != nil {
-		http.Error(w, "error during subs extraction", http.StatusInternalServerError)
-		log.Printf("ReadSubs: error during request to AsyncReadSubs, error = %v", err)
+		http.Error(w, missedLinkError, http.StatusBadRequest)
+		slog.WarnContext(ctx, "Summary: error during getUserUuid request", "error", err)
 		return
 	}
 
-	log.Printf("ReadSubs method: start of request to writeJSON, subs = %v", subs)
+	query := r.URL.Query()
+	from, to := query.Get("from"), query.Get("to")
 
-	err = writeJSON(w, http.StatusOK, subs)
+	start, err := storage.ParseDate(from)
 	if err != nil {
-		http.Error(w, "error during writing answer", http.StatusInternalServerError)
-		log.Print("ReadSubs method: error during writeJSON ", err.Error())
+		http.Error(w, dataStructError, http.StatusBadRequest)
+		slog.WarnContext(ctx, "Summary: error during from parsing", "error", err)
 		return
 	}
 
-	log.Print("ReadSubs method: successful request complited")
-}
+	end, err := storage.ParseDate(to)
+	if err != nil {
+		http.Error(w, dataStructError, http.StatusBadRequest)
+		slog.WarnContext(ctx, "Summary: error during to parsing", "error", err)
+		return
+	}
+
+	if end.Before(start) {
+		http.Error(w, dataStructError, http.StatusBadRequest)
+		slog.WarnContext(ctx, "Summary: to is before from", "from", from, "to", to)
+		return
+	}
 
+	// Хранилищу период передаётся полными датами, как в v2: Postgres не принимает YYYY-MM и MM-YYYY
+	period := models.Subscription{ServiceName: query.Get("service"), UserId: uuid, StartDate: start.Format(time.DateOnly), EndDate: end.Format(time.DateOnly)}
+	h.writeSum(w, r, period, func(sum *models.SubscriptionSum) any { return sum })
+}
 
 // ShowSubscSum godoc
 // @Summary     Получить подписки и их сумму по сервису за период
-// @Description Сервис указывается в пути, период (start_date и end_date) — в теле, user UUID — в заголовке Authorization.
-// @Tags        subscriptions
+// @Description Устаревший вариант GET /api/v1/subscriptions/summary. Сервис указывается в пути, период (start_date и end_date) — в теле, user UUID — в заголовке Authorization.
+// @Tags        subscriptions v1
+// @Deprecated
 // @Accept      json
 // @Produce     json
 // @Param       Authorization header string               true  "User UUID"
@@ -410,71 +412,56 @@ func (h *Handlers) ReadSubs(w http.ResponseWriter, r *http.Request) {
 // @Success     200           {array}  models.Subscription
 // @Failure     400           {object} map[string]string  "Bad Request"
 // @Failure     500           {object} map[string]string  "Internal Server Error"
-// @Router      /subscriptions/sum/{service} [post]
+// @Router      /api/v1/subscriptions/sum/{service} [post]
 func (h *Handlers) ShowSubscSum(w http.ResponseWriter, r *http.Request) {
-	log.Printf("ShowSubscSum: method=%v url=%v", r.Method, r.URL.Path)
-	log.Printf("ShowSubscSum: request to getService method")
+	ctx := r.Context()
 
 	serviceName, err := getService(r)
-
-	log.Printf("ShowSubscSum: request to getService method complited")
-
 	if err != nil {
 		http.Error(w, missedLinkError, http.StatusBadRequest)
-		log.Printf("ShowSubscSum: error durind request to getService, error: %v", err)
+		slog.WarnContext(ctx, "ShowSubscSum: error during getService request", "error", err)
 		return
 	}
 
-	log.Printf("ShowSubscSum: successful request to getService method, serviceName = %v", serviceName)
-
-	log.Printf("ShowSubscSum: start of request to getUserUuid")
-
 	uuid, err := getUserUuid(r)
-
-	log.Printf("ShowSubscSum: request to getUserUuid method complited, uuid = %v", uuid)
-
 	if err != nil {
 		http.Error(w, missedLinkError, http.StatusInternalServerError)
-		log.Printf("ShowSubscSum: error during request to getUserUuid method, uuid = %v, error = %v", uuid, err.Error())
+		slog.WarnContext(ctx, "ShowSubscSum: error during getUserUuid request", "error", err)
 		return
 	}
 
 	var periods models.ShowSubscSum
 
-	log.Printf("ShowSubscSum: start of decoding periods")
-
 	err = json.NewDecoder(r.Body).Decode(&periods)
-
-	log.Printf("ShowSubscSum: decoding complited successfuly, periods = %v", periods)
-
 	if err != nil {
 		http.Error(w, dataStructError, http.StatusBadRequest)
-		log.Printf("ShowSubscSum: error during periods decoding, periods = %v, error = %v", periods, err.Error())
+		slog.WarnContext(ctx, "ShowSubscSum: error during periods decoding", "error", err)
 		return
 	}
 
-	log.Printf("ShowSubscSum: decoding complited successfuly, periods = %v", periods)
+	h.writeSum(w, r, models.Subscription{ServiceName: serviceName, UserId: uuid, StartDate: periods.StartDate, EndDate: periods.EndDate}, withTotalRow)
+}
 
-	log.Printf("ShowSubscSum: start of request to AsyncShowSubscSum, ServiceName = %v, UserId = %v, StartDate = %v, EndDate = %v", serviceName, uuid, periods.StartDate, periods.EndDate)
+// В ответе POST .../sum/{service} итог остаётся последней строкой "Итого" после подписок
 
-	subs, err := h.w.AsyncShowSubscSum(models.Subscription{ServiceName: serviceName, UserId: uuid, StartDate: periods.StartDate, EndDate: periods.EndDate})
+func withTotalRow(sum *models.SubscriptionSum) any {
+	return append(sum.Items, models.Subscription{ServiceName: "Итого", TotalSum: sum.Total})
+}
+
+// Общая часть Summary и ShowSubscSum: запрос суммы и ответ в формате, который задаёт render
 
-	log.Printf("ShowSubscSum: complited request to AsyncShowSubscSum, subs = %v", subs)
+func (h *Handlers) writeSum(w http.ResponseWriter, r *http.Request, req models.Subscription, render func(*models.SubscriptionSum) any) {
+	ctx := r.Context()
 
+	sum, err := h.w.AsyncShowSubscSum(ctx, req)
 	if err != nil {
 		http.Error(w, "error during subscription records summation", http.StatusInternalServerError)
-		log.Printf("ShowSubscSum: error during request to AsyncShowSubscSum, error: %v", err)
+		slog.ErrorContext(ctx, "ShowSubscSum: error during AsyncShowSubscSum request", "service_name", req.ServiceName, "error", err)
 		return
 	}
 
-	log.Printf("ShowSubscSum method: start of request to writeJSON, subs = %v", subs)
-
-	err = writeJSON(w, http.StatusOK, subs)
+	err = writeJSON(w, http.StatusOK, render(sum))
 	if err != nil {
-		log.Print("CreateSub method: error during writeJSON ", err.Error())
-		http.Error(w, "error during writing answer", http.StatusInternalServerError)
-		return
+		slog.ErrorContext(ctx, "ShowSubscSum: error during writeJSON", "error", err)
 	}
-
-	log.Print("ShowSubscSum method: successful request complited")
 }
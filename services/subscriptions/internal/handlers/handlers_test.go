@@ -1,9 +1,381 @@
 package handlers_test
 
-import "testing"
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"subscriptions/internal/config"
+	"subscriptions/internal/handlers"
+	"subscriptions/internal/metrics"
+	"subscriptions/internal/models"
+	"subscriptions/internal/router"
+	"subscriptions/internal/service"
+	"subscriptions/internal/storage/memory"
+	"subscriptions/internal/store"
+	"subscriptions/internal/webhooks"
+	"testing"
+	"time"
+)
 
+const userId = "60601fee-2bf1-4721-ae6f-7636e79a0cba"
 
+func newServer(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	return newServerWith(t, memory.NewStorage())
+}
+
+func newServerWith(t *testing.T, st store.Storage) *httptest.Server {
+	t.Helper()
+
+	s := service.NewService(st)
+	w := service.StartWorkerPool(2, 10, s, metrics.NewMetrics())
+	t.Cleanup(w.Stop)
+	mux := http.NewServeMux()
+	router.NewRouter(handlers.NewHandler(w), handlers.NewHandlerV2(w, webhooks.NewGuard(config.WebhooksConfig{AllowPrivateNetworks: true}))).InitRoutes(mux)
+
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	return srv
+}
+
+func do(t *testing.T, method string, url string, body string) *http.Response {
+	t.Helper()
+
+	req, err := http.NewRequest(method, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", userId)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+
+	return resp
+}
 
 func TestHandlers(t *testing.T) {
+	srv := newServer(t)
+
+	sub := `{"service_name":"Netflix","price":400,"user_id":"` + userId + `","start_date":"2025-07-01","end_date":"2025-09-01"}`
+
+	resp := do(t, http.MethodPost, srv.URL+"/subscriptions", sub)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("create: status %d", resp.StatusCode)
+	}
+
+	resp = do(t, http.MethodGet, srv.URL+"/subscriptions/1", "")
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("read: status %d", resp.StatusCode)
+	}
+
+	var got models.Subscription
+	if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	if got.ServiceName != "Netflix" || got.StartDate != "07-2025" || got.EndDate != "09-2025" {
+		t.Fatalf("read: unexpected subscription %+v", got)
+	}
+
+	resp = do(t, http.MethodPost, srv.URL+"/subscriptions/sum/Netflix", `{"start_date":"2025-01-01","end_date":"2025-12-31"}`)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("sum: status %d", resp.StatusCode)
+	}
+
+	var subs []models.Subscription
+	if err := json.NewDecoder(resp.Body).Decode(&subs); err != nil {
+		t.Fatal(err)
+	}
+	if len(subs) != 2 || subs[1].TotalSum != 400 {
+		t.Fatalf("sum: unexpected response %+v", subs)
+	}
+
+	resp = do(t, http.MethodGet, srv.URL+"/subscriptions/summary?from=2025-01-01&to=2025-12-31", "")
+	var sum models.SubscriptionSum
+	if err := json.NewDecoder(resp.Body).Decode(&sum); err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK || len(sum.Items) != 1 || sum.Total != 400 {
+		t.Fatalf("summary: status %d, response %+v", resp.StatusCode, sum)
+	}
+
+	for _, query := range []string{"from=2025-01-01", "from=yesterday&to=2025-12-31", "from=2025-12-31&to=2025-01-01"} {
+		if resp := do(t, http.MethodGet, srv.URL+"/subscriptions/summary?"+query, ""); resp.StatusCode != http.StatusBadRequest {
+			t.Fatalf("summary %s: status %d", query, resp.StatusCode)
+		}
+	}
+
+	resp = do(t, http.MethodDelete, srv.URL+"/subscriptions/1", "")
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("delete: status %d", resp.StatusCode)
+	}
+
+	resp = do(t, http.MethodGet, srv.URL+"/subscriptions/1", "")
+	if resp.StatusCode != http.StatusInternalServerError {
+		t.Fatalf("read after delete: status %d", resp.StatusCode)
+	}
+}
+
+// Как Postgres, принимает период суммы только полными датами
+
+type fullDateStorage struct {
+	store.Storage
+}
+
+func (s fullDateStorage) WithTx(ctx context.Context, fn func(tx store.Storage) error) error {
+	return s.Storage.WithTx(ctx, func(tx store.Storage) error { return fn(fullDateStorage{tx}) })
+}
+
+func (s fullDateStorage) WithReadTx(ctx context.Context, fn func(tx store.Storage) error) error {
+	return s.Storage.WithReadTx(ctx, func(tx store.Storage) error { return fn(fullDateStorage{tx}) })
+}
+
+func (s fullDateStorage) ShowSubscSumRequest(ctx context.Context, serviceName string, userId string, startPeriod string, endPeriod string) (*models.SubscriptionSum, error) {
+	for _, date := range []string{startPeriod, endPeriod} {
+		if _, err := time.Parse(time.DateOnly, date); err != nil {
+			return nil, err
+		}
+	}
+	return s.Storage.ShowSubscSumRequest(ctx, serviceName, userId, startPeriod, endPeriod)
+}
+
+func TestSummaryMonths(t *testing.T) {
+	srv := newServerWith(t, fullDateStorage{memory.NewStorage()})
+
+	sub := `{"service_name":"Netflix","price":400,"user_id":"` + userId + `","start_date":"2025-07-01","end_date":"2025-09-01"}`
+	if resp := do(t, http.MethodPost, srv.URL+"/subscriptions", sub); resp.StatusCode != http.StatusOK {
+		t.Fatalf("create: status %d", resp.StatusCode)
+	}
+
+	for _, query := range []string{"from=2025-01&to=2025-12", "from=01-2025&to=12-2025"} {
+		resp := do(t, http.MethodGet, srv.URL+"/subscriptions/summary?"+query, "")
+		var sum models.SubscriptionSum
+		if err := json.NewDecoder(resp.Body).Decode(&sum); err != nil {
+			t.Fatalf("summary %s: status %d, error %v", query, resp.StatusCode, err)
+		}
+		if resp.StatusCode != http.StatusOK || len(sum.Items) != 1 || sum.Total != 400 {
+			t.Fatalf("summary %s: status %d, response %+v", query, resp.StatusCode, sum)
+		}
+	}
+}
+
+func TestCreateSubValidation(t *testing.T) {
+	srv := newServer(t)
+
+	tests := map[string]string{
+		"broken json":    `{"service_name":`,
+		"user not uuid":  `{"service_name":"Netflix","price":400,"user_id":"42","start_date":"2025-07-01"}`,
+		"negative price": `{"service_name":"Netflix","price":-1,"user_id":"` + userId + `","start_date":"2025-07-01"}`,
+	}
+
+	for name, body := range tests {
+		t.Run(name, func(t *testing.T) {
+			resp := do(t, http.MethodPost, srv.URL+"/subscriptions", body)
+			if resp.StatusCode != http.StatusBadRequest {
+				t.Fatalf("status %d, want %d", resp.StatusCode, http.StatusBadRequest)
+			}
+		})
+	}
+}
+
+func TestHandlersV2(t *testing.T) {
+	srv := newServer(t)
+	base := srv.URL + "/api/v2/subscriptions"
+
+	resp := do(t, http.MethodPost, base, `{"service_name":"Netflix","price":400,"user_id":"`+userId+`","start_date":"2025-07","end_date":"2025-09"}`)
+	if resp.StatusCode != http.StatusCreated || resp.Header.Get("Location") != "/api/v2/subscriptions/1" {
+		t.Fatalf("create: status %d, location %q", resp.StatusCode, resp.Header.Get("Location"))
+	}
+
+	var created models.SubscriptionV2
+	if err := json.NewDecoder(resp.Body).Decode(&created); err != nil {
+		t.Fatal(err)
+	}
+	if created.Id != 1 || created.StartDate != "2025-07" || created.EndDate != "2025-09" {
+		t.Fatalf("create: unexpected subscription %+v", created)
+	}
+
+	resp = do(t, http.MethodGet, base+"/summary?service=Netflix&from=2025-01&to=2025-12", "")
+	var sum models.SubscriptionSumV2
+	if err := json.NewDecoder(resp.Body).Decode(&sum); err != nil {
+		t.Fatal(err)
+	}
+	if len(sum.Items) != 1 || sum.Total != 400 {
+		t.Fatalf("sum: unexpected response %+v", sum)
+	}
+
+	resp = do(t, http.MethodPost, base, `{"service_name":"Netflix","price":400,"user_id":"`+userId+`","start_date":"2025-07","end_date":"2025-01"}`)
+	var apiErr models.ErrorV2
+	if err := json.NewDecoder(resp.Body).Decode(&apiErr); err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusBadRequest || apiErr.Error.Code != "invalid_request" {
+		t.Fatalf("invalid period: status %d, error %+v", resp.StatusCode, apiErr)
+	}
+
+	update := `{"service_name":"Netflix","price":500,"user_id":"` + userId + `","start_date":"2025-07"}`
+	resp = do(t, http.MethodPut, base+"/1", update)
+	var updated models.SubscriptionV2
+	if err := json.NewDecoder(resp.Body).Decode(&updated); err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK || updated.Price != 500 || updated.EndDate != "" {
+		t.Fatalf("update: status %d, subscription %+v", resp.StatusCode, updated)
+	}
+
+	if resp = do(t, http.MethodDelete, base+"/1", ""); resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("delete: status %d", resp.StatusCode)
+	}
+
+	for _, method := range []string{http.MethodGet, http.MethodDelete} {
+		if resp = do(t, method, base+"/1", ""); resp.StatusCode != http.StatusNotFound {
+			t.Fatalf("%s after delete: status %d, want 404", method, resp.StatusCode)
+		}
+	}
+	if resp = do(t, http.MethodPut, base+"/1", update); resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("PUT after delete: status %d, want 404", resp.StatusCode)
+	}
+}
+
+func TestBudgetAlerts(t *testing.T) {
+	srv := newServer(t)
+	base := srv.URL + "/api/v2"
+
+	resp := do(t, http.MethodPost, base+"/budgets", `{"service_name":"Netflix","monthly_limit":300}`)
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("create budget: status %d", resp.StatusCode)
+	}
+	if resp = do(t, http.MethodPost, base+"/budgets", `{"service_name":"Netflix","monthly_limit":500}`); resp.StatusCode != http.StatusConflict {
+		t.Fatalf("duplicate budget: status %d, want 409", resp.StatusCode)
+	}
+
+	// Подписка действует в текущем месяце, её создание запускает проверку бюджетов в пуле воркеров
+	start := time.Now().UTC().Format("2006-01")
+	resp = do(t, http.MethodPost, base+"/subscriptions", `{"service_name":"Netflix","price":400,"user_id":"`+userId+`","start_date":"`+start+`"}`)
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("create subscription: status %d", resp.StatusCode)
+	}
+
+	var alerts []models.BudgetAlert
+	for deadline := time.Now().Add(2 * time.Second); len(alerts) == 0 && time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		resp = do(t, http.MethodGet, base+"/budgets/alerts", "")
+		if err := json.NewDecoder(resp.Body).Decode(&alerts); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(alerts) != 1 || alerts[0].Spent != 400 || alerts[0].MonthlyLimit != 300 || alerts[0].Month != start {
+		t.Fatalf("unexpected alerts %+v", alerts)
+	}
+
+	// Чужой бюджет не виден
+	req, _ := http.NewRequest(http.MethodGet, base+"/budgets/1", nil)
+	req.Header.Set("Authorization", "0f6a6a54-5c1c-4b38-9a4e-0c1a2b3c4d5e")
+	other, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other.Body.Close()
+	if other.StatusCode != http.StatusNotFound {
+		t.Fatalf("budget of another user: status %d, want 404", other.StatusCode)
+	}
+}
+
+func TestWebhooks(t *testing.T) {
+	srv := newServer(t)
+	base := srv.URL + "/api/v2/webhooks"
+
+	if resp := do(t, http.MethodPost, base, `{"url":"ftp://example.com"}`); resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("invalid url: status %d", resp.StatusCode)
+	}
+
+	resp := do(t, http.MethodPost, base, `{"url":"https://example.com/hook"}`)
+	var created models.Webhook
+	if err := json.NewDecoder(resp.Body).Decode(&created); err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusCreated || len(created.Secret) != 64 || resp.Header.Get("Location") != "/api/v2/webhooks/1" {
+		t.Fatalf("create: status %d, webhook %+v", resp.StatusCode, created)
+	}
+
+	// Секрет виден только в ответе на создание
+	resp = do(t, http.MethodGet, base+"/1", "")
+	var got models.Webhook
+	if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK || got.Secret != "" || got.URL != "https://example.com/hook" {
+		t.Fatalf("read: status %d, webhook %+v", resp.StatusCode, got)
+	}
+
+	resp = do(t, http.MethodGet, base+"/1/deliveries", "")
+	var deliveries []models.WebhookDelivery
+	if err := json.NewDecoder(resp.Body).Decode(&deliveries); err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK || deliveries == nil {
+		t.Fatalf("deliveries: status %d, %v", resp.StatusCode, deliveries)
+	}
+
+	if resp = do(t, http.MethodDelete, base+"/1", ""); resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("delete: status %d", resp.StatusCode)
+	}
+	if resp = do(t, http.MethodGet, base+"/1", ""); resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("read after delete: status %d", resp.StatusCode)
+	}
+}
+
+func TestCatalog(t *testing.T) {
+	srv := newServer(t)
+	base := srv.URL + "/api/v2"
+
+	resp := do(t, http.MethodPost, base+"/admin/services", `{"name":" Netflix ","aliases":["netflix.com","NFLX","nflx"],"category":"video","default_price":400}`)
+	var created models.CatalogEntry
+	if err := json.NewDecoder(resp.Body).Decode(&created); err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusCreated || resp.Header.Get("Location") != "/api/v2/services/1" {
+		t.Fatalf("create: status %d, location %q", resp.StatusCode, resp.Header.Get("Location"))
+	}
+	if created.Name != "Netflix" || len(created.Aliases) != 2 {
+		t.Fatalf("create: unexpected entry %+v", created)
+	}
+
+	// Имя другой записи не может совпадать с псевдонимом
+	if resp = do(t, http.MethodPost, base+"/admin/services", `{"name":"nflx"}`); resp.StatusCode != http.StatusConflict {
+		t.Fatalf("conflicting name: status %d, want 409", resp.StatusCode)
+	}
+
+	resp = do(t, http.MethodPost, base+"/subscriptions", `{"service_name":"  NFLX ","price":400,"user_id":"`+userId+`","start_date":"2025-07","end_date":"2025-09"}`)
+	var sub models.SubscriptionV2
+	if err := json.NewDecoder(resp.Body).Decode(&sub); err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusCreated || sub.ServiceName != "Netflix" {
+		t.Fatalf("create subscription: status %d, %+v", resp.StatusCode, sub)
+	}
+
+	resp = do(t, http.MethodGet, base+"/subscriptions/summary?service=netflix.com&from=2025-01&to=2025-12", "")
+	var sum models.SubscriptionSumV2
+	if err := json.NewDecoder(resp.Body).Decode(&sum); err != nil {
+		t.Fatal(err)
+	}
+	if len(sum.Items) != 1 || sum.Total != 400 {
+		t.Fatalf("sum by alias: unexpected response %+v", sum)
+	}
 
-}
\ No newline at end of file
+	if resp = do(t, http.MethodPut, base+"/admin/services/1", `{"name":"Netflix","aliases":["nflx"],"logo_url":"ftp://logo"}`); resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("invalid logo_url: status %d", resp.StatusCode)
+	}
+	if resp = do(t, http.MethodDelete, base+"/admin/services/1", ""); resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("delete: status %d", resp.StatusCode)
+	}
+	if resp = do(t, http.MethodGet, base+"/services/1", ""); resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("read after delete: status %d", resp.StatusCode)
+	}
+}
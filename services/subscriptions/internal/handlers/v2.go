@@ -0,0 +1,535 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"subscriptions/internal/models"
+	"subscriptions/internal/service"
+	"subscriptions/internal/storage"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Обработчики API v2. Отличия от v1: даты в формате YYYY-MM, ошибки в JSON с кодом,
+// создание отвечает 201 с Location и созданной записью, отсутствующая запись — 404
+
+const (
+	codeInvalidRequest = "invalid_request"
+	codeUnauthorized   = "unauthorized"
+	codeNotFound       = "not_found"
+	codeConflict       = "conflict"
+	codeInternal       = "internal_error"
+
+	v2Prefix = "/api/v2/subscriptions/"
+
+	maxAnalyticsMonths    = 120 // Предел длины ряда в аналитике, чтобы один запрос не строил ряд на века
+	defaultForecastMonths = 12
+)
+
+// Проверка адреса вебхука при регистрации
+
+type URLChecker interface {
+	CheckURL(ctx context.Context, rawURL string) error
+}
+
+type HandlersV2 struct {
+	w    WorkerPool
+	urls URLChecker
+}
+
+func NewHandlerV2(a WorkerPool, urls URLChecker) *HandlersV2 {
+	return &HandlersV2{
+		w:    a,
+		urls: urls,
+	}
+}
+
+func writeErrorV2(w http.ResponseWriter, statusCode int, code string, message string) {
+	w.Header().Set("Content-type", "application/json")
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(models.ErrorV2{Error: models.ErrorBodyV2{Code: code, Message: message}})
+}
+
+// Ошибка воркера: отсутствие записи — 404, повтор бюджета или имени в каталоге — 409, остальное — 500 без подробностей
+
+func writeServiceErrorV2(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, service.ErrNotFound):
+		writeErrorV2(w, http.StatusNotFound, codeNotFound, "subscription not found")
+	case errors.Is(err, service.ErrBudgetNotFound):
+		writeErrorV2(w, http.StatusNotFound, codeNotFound, "budget not found")
+	case errors.Is(err, service.ErrWebhookNotFound):
+		writeErrorV2(w, http.StatusNotFound, codeNotFound, "webhook not found")
+	case errors.Is(err, service.ErrCatalogEntryNotFound):
+		writeErrorV2(w, http.StatusNotFound, codeNotFound, "catalog entry not found")
+	case errors.Is(err, service.ErrBudgetExists), errors.Is(err, service.ErrCatalogConflict):
+		writeErrorV2(w, http.StatusConflict, codeConflict, err.Error())
+	default:
+		writeErrorV2(w, http.StatusInternalServerError, codeInternal, "internal error")
+	}
+}
+
+// Дата из запроса приводится к YYYY-MM-DD, который одинаково понимают все хранилища
+
+func normalizeDate(date string) (string, error) {
+	t, err := storage.ParseDate(date)
+	if err != nil {
+		return "", err
+	}
+	return t.Format(time.DateOnly), nil
+}
+
+// Хранилища отдают даты как MM-YYYY, в v2 они выводятся как YYYY-MM
+
+func formatDateV2(date string) string {
+	t, err := storage.ParseDate(date)
+	if err != nil {
+		return date
+	}
+	return t.Format("2006-01")
+}
+
+func toV2(sub models.Subscription) models.SubscriptionV2 {
+	return models.SubscriptionV2{
+		Id:          sub.Id,
+		ServiceName: sub.ServiceName,
+		Price:       sub.Price,
+		UserId:      sub.UserId,
+		StartDate:   formatDateV2(sub.StartDate),
+		EndDate:     formatDateV2(sub.EndDate),
+	}
+}
+
+func toV2List(subs []models.Subscription) []models.SubscriptionV2 {
+	items := make([]models.SubscriptionV2, 0, len(subs))
+	for _, sub := range subs {
+		items = append(items, toV2(sub))
+	}
+	return items
+}
+
+// Разбор и проверка тела запроса на создание и обновление. Текст ошибки уходит клиенту
+
+func decodeSubV2(r *http.Request) (models.Subscription, error) {
+	var in models.SubscriptionV2
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		return models.Subscription{}, fmt.Errorf("request body is not valid JSON")
+	}
+
+	sub := models.Subscription{ServiceName: in.ServiceName, Price: in.Price, UserId: in.UserId}
+
+	if sub.ServiceName == "" {
+		return sub, fmt.Errorf("service_name is required")
+	}
+	if _, err := uuid.Parse(sub.UserId); err != nil {
+		return sub, fmt.Errorf("user_id must be a UUID")
+	}
+	if sub.Price < 0 {
+		return sub, fmt.Errorf("price must not be negative")
+	}
+
+	var err error
+	if sub.StartDate, err = normalizeDate(in.StartDate); err != nil {
+		return sub, fmt.Errorf("start_date must be YYYY-MM or YYYY-MM-DD")
+	}
+	if in.EndDate != "" {
+		if sub.EndDate, err = normalizeDate(in.EndDate); err != nil {
+			return sub, fmt.Errorf("end_date must be YYYY-MM or YYYY-MM-DD")
+		}
+		if sub.EndDate < sub.StartDate {
+			return sub, fmt.Errorf("end_date must not be before start_date")
+		}
+	}
+
+	return sub, nil
+}
+
+// CreateSub godoc
+// @Summary     Создать подписку
+// @Description Создаёт подписку и возвращает её с присвоенным id, адрес записи — в заголовке Location.
+// @Tags        subscriptions v2
+// @Accept      json
+// @Produce     json
+// @Param       subscription  body     models.SubscriptionV2 true "Данные новой подписки, id игнорируется"
+// @Success     201           {object} models.SubscriptionV2
+// @Failure     400           {object} models.ErrorV2
+// @Failure     500           {object} models.ErrorV2
+// @Router      /api/v2/subscriptions [post]
+func (h *HandlersV2) CreateSub(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	sub, err := decodeSubV2(r)
+	if err != nil {
+		writeErrorV2(w, http.StatusBadRequest, codeInvalidRequest, err.Error())
+		slog.WarnContext(ctx, "CreateSubV2: invalid subscription", "error", err)
+		return
+	}
+
+	// Имя сервиса в созданной записи могло замениться именем из каталога
+	created, err := h.w.AsyncCreateSub(ctx, sub)
+	if err != nil {
+		writeServiceErrorV2(w, err)
+		slog.ErrorContext(ctx, "CreateSubV2: error during AsyncCreateSub request", "error", err)
+		return
+	}
+
+	slog.InfoContext(ctx, "CreateSubV2: subscription record created", "id", created.Id, "service_name", created.ServiceName)
+
+	w.Header().Set("Location", v2Prefix+strconv.Itoa(created.Id))
+
+	err = writeJSON(w, http.StatusCreated, toV2(*created))
+	if err != nil {
+		slog.ErrorContext(ctx, "CreateSubV2: error during writeJSON", "error", err)
+	}
+}
+
+// ReadSub godoc
+// @Summary     Получить подписку по ID
+// @Tags        subscriptions v2
+// @Produce     json
+// @Param       id   path     int true "Subscription ID"
+// @Success     200  {object} models.SubscriptionV2
+// @Failure     400  {object} models.ErrorV2
+// @Failure     404  {object} models.ErrorV2
+// @Failure     500  {object} models.ErrorV2
+// @Router      /api/v2/subscriptions/{id} [get]
+func (h *HandlersV2) ReadSub(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	id, err := getSubId(w, r)
+	if err != nil {
+		writeErrorV2(w, http.StatusBadRequest, codeInvalidRequest, "id must be a number")
+		return
+	}
+
+	sub, err := h.w.AsyncReadSub(ctx, models.Subscription{Id: id})
+	if err != nil {
+		writeServiceErrorV2(w, err)
+		slog.WarnContext(ctx, "ReadSubV2: error during AsyncReadSub request", "id", id, "error", err)
+		return
+	}
+
+	err = writeJSON(w, http.StatusOK, toV2(*sub))
+	if err != nil {
+		slog.ErrorContext(ctx, "ReadSubV2: error during writeJSON", "error", err)
+	}
+}
+
+// UpdateSub godoc
+// @Summary     Обновить подписку
+// @Tags        subscriptions v2
+// @Accept      json
+// @Produce     json
+// @Param       id            path     int                   true "Subscription ID"
+// @Param       subscription  body     models.SubscriptionV2 true "Новые данные подписки, id берётся из пути"
+// @Success     200           {object} models.SubscriptionV2
+// @Failure     400           {object} models.ErrorV2
+// @Failure     404           {object} models.ErrorV2
+// @Failure     500           {object} models.ErrorV2
+// @Router      /api/v2/subscriptions/{id} [put]
+func (h *HandlersV2) UpdateSub(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	id, err := getSubId(w, r)
+	if err != nil {
+		writeErrorV2(w, http.StatusBadRequest, codeInvalidRequest, "id must be a number")
+		return
+	}
+
+	sub, err := decodeSubV2(r)
+	if err != nil {
+		writeErrorV2(w, http.StatusBadRequest, codeInvalidRequest, err.Error())
+		slog.WarnContext(ctx, "UpdateSubV2: invalid subscription", "error", err)
+		return
+	}
+	sub.Id = id
+
+	updated, err := h.w.AsyncUpdateSub(ctx, sub)
+	if err != nil {
+		writeServiceErrorV2(w, err)
+		slog.ErrorContext(ctx, "UpdateSubV2: error during AsyncUpdateSub request", "id", id, "error", err)
+		return
+	}
+
+	slog.InfoContext(ctx, "UpdateSubV2: subscription record updated", "id", id)
+
+	err = writeJSON(w, http.StatusOK, toV2(*updated))
+	if err != nil {
+		slog.ErrorContext(ctx, "UpdateSubV2: error during writeJSON", "error", err)
+	}
+}
+
+// DeleteSub godoc
+// @Summary     Удалить подписку
+// @Tags        subscriptions v2
+// @Param       id   path int true "Subscription ID"
+// @Success     204
+// @Failure     400  {object} models.ErrorV2
+// @Failure     404  {object} models.ErrorV2
+// @Failure     500  {object} models.ErrorV2
+// @Router      /api/v2/subscriptions/{id} [delete]
+func (h *HandlersV2) DeleteSub(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	id, err := getSubId(w, r)
+	if err != nil {
+		writeErrorV2(w, http.StatusBadRequest, codeInvalidRequest, "id must be a number")
+		return
+	}
+
+	err = h.w.AsyncDeleteSub(ctx, models.Subscription{Id: id})
+	if err != nil {
+		writeServiceErrorV2(w, err)
+		slog.ErrorContext(ctx, "DeleteSubV2: error during AsyncDeleteSub request", "id", id, "error", err)
+		return
+	}
+
+	slog.InfoContext(ctx, "DeleteSubV2: subscription record deleted", "id", id)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ReadSubs godoc
+// @Summary     Получить все подписки пользователя
+// @Description UUID пользователя берётся из заголовка Authorization. Если подписок нет, возвращается пустой список.
+// @Tags        subscriptions v2
+// @Produce     json
+// @Param       Authorization header string true "User UUID"
+// @Success     200 {array}  models.SubscriptionV2
+// @Failure     401 {object} models.ErrorV2
+// @Failure     500 {object} models.ErrorV2
+// @Router      /api/v2/subscriptions [get]
+func (h *HandlersV2) ReadSubs(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	userId, err := getUserUuid(r)
+	if err != nil {
+		writeErrorV2(w, http.StatusUnauthorized, codeUnauthorized, "Authorization header must contain user UUID")
+		return
+	}
+
+	subs, err := h.w.AsyncReadSubs(ctx, models.Subscription{UserId: userId})
+	if err != nil {
+		writeServiceErrorV2(w, err)
+		slog.ErrorContext(ctx, "ReadSubsV2: error during AsyncReadSubs request", "error", err)
+		return
+	}
+
+	err = writeJSON(w, http.StatusOK, toV2List(subs))
+	if err != nil {
+		slog.ErrorContext(ctx, "ReadSubsV2: error during writeJSON", "error", err)
+	}
+}
+
+// Summary godoc
+// @Summary     Получить подписки и их сумму за период
+// @Description Период from и to в формате YYYY-MM или YYYY-MM-DD, user UUID — в заголовке Authorization. Без service суммируются все сервисы пользователя.
+// @Tags        subscriptions v2
+// @Produce     json
+// @Param       Authorization header string true  "User UUID"
+// @Param       service       query  string false "Service name (например, Netflix)"
+// @Param       from          query  string true  "Начало периода, например 2025-01"
+// @Param       to            query  string true  "Конец периода, например 2025-12"
+// @Success     200           {object} models.SubscriptionSumV2
+// @Failure     400           {object} models.ErrorV2
+// @Failure     401           {object} models.ErrorV2
+// @Failure     500           {object} models.ErrorV2
+// @Router      /api/v2/subscriptions/summary [get]
+func (h *HandlersV2) Summary(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	h.writeSum(w, r, query.Get("service"), query.Get("from"), query.Get("to"))
+}
+
+// ShowSubscSum godoc
+// @Summary     Получить подписки и их сумму по сервису за период
+// @Description Устаревший вариант GET /api/v2/subscriptions/summary. Период (start_date и end_date в формате YYYY-MM или YYYY-MM-DD) передаётся в теле, user UUID — в заголовке Authorization.
+// @Tags        subscriptions v2
+// @Deprecated
+// @Accept      json
+// @Produce     json
+// @Param       Authorization header string              true "User UUID"
+// @Param       service       path   string              true "Service name (например, Netflix)"
+// @Param       period        body   models.ShowSubscSum true "Период"
+// @Success     200           {object} models.SubscriptionSumV2
+// @Failure     400           {object} models.ErrorV2
+// @Failure     401           {object} models.ErrorV2
+// @Failure     500           {object} models.ErrorV2
+// @Router      /api/v2/subscriptions/sum/{service} [post]
+func (h *HandlersV2) ShowSubscSum(w http.ResponseWriter, r *http.Request) {
+	serviceName, err := getService(r)
+	if err != nil {
+		writeErrorV2(w, http.StatusBadRequest, codeInvalidRequest, "service name is required")
+		return
+	}
+
+	var periods models.ShowSubscSum
+
+	if err := json.NewDecoder(r.Body).Decode(&periods); err != nil {
+		writeErrorV2(w, http.StatusBadRequest, codeInvalidRequest, "request body is not valid JSON")
+		return
+	}
+
+	h.writeSum(w, r, serviceName, periods.StartDate, periods.EndDate)
+}
+
+// Общая часть Summary и ShowSubscSum: проверка периода, запрос суммы и ответ
+
+func (h *HandlersV2) writeSum(w http.ResponseWriter, r *http.Request, serviceName string, start string, end string) {
+	ctx := r.Context()
+
+	userId, err := getUserUuid(r)
+	if err != nil {
+		writeErrorV2(w, http.StatusUnauthorized, codeUnauthorized, "Authorization header must contain user UUID")
+		return
+	}
+
+	from, err := normalizeDate(start)
+	if err != nil {
+		writeErrorV2(w, http.StatusBadRequest, codeInvalidRequest, "period start must be YYYY-MM or YYYY-MM-DD")
+		return
+	}
+
+	to, err := normalizeDate(end)
+	if err != nil {
+		writeErrorV2(w, http.StatusBadRequest, codeInvalidRequest, "period end must be YYYY-MM or YYYY-MM-DD")
+		return
+	}
+
+	if to < from {
+		writeErrorV2(w, http.StatusBadRequest, codeInvalidRequest, "period end must not be before period start")
+		return
+	}
+
+	sum, err := h.w.AsyncShowSubscSum(ctx, models.Subscription{ServiceName: serviceName, UserId: userId, StartDate: from, EndDate: to})
+	if err != nil {
+		writeServiceErrorV2(w, err)
+		slog.ErrorContext(ctx, "ShowSubscSumV2: error during AsyncShowSubscSum request", "service_name", serviceName, "error", err)
+		return
+	}
+
+	err = writeJSON(w, http.StatusOK, models.SubscriptionSumV2{Items: toV2List(sum.Items), Total: sum.Total})
+	if err != nil {
+		slog.ErrorContext(ctx, "ShowSubscSumV2: error during writeJSON", "error", err)
+	}
+}
+
+// Период аналитики: оба конца обязательны, конец не раньше начала, длина не больше maxAnalyticsMonths.
+// Текст ошибки уходит клиенту
+
+func analyticsPeriod(r *http.Request) (string, string, error) {
+	query := r.URL.Query()
+
+	from, err := storage.ParseDate(query.Get("from"))
+	if err != nil {
+		return "", "", fmt.Errorf("from must be YYYY-MM or YYYY-MM-DD")
+	}
+
+	to, err := storage.ParseDate(query.Get("to"))
+	if err != nil {
+		return "", "", fmt.Errorf("to must be YYYY-MM or YYYY-MM-DD")
+	}
+
+	months := (to.Year()-from.Year())*12 + int(to.Month()-from.Month()) + 1
+	if months < 1 {
+		return "", "", fmt.Errorf("to must not be before from")
+	}
+	if months > maxAnalyticsMonths {
+		return "", "", fmt.Errorf("period must not exceed %d months", maxAnalyticsMonths)
+	}
+
+	return from.Format(time.DateOnly), to.Format(time.DateOnly), nil
+}
+
+// MonthlySpending godoc
+// @Summary     Помесячные расходы пользователя
+// @Description Для каждого месяца периода — сумма подписок, действующих в этом месяце, и разбивка по сервисам. Месяцы без подписок возвращаются с нулевой суммой.
+// @Tags        analytics v2
+// @Produce     json
+// @Param       Authorization header string true "User UUID"
+// @Param       from          query  string true "Первый месяц, например 2025-01"
+// @Param       to            query  string true "Последний месяц, например 2025-12"
+// @Success     200           {array}  models.MonthlySpending
+// @Failure     400           {object} models.ErrorV2
+// @Failure     401           {object} models.ErrorV2
+// @Failure     500           {object} models.ErrorV2
+// @Router      /api/v2/subscriptions/analytics/monthly [get]
+func (h *HandlersV2) MonthlySpending(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	userId, err := getUserUuid(r)
+	if err != nil {
+		writeErrorV2(w, http.StatusUnauthorized, codeUnauthorized, "Authorization header must contain user UUID")
+		return
+	}
+
+	from, to, err := analyticsPeriod(r)
+	if err != nil {
+		writeErrorV2(w, http.StatusBadRequest, codeInvalidRequest, err.Error())
+		return
+	}
+
+	months, err := h.w.AsyncMonthlySpending(ctx, models.Subscription{UserId: userId, StartDate: from, EndDate: to})
+	if err != nil {
+		writeServiceErrorV2(w, err)
+		slog.ErrorContext(ctx, "MonthlySpendingV2: error during AsyncMonthlySpending request", "error", err)
+		return
+	}
+
+	err = writeJSON(w, http.StatusOK, months)
+	if err != nil {
+		slog.ErrorContext(ctx, "MonthlySpendingV2: error during writeJSON", "error", err)
+	}
+}
+
+// Forecast godoc
+// @Summary     Прогноз расходов на ближайшие месяцы
+// @Description Прогноз начинается с текущего месяца и учитывает бессрочные подписки и подписки, которые ещё не закончились. Подписка перестаёт учитываться после месяца окончания и указывается в expiring этого месяца. В отличие от суммы за период, цена учитывается за каждый месяц действия подписки, включая бессрочные.
+// @Tags        analytics v2
+// @Produce     json
+// @Param       Authorization header string true  "User UUID"
+// @Param       months        query  int    false "Число месяцев, по умолчанию 12, не больше 120"
+// @Success     200           {object} models.Forecast
+// @Failure     400           {object} models.ErrorV2
+// @Failure     401           {object} models.ErrorV2
+// @Failure     500           {object} models.ErrorV2
+// @Router      /api/v2/subscriptions/forecast [get]
+func (h *HandlersV2) Forecast(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	userId, err := getUserUuid(r)
+	if err != nil {
+		writeErrorV2(w, http.StatusUnauthorized, codeUnauthorized, "Authorization header must contain user UUID")
+		return
+	}
+
+	months := defaultForecastMonths
+	if value := r.URL.Query().Get("months"); value != "" {
+		months, err = strconv.Atoi(value)
+		if err != nil || months < 1 || months > maxAnalyticsMonths {
+			writeErrorV2(w, http.StatusBadRequest, codeInvalidRequest, fmt.Sprintf("months must be a number from 1 to %d", maxAnalyticsMonths))
+			return
+		}
+	}
+
+	now := time.Now().UTC()
+	from := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, months-1, 0)
+
+	forecast, err := h.w.AsyncForecast(ctx, models.Subscription{UserId: userId, StartDate: from.Format(time.DateOnly), EndDate: to.Format(time.DateOnly)})
+	if err != nil {
+		writeServiceErrorV2(w, err)
+		slog.ErrorContext(ctx, "ForecastV2: error during AsyncForecast request", "error", err)
+		return
+	}
+
+	err = writeJSON(w, http.StatusOK, forecast)
+	if err != nil {
+		slog.ErrorContext(ctx, "ForecastV2: error during writeJSON", "error", err)
+	}
+}
@@ -0,0 +1,236 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"subscriptions/internal/models"
+	"subscriptions/internal/service"
+	"subscriptions/internal/webhooks"
+)
+
+// Вебхуки есть только в v2. На зарегистрированный адрес приходят события subscription.created, subscription.updated
+// и subscription.deleted по подпискам пользователя, подписанные секретом из ответа на регистрацию
+
+const webhooksPrefix = "/api/v2/webhooks/"
+
+// Тело запроса на регистрацию вебхука
+
+type webhookRequest struct {
+	URL string `json:"url" example:"https://billing.example.com/hooks/subscriptions"`
+}
+
+// Вебхук по id из пути, если он принадлежит пользователю из Authorization. Ответ с ошибкой уже записан, если ok false
+
+func (h *HandlersV2) ownWebhook(w http.ResponseWriter, r *http.Request) (*models.Webhook, bool) {
+	userId, err := getUserUuid(r)
+	if err != nil {
+		writeErrorV2(w, http.StatusUnauthorized, codeUnauthorized, "Authorization header must contain user UUID")
+		return nil, false
+	}
+
+	id, err := getSubId(w, r)
+	if err != nil {
+		writeErrorV2(w, http.StatusBadRequest, codeInvalidRequest, "id must be a number")
+		return nil, false
+	}
+
+	wh, err := h.w.AsyncReadWebhook(r.Context(), models.Webhook{Id: id})
+	if err == nil && wh.UserId != userId {
+		err = service.ErrWebhookNotFound
+	}
+	if err != nil {
+		writeServiceErrorV2(w, err)
+		return nil, false
+	}
+
+	return wh, true
+}
+
+// CreateWebhook godoc
+// @Summary     Зарегистрировать вебхук
+// @Description События по подпискам пользователя отправляются POST запросом с JSON телом. Заголовок X-Webhook-Signature содержит sha256=HMAC-SHA256 от "<X-Webhook-Timestamp>.<тело>" с секретом вебхука. Секрет возвращается только в этом ответе. Неудачные доставки повторяются с экспоненциальной паузой. Адрес не может указывать на loopback, частные и link-local сети, редиректы не выполняются.
+// @Tags        webhooks v2
+// @Accept      json
+// @Produce     json
+// @Param       Authorization header string         true "User UUID"
+// @Param       webhook       body   webhookRequest true "Адрес вебхука"
+// @Success     201           {object} models.Webhook
+// @Failure     400           {object} models.ErrorV2
+// @Failure     401           {object} models.ErrorV2
+// @Failure     500           {object} models.ErrorV2
+// @Router      /api/v2/webhooks [post]
+func (h *HandlersV2) CreateWebhook(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	userId, err := getUserUuid(r)
+	if err != nil {
+		writeErrorV2(w, http.StatusUnauthorized, codeUnauthorized, "Authorization header must contain user UUID")
+		return
+	}
+
+	var in webhookRequest
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		writeErrorV2(w, http.StatusBadRequest, codeInvalidRequest, "request body is not valid JSON")
+		return
+	}
+	if err := h.urls.CheckURL(ctx, in.URL); err != nil {
+		switch {
+		case errors.Is(err, webhooks.ErrInvalidURL):
+			writeErrorV2(w, http.StatusBadRequest, codeInvalidRequest, "url must be an absolute http or https URL")
+		case errors.Is(err, webhooks.ErrForbiddenAddress):
+			writeErrorV2(w, http.StatusBadRequest, codeInvalidRequest, "url must not point to a loopback, private or link-local address")
+		default:
+			writeErrorV2(w, http.StatusBadRequest, codeInvalidRequest, "url host cannot be resolved")
+		}
+		slog.WarnContext(ctx, "CreateWebhookV2: error during url check", "error", err)
+		return
+	}
+
+	wh, err := h.w.AsyncCreateWebhook(ctx, models.Webhook{UserId: userId, URL: in.URL})
+	if err != nil {
+		writeServiceErrorV2(w, err)
+		slog.ErrorContext(ctx, "CreateWebhookV2: error during AsyncCreateWebhook request", "error", err)
+		return
+	}
+
+	slog.InfoContext(ctx, "CreateWebhookV2: webhook record created", "id", wh.Id)
+
+	w.Header().Set("Location", webhooksPrefix+strconv.Itoa(wh.Id))
+
+	err = writeJSON(w, http.StatusCreated, wh)
+	if err != nil {
+		slog.ErrorContext(ctx, "CreateWebhookV2: error during writeJSON", "error", err)
+	}
+}
+
+// ReadWebhook godoc
+// @Summary     Получить вебхук по ID
+// @Tags        webhooks v2
+// @Produce     json
+// @Param       Authorization header string true "User UUID"
+// @Param       id            path   int    true "Webhook ID"
+// @Success     200           {object} models.Webhook
+// @Failure     400           {object} models.ErrorV2
+// @Failure     401           {object} models.ErrorV2
+// @Failure     404           {object} models.ErrorV2
+// @Failure     500           {object} models.ErrorV2
+// @Router      /api/v2/webhooks/{id} [get]
+func (h *HandlersV2) ReadWebhook(w http.ResponseWriter, r *http.Request) {
+	wh, ok := h.ownWebhook(w, r)
+	if !ok {
+		return
+	}
+
+	err := writeJSON(w, http.StatusOK, wh)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "ReadWebhookV2: error during writeJSON", "error", err)
+	}
+}
+
+// ReadWebhooks godoc
+// @Summary     Получить все вебхуки пользователя
+// @Tags        webhooks v2
+// @Produce     json
+// @Param       Authorization header string true "User UUID"
+// @Success     200           {array}  models.Webhook
+// @Failure     401           {object} models.ErrorV2
+// @Failure     500           {object} models.ErrorV2
+// @Router      /api/v2/webhooks [get]
+func (h *HandlersV2) ReadWebhooks(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	userId, err := getUserUuid(r)
+	if err != nil {
+		writeErrorV2(w, http.StatusUnauthorized, codeUnauthorized, "Authorization header must contain user UUID")
+		return
+	}
+
+	webhooks, err := h.w.AsyncReadWebhooks(ctx, models.Webhook{UserId: userId})
+	if err != nil {
+		writeServiceErrorV2(w, err)
+		slog.ErrorContext(ctx, "ReadWebhooksV2: error during AsyncReadWebhooks request", "error", err)
+		return
+	}
+
+	if webhooks == nil {
+		webhooks = []models.Webhook{}
+	}
+
+	err = writeJSON(w, http.StatusOK, webhooks)
+	if err != nil {
+		slog.ErrorContext(ctx, "ReadWebhooksV2: error during writeJSON", "error", err)
+	}
+}
+
+// DeleteWebhook godoc
+// @Summary     Удалить вебхук
+// @Description Вместе с вебхуком удаляется журнал его доставок, недоставленные события больше не отправляются.
+// @Tags        webhooks v2
+// @Param       Authorization header string true "User UUID"
+// @Param       id            path   int    true "Webhook ID"
+// @Success     204
+// @Failure     400           {object} models.ErrorV2
+// @Failure     401           {object} models.ErrorV2
+// @Failure     404           {object} models.ErrorV2
+// @Failure     500           {object} models.ErrorV2
+// @Router      /api/v2/webhooks/{id} [delete]
+func (h *HandlersV2) DeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	wh, ok := h.ownWebhook(w, r)
+	if !ok {
+		return
+	}
+
+	err := h.w.AsyncDeleteWebhook(ctx, *wh)
+	if err != nil {
+		writeServiceErrorV2(w, err)
+		slog.ErrorContext(ctx, "DeleteWebhookV2: error during AsyncDeleteWebhook request", "id", wh.Id, "error", err)
+		return
+	}
+
+	slog.InfoContext(ctx, "DeleteWebhookV2: webhook record deleted", "id", wh.Id)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ReadDeliveries godoc
+// @Summary     Журнал доставок вебхука
+// @Description Последние 100 доставок, сначала новые. У ожидающих повтора указано время следующей попытки.
+// @Tags        webhooks v2
+// @Produce     json
+// @Param       Authorization header string true "User UUID"
+// @Param       id            path   int    true "Webhook ID"
+// @Success     200           {array}  models.WebhookDelivery
+// @Failure     400           {object} models.ErrorV2
+// @Failure     401           {object} models.ErrorV2
+// @Failure     404           {object} models.ErrorV2
+// @Failure     500           {object} models.ErrorV2
+// @Router      /api/v2/webhooks/{id}/deliveries [get]
+func (h *HandlersV2) ReadDeliveries(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	wh, ok := h.ownWebhook(w, r)
+	if !ok {
+		return
+	}
+
+	deliveries, err := h.w.AsyncReadDeliveries(ctx, *wh)
+	if err != nil {
+		writeServiceErrorV2(w, err)
+		slog.ErrorContext(ctx, "ReadDeliveriesV2: error during AsyncReadDeliveries request", "id", wh.Id, "error", err)
+		return
+	}
+
+	if deliveries == nil {
+		deliveries = []models.WebhookDelivery{}
+	}
+
+	err = writeJSON(w, http.StatusOK, deliveries)
+	if err != nil {
+		slog.ErrorContext(ctx, "ReadDeliveriesV2: error during writeJSON", "error", err)
+	}
+}
@@ -0,0 +1,141 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	statusOK   = "ok"
+	statusFail = "fail"
+)
+
+// Проверка готовности. Возвращает ошибку, если зависимость недоступна
+
+type Check func(ctx context.Context) error
+
+type CheckResult struct {
+	Status   string `json:"status"`
+	Error    string `json:"error,omitempty"`
+	Duration string `json:"duration"`
+}
+
+type Report struct {
+	Status string                 `json:"status"`
+	Checks map[string]CheckResult `json:"checks,omitempty"`
+}
+
+type namedCheck struct {
+	name  string
+	check Check
+}
+
+type Checker struct {
+	timeout      time.Duration
+	mu           sync.RWMutex
+	checks       []namedCheck
+	shuttingDown atomic.Bool
+}
+
+func NewChecker(timeout time.Duration) *Checker {
+	return &Checker{timeout: timeout}
+}
+
+func (c *Checker) AddReadinessCheck(name string, check Check) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.checks = append(c.checks, namedCheck{name: name, check: check})
+}
+
+// После вызова /readyz всегда отвечает 503, чтобы балансировщик перестал слать трафик
+
+func (c *Checker) SetShuttingDown() {
+	c.shuttingDown.Store(true)
+}
+
+// Liveness godoc
+// @Summary     Проверка жизни процесса
+// @Tags        health
+// @Produce     json
+// @Success     200 {object} health.Report
+// @Router      /healthz [get]
+func (c *Checker) Liveness(w http.ResponseWriter, r *http.Request) {
+	writeReport(w, http.StatusOK, Report{Status: statusOK})
+}
+
+// Readiness godoc
+// @Summary     Проверка готовности принимать трафик
+// @Description Проверяет базу данных, применённые миграции и загрузку пула воркеров.
+// @Tags        health
+// @Produce     json
+// @Success     200 {object} health.Report
+// @Failure     503 {object} health.Report
+// @Router      /readyz [get]
+func (c *Checker) Readiness(w http.ResponseWriter, r *http.Request) {
+	report := c.Run(r.Context())
+
+	status := http.StatusOK
+	if report.Status != statusOK {
+		status = http.StatusServiceUnavailable
+	}
+
+	writeReport(w, status, report)
+}
+
+// Выполняет все проверки параллельно с общим таймаутом
+
+func (c *Checker) Run(ctx context.Context) Report {
+	c.mu.RLock()
+	checks := append([]namedCheck(nil), c.checks...)
+	c.mu.RUnlock()
+
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	report := Report{Status: statusOK, Checks: make(map[string]CheckResult, len(checks)+1)}
+
+	if c.shuttingDown.Load() {
+		report.Status = statusFail
+		report.Checks["shutdown"] = CheckResult{Status: statusFail, Error: "server is shutting down", Duration: "0s"}
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+
+	for _, nc := range checks {
+		wg.Add(1)
+		go func(nc namedCheck) {
+			defer wg.Done()
+
+			start := time.Now()
+			err := nc.check(ctx)
+			res := CheckResult{Status: statusOK, Duration: time.Since(start).String()}
+			if err != nil {
+				res.Status = statusFail
+				res.Error = err.Error()
+			}
+
+			mu.Lock()
+			report.Checks[nc.name] = res
+			if err != nil {
+				report.Status = statusFail
+			}
+			mu.Unlock()
+		}(nc)
+	}
+
+	wg.Wait()
+
+	return report
+}
+
+func writeReport(w http.ResponseWriter, status int, report Report) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(report)
+}
@@ -0,0 +1,89 @@
+package health_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"subscriptions/internal/health"
+	"testing"
+	"time"
+)
+
+func readiness(t *testing.T, c *health.Checker) (int, health.Report) {
+	t.Helper()
+
+	rec := httptest.NewRecorder()
+	c.Readiness(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+	var report health.Report
+	if err := json.NewDecoder(rec.Body).Decode(&report); err != nil {
+		t.Fatal(err)
+	}
+	return rec.Code, report
+}
+
+func TestLiveness(t *testing.T) {
+	c := health.NewChecker(time.Second)
+	c.AddReadinessCheck("database", func(ctx context.Context) error { return errors.New("down") })
+
+	rec := httptest.NewRecorder()
+	c.Liveness(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+
+	if rec.Code != http.StatusOK || rec.Header().Get("Cache-Control") != "no-store" {
+		t.Fatalf("liveness must not depend on readiness checks: status %d, headers %v", rec.Code, rec.Header())
+	}
+}
+
+func TestReadiness(t *testing.T) {
+	c := health.NewChecker(time.Second)
+	c.AddReadinessCheck("database", func(ctx context.Context) error { return nil })
+	c.AddReadinessCheck("migrations", func(ctx context.Context) error { return nil })
+
+	status, report := readiness(t, c)
+	if status != http.StatusOK || report.Status != "ok" || len(report.Checks) != 2 {
+		t.Fatalf("status %d, report %+v", status, report)
+	}
+
+	c.AddReadinessCheck("workers", func(ctx context.Context) error { return errors.New("queue is full") })
+
+	status, report = readiness(t, c)
+	if status != http.StatusServiceUnavailable || report.Status != "fail" {
+		t.Fatalf("status %d, report %+v", status, report)
+	}
+	if res := report.Checks["workers"]; res.Status != "fail" || res.Error != "queue is full" {
+		t.Fatalf("workers check %+v", res)
+	}
+	if res := report.Checks["database"]; res.Status != "ok" || res.Error != "" {
+		t.Fatalf("database check %+v", res)
+	}
+}
+
+func TestReadinessTimeout(t *testing.T) {
+	c := health.NewChecker(20 * time.Millisecond)
+	c.AddReadinessCheck("database", func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+
+	start := time.Now()
+	status, report := readiness(t, c)
+	if status != http.StatusServiceUnavailable || report.Checks["database"].Error != context.DeadlineExceeded.Error() {
+		t.Fatalf("status %d, report %+v", status, report)
+	}
+	if time.Since(start) > time.Second {
+		t.Fatal("check must be cancelled by the checker timeout")
+	}
+}
+
+func TestReadinessShuttingDown(t *testing.T) {
+	c := health.NewChecker(time.Second)
+	c.AddReadinessCheck("database", func(ctx context.Context) error { return nil })
+	c.SetShuttingDown()
+
+	status, report := readiness(t, c)
+	if status != http.StatusServiceUnavailable || report.Checks["shutdown"].Status != "fail" || report.Checks["database"].Status != "ok" {
+		t.Fatalf("status %d, report %+v", status, report)
+	}
+}
@@ -0,0 +1,11 @@
+package logger
+
+import (
+	"io"
+	"log/slog"
+)
+
+func NewWithWriter(w io.Writer, level string) *slog.Logger {
+	handler := slog.NewJSONHandler(w, &slog.HandlerOptions{Level: ParseLevel(level)})
+	return slog.New(&contextHandler{Handler: handler})
+}
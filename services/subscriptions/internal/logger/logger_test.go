@@ -0,0 +1,84 @@
+package logger_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"subscriptions/internal/logger"
+	"testing"
+)
+
+func TestParseLevel(t *testing.T) {
+	cases := map[string]slog.Level{
+		"debug":   slog.LevelDebug,
+		" WARN ":  slog.LevelWarn,
+		"warning": slog.LevelWarn,
+		"error":   slog.LevelError,
+		"info":    slog.LevelInfo,
+		"verbose": slog.LevelInfo,
+		"":        slog.LevelInfo,
+	}
+
+	for level, want := range cases {
+		if got := logger.ParseLevel(level); got != want {
+			t.Errorf("ParseLevel(%q) = %s, want %s", level, got, want)
+		}
+	}
+}
+
+func TestRedactHeaders(t *testing.T) {
+	h := http.Header{}
+	h.Set("Authorization", "Bearer token")
+	h.Set("Cookie", "session=1")
+	h.Set("X-Api-Key", "key")
+	h.Add("Accept", "application/json")
+	h.Add("Accept", "text/plain")
+	// Нестандартный регистр ключа, например заголовок добавлен напрямую в map
+	h["proxy-authorization"] = []string{"Basic creds"}
+
+	got := logger.RedactHeaders(h)
+
+	for _, name := range []string{"Authorization", "Cookie", "X-Api-Key", "proxy-authorization"} {
+		if got[name] != "[REDACTED]" {
+			t.Errorf("%s = %q, want it redacted", name, got[name])
+		}
+	}
+	if got["Accept"] != "application/json, text/plain" {
+		t.Errorf("Accept = %q", got["Accept"])
+	}
+	if h.Get("Authorization") != "Bearer token" {
+		t.Error("original headers must not be changed")
+	}
+}
+
+func TestRequestIDInRecords(t *testing.T) {
+	var buf bytes.Buffer
+	log := logger.NewWithWriter(&buf, "info")
+
+	ctx := logger.WithRequestID(context.Background(), "req-1")
+	log.With("component", "test").InfoContext(ctx, "with id")
+	log.DebugContext(ctx, "below level")
+	log.InfoContext(context.Background(), "without id")
+
+	dec := json.NewDecoder(&buf)
+	var records []map[string]any
+	for dec.More() {
+		var rec map[string]any
+		if err := dec.Decode(&rec); err != nil {
+			t.Fatal(err)
+		}
+		records = append(records, rec)
+	}
+
+	if len(records) != 2 {
+		t.Fatalf("expected 2 records, got %v", records)
+	}
+	if records[0]["request_id"] != "req-1" || records[0]["component"] != "test" {
+		t.Errorf("first record %v", records[0])
+	}
+	if _, ok := records[1]["request_id"]; ok {
+		t.Errorf("record without request id in context %v", records[1])
+	}
+}
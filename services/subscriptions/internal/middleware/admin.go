@@ -0,0 +1,53 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"subscriptions/internal/models"
+)
+
+// Запросы к /api/v2/admin/ проходят только с заголовком Authorization: Bearer <token>.
+// Без токена в конфигурации административный API выключен и отвечает 403
+
+const adminPrefix = "/api/v2/admin/"
+
+type AdminAuth struct {
+	token []byte
+}
+
+func NewAdminAuth(token string) *AdminAuth {
+	return &AdminAuth{token: []byte(token)}
+}
+
+func (a *AdminAuth) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.URL.Path, adminPrefix) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if len(a.token) == 0 {
+			writeAdminError(w, http.StatusForbidden, "forbidden", "admin API is disabled")
+			return
+		}
+
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), a.token) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeAdminError(w, http.StatusUnauthorized, "unauthorized", "Authorization header must contain admin token")
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Ошибка в формате API v2
+
+func writeAdminError(w http.ResponseWriter, statusCode int, code string, message string) {
+	w.Header().Set("Content-type", "application/json")
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(models.ErrorV2{Error: models.ErrorBodyV2{Code: code, Message: message}})
+}
@@ -0,0 +1,44 @@
+package middleware_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"subscriptions/internal/middleware"
+	"testing"
+)
+
+func TestAdminAuth(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	})
+
+	tests := []struct {
+		token  string
+		path   string
+		header string
+		status int
+	}{
+		{"", "/api/v2/admin/services", "Bearer ", http.StatusForbidden},
+		{"", "/api/v2/services", "", http.StatusTeapot},
+		{"0123456789abcdef", "/api/v2/admin/services", "", http.StatusUnauthorized},
+		{"0123456789abcdef", "/api/v2/admin/services", "0123456789abcdef", http.StatusUnauthorized},
+		{"0123456789abcdef", "/api/v2/admin/services", "Bearer 0123456789abcdeX", http.StatusUnauthorized},
+		{"0123456789abcdef", "/api/v2/admin/services/1", "Bearer 0123456789abcdef", http.StatusTeapot},
+		{"0123456789abcdef", "/api/v2/subscriptions", "60601fee-2bf1-4721-ae6f-7636e79a0cba", http.StatusTeapot},
+	}
+
+	for _, tt := range tests {
+		h := middleware.NewAdminAuth(tt.token).Handler(next)
+
+		req := httptest.NewRequest(http.MethodPost, tt.path, nil)
+		if tt.header != "" {
+			req.Header.Set("Authorization", tt.header)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+
+		if rec.Code != tt.status {
+			t.Fatalf("token %q, %s with %q: status %d, want %d", tt.token, tt.path, tt.header, rec.Code, tt.status)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"subscriptions/internal/config"
+)
+
+// CORS для всех ответов, а не только для preflight: без Access-Control-Allow-Origin
+// в обычном ответе браузер не отдаёт его странице
+
+type CORS struct {
+	origins          []string
+	allowAll         bool
+	methods          string
+	headers          string
+	exposed          string
+	allowCredentials bool
+	maxAge           string
+}
+
+func NewCORS(cfg config.CORSConfig) *CORS {
+	c := &CORS{
+		methods:          strings.Join(cfg.AllowedMethods, ", "),
+		headers:          strings.Join(cfg.AllowedHeaders, ", "),
+		exposed:          strings.Join(cfg.ExposedHeaders, ", "),
+		allowCredentials: cfg.AllowCredentials,
+		maxAge:           strconv.Itoa(int(cfg.MaxAge.Seconds())),
+	}
+
+	for _, origin := range cfg.AllowedOrigins {
+		if origin == "*" {
+			c.allowAll = true
+			continue
+		}
+		c.origins = append(c.origins, strings.ToLower(origin))
+	}
+
+	return c
+}
+
+func (c *CORS) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Ответ зависит от Origin, кэши не должны отдавать его другому источнику
+		w.Header().Add("Vary", "Origin")
+
+		origin := r.Header.Get("Origin")
+		preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+
+		if origin == "" || !c.allowed(origin) {
+			if r.Method == http.MethodOptions {
+				c.options(w)
+				return
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if c.allowAll && !c.allowCredentials {
+			w.Header().Set("Access-Control-Allow-Origin", "*")
+		} else {
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+		}
+		if c.allowCredentials {
+			w.Header().Set("Access-Control-Allow-Credentials", "true")
+		}
+
+		if preflight {
+			w.Header().Add("Vary", "Access-Control-Request-Method")
+			w.Header().Add("Vary", "Access-Control-Request-Headers")
+			w.Header().Set("Access-Control-Allow-Methods", c.methods)
+			w.Header().Set("Access-Control-Allow-Headers", c.headers)
+			w.Header().Set("Access-Control-Max-Age", c.maxAge)
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		if c.exposed != "" {
+			w.Header().Set("Access-Control-Expose-Headers", c.exposed)
+		}
+
+		if r.Method == http.MethodOptions {
+			c.options(w)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// OPTIONS без preflight не доходит до маршрутов и, как раньше, получает 204 со списком методов
+
+func (c *CORS) options(w http.ResponseWriter) {
+	w.Header().Set("Allow", c.methods)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Источник сравнивается без учёта регистра, шаблон https://*.example.com разрешает поддомены
+
+func (c *CORS) allowed(origin string) bool {
+	if c.allowAll {
+		return true
+	}
+
+	origin = strings.ToLower(origin)
+	for _, allowed := range c.origins {
+		if prefix, suffix, ok := strings.Cut(allowed, "*"); ok {
+			if len(origin) > len(prefix)+len(suffix) && strings.HasPrefix(origin, prefix) && strings.HasSuffix(origin, suffix) {
+				return true
+			}
+			continue
+		}
+		if origin == allowed {
+			return true
+		}
+	}
+
+	return false
+}
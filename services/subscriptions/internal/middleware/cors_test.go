@@ -0,0 +1,63 @@
+package middleware_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"subscriptions/internal/config"
+	"subscriptions/internal/middleware"
+	"testing"
+)
+
+func TestCORS(t *testing.T) {
+	cfg := config.Default().CORS
+	cfg.AllowedOrigins = []string{"https://app.example.com", "https://*.example.org"}
+	cfg.AllowCredentials = true
+
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	})
+	h := middleware.NewCORS(cfg).Handler(next)
+
+	do := func(method string, origin string, preflight bool) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/subscriptions", nil)
+		if origin != "" {
+			req.Header.Set("Origin", origin)
+		}
+		if preflight {
+			req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := do(http.MethodGet, "https://App.example.com", false)
+	if rec.Code != http.StatusTeapot || rec.Header().Get("Access-Control-Allow-Origin") != "https://App.example.com" {
+		t.Fatalf("actual request: status %d, headers %v", rec.Code, rec.Header())
+	}
+	if rec.Header().Get("Access-Control-Allow-Credentials") != "true" || rec.Header().Get("Vary") != "Origin" {
+		t.Fatalf("actual request: headers %v", rec.Header())
+	}
+
+	rec = do(http.MethodOptions, "https://api.example.org", true)
+	if rec.Code != http.StatusNoContent || rec.Header().Get("Access-Control-Allow-Methods") == "" || rec.Header().Get("Access-Control-Max-Age") != "600" {
+		t.Fatalf("preflight: status %d, headers %v", rec.Code, rec.Header())
+	}
+
+	rec = do(http.MethodGet, "https://evil.example.net", false)
+	if rec.Code != http.StatusTeapot || rec.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Fatalf("disallowed origin: status %d, headers %v", rec.Code, rec.Header())
+	}
+
+	rec = do(http.MethodOptions, "https://evil.example.net", true)
+	if rec.Code != http.StatusNoContent || rec.Header().Get("Access-Control-Allow-Methods") != "" {
+		t.Fatalf("disallowed preflight: status %d, headers %v", rec.Code, rec.Header())
+	}
+
+	for _, origin := range []string{"", "https://app.example.com"} {
+		rec = do(http.MethodOptions, origin, false)
+		if rec.Code != http.StatusNoContent || rec.Header().Get("Allow") == "" || rec.Header().Get("Access-Control-Max-Age") != "" {
+			t.Fatalf("plain OPTIONS with origin %q: status %d, headers %v", origin, rec.Code, rec.Header())
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package middleware_test
+
+import (
+	"bytes"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"subscriptions/internal/logger"
+	"subscriptions/internal/middleware"
+	"testing"
+)
+
+func TestRequestID(t *testing.T) {
+	var seen string
+	h := middleware.RequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = logger.RequestID(r.Context())
+	}))
+
+	cases := []struct {
+		name   string
+		header string
+		keep   bool
+	}{
+		{"propagated", "abc-123", true},
+		{"missing", "", false},
+		{"control characters", "abc\x01", false},
+		{"spaces", "abc 123", false},
+		{"too long", strings.Repeat("a", 129), false},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/subscriptions", nil)
+			if tc.header != "" {
+				req.Header.Set(middleware.RequestIDHeader, tc.header)
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+
+			got := rec.Header().Get(middleware.RequestIDHeader)
+			if got == "" || got != seen {
+				t.Fatalf("response id %q, context id %q", got, seen)
+			}
+			if tc.keep != (got == tc.header) {
+				t.Fatalf("header %q, got id %q", tc.header, got)
+			}
+		})
+	}
+}
+
+func TestLoggingRedactsHeaders(t *testing.T) {
+	var buf bytes.Buffer
+	prev := slog.Default()
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug})))
+	t.Cleanup(func() { slog.SetDefault(prev) })
+
+	h := middleware.Logging(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+	}))
+
+	req := httptest.NewRequest(http.MethodPost, "/subscriptions", nil)
+	req.Header.Set("Authorization", "Bearer secret-token")
+	req.Header.Set("Cookie", "session=secret-cookie")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+
+	out := buf.String()
+	if strings.Contains(out, "secret-token") || strings.Contains(out, "secret-cookie") {
+		t.Fatalf("sensitive header leaked to log: %s", out)
+	}
+	if !strings.Contains(out, `"Authorization":"[REDACTED]"`) || !strings.Contains(out, `"status":201`) {
+		t.Fatalf("unexpected log output: %s", out)
+	}
+}
@@ -0,0 +1,45 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"subscriptions/internal/metrics"
+	"time"
+)
+
+// Обёртка над http.ResponseWriter для получения кода ответа
+
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (rec *statusRecorder) WriteHeader(code int) {
+	rec.status = code
+	rec.ResponseWriter.WriteHeader(code)
+}
+
+func (rec *statusRecorder) Unwrap() http.ResponseWriter {
+	return rec.ResponseWriter
+}
+
+// Считает запросы и время ответа по маршруту. Маршрут берётся из шаблона ServeMux,
+// а не из пути запроса, чтобы id подписок не раздували количество рядов
+
+func Metrics(m *metrics.Metrics, mux *http.ServeMux, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+
+		next.ServeHTTP(rec, r)
+
+		_, route := mux.Handler(r)
+		if route == "" {
+			route = "unmatched"
+		}
+
+		status := strconv.Itoa(rec.status)
+		m.HTTPRequests.WithLabelValues(route, r.Method, status).Inc()
+		m.HTTPDuration.WithLabelValues(route, r.Method, status).Observe(time.Since(start).Seconds())
+	})
+}
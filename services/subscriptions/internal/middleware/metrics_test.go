@@ -0,0 +1,52 @@
+package middleware_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"subscriptions/internal/metrics"
+	"subscriptions/internal/middleware"
+	"testing"
+)
+
+func TestMetrics(t *testing.T) {
+	m := metrics.NewMetrics()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /subscriptions/{id}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	})
+	mux.HandleFunc("POST /subscriptions", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("{}"))
+	})
+	h := middleware.Metrics(m, mux, mux)
+
+	for _, req := range []*http.Request{
+		httptest.NewRequest(http.MethodGet, "/subscriptions/1", nil),
+		httptest.NewRequest(http.MethodGet, "/subscriptions/2", nil),
+		httptest.NewRequest(http.MethodPost, "/subscriptions", nil),
+		httptest.NewRequest(http.MethodGet, "/unknown", nil),
+	} {
+		h.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	rec := httptest.NewRecorder()
+	m.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body, _ := io.ReadAll(rec.Body)
+
+	// Маршрут берётся из шаблона, поэтому оба запроса по id попадают в один ряд
+	for _, want := range []string{
+		`subscriptions_http_requests_total{method="GET",route="GET /subscriptions/{id}",status="404"} 2`,
+		`subscriptions_http_requests_total{method="POST",route="POST /subscriptions",status="200"} 1`,
+		`subscriptions_http_requests_total{method="GET",route="unmatched",status="404"} 1`,
+		`subscriptions_http_request_duration_seconds_count{method="GET",route="GET /subscriptions/{id}",status="404"} 2`,
+	} {
+		if !strings.Contains(string(body), want) {
+			t.Errorf("metrics output has no %s", want)
+		}
+	}
+	if strings.Contains(string(body), `/subscriptions/1"`) {
+		t.Error("request path must not be used as a label")
+	}
+}
@@ -0,0 +1,184 @@
+package middleware
+
+import (
+	"context"
+	"log/slog"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"subscriptions/internal/config"
+	"subscriptions/internal/ratelimit"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Хранилище корзин токенов. В памяти процесса или общее для всех инстансов, например в Redis
+
+type Limiter interface {
+	Allow(ctx context.Context, key string, limit ratelimit.Limit) (ratelimit.Decision, error)
+}
+
+type rateLimitRule struct {
+	name   string
+	method string
+	path   string
+	limit  ratelimit.Limit
+}
+
+type RateLimiter struct {
+	store             Limiter
+	rules             []rateLimitRule
+	fallback          rateLimitRule
+	trustForwardedFor bool
+}
+
+func NewRateLimiter(store Limiter, cfg config.RateLimitConfig) *RateLimiter {
+	rl := &RateLimiter{
+		store:             store,
+		fallback:          rateLimitRule{name: "default", limit: ratelimit.Limit{Rate: cfg.Rate, Burst: cfg.Burst}},
+		trustForwardedFor: cfg.TrustForwardedFor,
+	}
+
+	for _, route := range cfg.Routes {
+		rl.rules = append(rl.rules, rateLimitRule{
+			name:   strings.TrimSpace(route.Method + " " + route.Path),
+			method: route.Method,
+			path:   route.Path,
+			limit:  ratelimit.Limit{Rate: route.Rate, Burst: route.Burst},
+		})
+	}
+
+	return rl
+}
+
+// Ограничивает запросы по IP клиента и, если в Authorization корректный UUID, ещё и по id пользователя.
+// Заголовок клиент задаёт сам, поэтому лимит по IP действует всегда, а корзина пользователя проверяется
+// только после него: подмена UUID не даёт новых запросов и не расходует чужую корзину сверх лимита IP.
+// Каждое правило считается в своих корзинах, поэтому строгий лимит на сумму не расходует общий
+
+func (rl *RateLimiter) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rule := rl.match(r)
+		if rule.limit.Rate <= 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		keys := []string{rule.name + ":ip:" + rl.clientIP(r)}
+		if userId, ok := validUserId(r); ok {
+			keys = append(keys, rule.name+":user:"+userId)
+		}
+
+		d, ok := rl.decide(r.Context(), keys, rule.limit)
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Set("RateLimit-Limit", strconv.Itoa(d.Limit))
+		w.Header().Set("RateLimit-Remaining", strconv.Itoa(d.Remaining))
+		w.Header().Set("RateLimit-Reset", ceilSeconds(d.Reset))
+
+		if !d.Allowed {
+			slog.WarnContext(r.Context(), "rate limit exceeded", "rule", rule.name, "remote_addr", r.RemoteAddr)
+			w.Header().Set("Retry-After", ceilSeconds(d.RetryAfter))
+			http.Error(w, "rate limit exceeded", http.StatusTooManyRequests)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Итог по корзинам запроса в порядке keys: действует самая строгая, после первого отказа остальные
+// не проверяются. При ошибке хранилища запрос пропускается
+
+func (rl *RateLimiter) decide(ctx context.Context, keys []string, limit ratelimit.Limit) (ratelimit.Decision, bool) {
+	var result ratelimit.Decision
+	found := false
+
+	for _, key := range keys {
+		d, err := rl.store.Allow(ctx, key, limit)
+		if err != nil {
+			slog.WarnContext(ctx, "rate limit: error during check, request allowed", "key", key, "error", err)
+			continue
+		}
+
+		if !found {
+			result, found = d, true
+		} else {
+			result.Allowed = result.Allowed && d.Allowed
+			result.Remaining = min(result.Remaining, d.Remaining)
+			result.Reset = max(result.Reset, d.Reset)
+			result.RetryAfter = max(result.RetryAfter, d.RetryAfter)
+		}
+
+		if !result.Allowed {
+			break
+		}
+	}
+
+	return result, found
+}
+
+// Первое подходящее правило. Путь с / на конце задаёт префикс, без него — точное совпадение.
+// Правила пишутся без версии API, поэтому /api/v1/subscriptions и /api/v2/subscriptions попадают под одно правило
+
+func (rl *RateLimiter) match(r *http.Request) rateLimitRule {
+	path := unversioned(r.URL.Path)
+	for _, rule := range rl.rules {
+		if rule.method != "" && rule.method != r.Method {
+			continue
+		}
+		if rule.path == path || strings.HasSuffix(rule.path, "/") && strings.HasPrefix(path, rule.path) {
+			return rule
+		}
+	}
+	return rl.fallback
+}
+
+func unversioned(path string) string {
+	rest, ok := strings.CutPrefix(path, "/api/v")
+	if !ok {
+		return path
+	}
+	version, tail, _ := strings.Cut(rest, "/")
+	if version == "" || strings.Trim(version, "0123456789") != "" {
+		return path
+	}
+	return "/" + tail
+}
+
+// Id пользователя в каноническом виде, чтобы разные записи одного UUID не давали разных корзин
+
+func validUserId(r *http.Request) (string, bool) {
+	userId, err := uuid.Parse(r.Header.Get("Authorization"))
+	if err != nil {
+		return "", false
+	}
+	return userId.String(), true
+}
+
+// X-Forwarded-For учитывается только за доверенным прокси, иначе клиент может подставить любой адрес
+
+func (rl *RateLimiter) clientIP(r *http.Request) string {
+	if rl.trustForwardedFor {
+		if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+			ip, _, _ := strings.Cut(forwarded, ",")
+			return strings.TrimSpace(ip)
+		}
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+func ceilSeconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
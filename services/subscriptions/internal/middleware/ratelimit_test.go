@@ -0,0 +1,100 @@
+package middleware_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"subscriptions/internal/config"
+	"subscriptions/internal/middleware"
+	"subscriptions/internal/ratelimit"
+	"testing"
+)
+
+func TestRateLimiter(t *testing.T) {
+	cfg := config.Default().RateLimit
+	cfg.Routes = []config.RateLimitRoute{
+		{Path: "/healthz"},
+		{Method: http.MethodPost, Path: "/subscriptions/sum/", Rate: 1, Burst: 1},
+	}
+
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	h := middleware.NewRateLimiter(ratelimit.NewMemory(), cfg).Handler(ok)
+
+	do := func(method string, path string, user string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		if user != "" {
+			req.Header.Set("Authorization", user)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := do(http.MethodPost, "/subscriptions/sum/Netflix", ""); rec.Code != http.StatusOK || rec.Header().Get("RateLimit-Limit") != "1" {
+		t.Fatalf("first sum: status %d, headers %v", rec.Code, rec.Header())
+	}
+
+	rec := do(http.MethodPost, "/subscriptions/sum/Netflix", "")
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("second sum: status %d, want 429", rec.Code)
+	}
+	if rec.Header().Get("Retry-After") != "1" || rec.Header().Get("RateLimit-Remaining") != "0" {
+		t.Fatalf("second sum: headers %v", rec.Header())
+	}
+
+	if rec := do(http.MethodPost, "/api/v2/subscriptions/sum/Netflix", ""); rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("versioned sum must share the rule: status %d, want 429", rec.Code)
+	}
+
+	if rec := do(http.MethodGet, "/subscriptions", ""); rec.Code != http.StatusOK {
+		t.Fatalf("default rule must use its own bucket: status %d", rec.Code)
+	}
+
+	for i := 0; i < 3; i++ {
+		if rec := do(http.MethodGet, "/healthz", ""); rec.Code != http.StatusOK || rec.Header().Get("RateLimit-Limit") != "" {
+			t.Fatalf("healthz must not be limited: status %d", rec.Code)
+		}
+	}
+}
+
+func TestRateLimiterUserKey(t *testing.T) {
+	cfg := config.Default().RateLimit
+	cfg.Rate, cfg.Burst = 1, 2
+
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	h := middleware.NewRateLimiter(ratelimit.NewMemory(), cfg).Handler(ok)
+
+	do := func(ip string, user string) int {
+		req := httptest.NewRequest(http.MethodGet, "/subscriptions", nil)
+		req.RemoteAddr = ip + ":1234"
+		req.Header.Set("Authorization", user)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	const victim = "60601fee-2bf1-4721-ae6f-7636e79a0cba"
+
+	// Новый UUID в каждом запросе не обходит лимит IP, и после отказа корзина пользователя не расходуется
+	users := []string{"11111111-1111-1111-1111-111111111111", "22222222-2222-2222-2222-222222222222", victim, victim}
+	for i, user := range users {
+		want := http.StatusOK
+		if i >= 2 {
+			want = http.StatusTooManyRequests
+		}
+		if code := do("192.0.2.1", user); code != want {
+			t.Fatalf("request %d from one IP: status %d, want %d", i, code, want)
+		}
+	}
+
+	// Запросы с заблокированного IP не истратили корзину пользователя, а запись UUID в другом регистре — та же корзина
+	for i, user := range []string{victim, strings.ToUpper(victim), victim} {
+		want := http.StatusOK
+		if i == 2 {
+			want = http.StatusTooManyRequests
+		}
+		if code := do("192.0.2.2", user); code != want {
+			t.Fatalf("request %d of user from another IP: status %d, want %d", i, code, want)
+		}
+	}
+}
@@ -0,0 +1,9 @@
+DROP INDEX IF EXISTS subscriptions_user_service_period_idx;
+DROP INDEX IF EXISTS subscriptions_user_start_idx;
+
+ALTER TABLE subscriptions
+    DROP CONSTRAINT IF EXISTS subscriptions_end_after_start,
+    DROP CONSTRAINT IF EXISTS subscriptions_price_non_negative;
+
+ALTER TABLE subscriptions
+    ALTER COLUMN user_id TYPE VARCHAR(255) USING user_id::text;
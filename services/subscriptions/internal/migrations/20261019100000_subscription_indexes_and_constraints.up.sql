@@ -0,0 +1,13 @@
+-- Существующие user_id должны быть корректными UUID, иначе миграция упадёт на приведении типа
+ALTER TABLE subscriptions
+    ALTER COLUMN user_id TYPE UUID USING user_id::uuid;
+
+ALTER TABLE subscriptions
+    ADD CONSTRAINT subscriptions_price_non_negative CHECK (price >= 0),
+    ADD CONSTRAINT subscriptions_end_after_start CHECK (end_date IS NULL OR end_date >= start_date);
+
+-- readSubs и выборки по периоду без сервиса
+CREATE INDEX subscriptions_user_start_idx ON subscriptions (user_id, start_date);
+
+-- showsubssum и showsubstotalsum: равенство по user_id и service_name, диапазон по датам
+CREATE INDEX subscriptions_user_service_period_idx ON subscriptions (user_id, service_name, start_date, end_date);
@@ -0,0 +1,23 @@
+-- Бюджет на месяц: общий (service_name = '') или по одному сервису, не больше одного на пару пользователь и сервис
+CREATE TABLE budgets(
+    id SERIAL PRIMARY KEY,
+    user_id UUID NOT NULL,
+    service_name VARCHAR(255) NOT NULL DEFAULT '',
+    monthly_limit BIGINT NOT NULL CONSTRAINT budgets_limit_non_negative CHECK (monthly_limit >= 0),
+    CONSTRAINT budgets_user_service_key UNIQUE (user_id, service_name)
+);
+
+-- Одно предупреждение на бюджет и месяц, повторная проверка обновляет сумму
+CREATE TABLE budget_alerts(
+    id SERIAL PRIMARY KEY,
+    budget_id INTEGER NOT NULL REFERENCES budgets(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL,
+    service_name VARCHAR(255) NOT NULL,
+    month DATE NOT NULL,
+    monthly_limit BIGINT NOT NULL,
+    spent BIGINT NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT budget_alerts_budget_month_key UNIQUE (budget_id, month)
+);
+
+CREATE INDEX budget_alerts_user_month_idx ON budget_alerts (user_id, month);
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS reminders_sent;
@@ -0,0 +1,8 @@
+-- Отправленные напоминания: одна запись на подписку, вид напоминания и день события, чтобы не слать повторно
+CREATE TABLE reminders_sent(
+    subscription_id INTEGER NOT NULL REFERENCES subscriptions(id) ON DELETE CASCADE,
+    kind VARCHAR(16) NOT NULL,
+    due_date DATE NOT NULL,
+    sent_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT reminders_sent_pkey PRIMARY KEY (subscription_id, kind, due_date)
+);
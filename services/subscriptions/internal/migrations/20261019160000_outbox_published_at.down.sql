@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS outbox_unpublished_idx;
+ALTER TABLE outbox DROP COLUMN IF EXISTS published_at;
@@ -0,0 +1,4 @@
+-- Публикация в брокер отмечается отдельно от рассылки на вебхуки: у каждого потребителя outbox свой признак
+ALTER TABLE outbox ADD COLUMN published_at TIMESTAMP WITH TIME ZONE;
+
+CREATE INDEX outbox_unpublished_idx ON outbox (id) WHERE published_at IS NULL;
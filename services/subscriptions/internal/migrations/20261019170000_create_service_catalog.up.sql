@@ -0,0 +1,16 @@
+-- Каталог сервисов: имя подписки, совпавшее с name или одним из aliases без учёта регистра, заменяется на name.
+-- Уникальность псевдонимов между записями проверяет сервис, индекс защищает только канонические имена
+CREATE TABLE service_catalog(
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    aliases TEXT[] NOT NULL DEFAULT '{}',
+    category VARCHAR(255) NOT NULL DEFAULT '',
+    default_price BIGINT NOT NULL DEFAULT 0 CONSTRAINT service_catalog_price_non_negative CHECK (default_price >= 0),
+    logo_url TEXT NOT NULL DEFAULT ''
+);
+
+CREATE UNIQUE INDEX service_catalog_name_key ON service_catalog (lower(name));
+
+-- showsubssum сравнивает имена сервисов без учёта регистра и пробелов по краям
+DROP INDEX IF EXISTS subscriptions_user_service_period_idx;
+CREATE INDEX subscriptions_user_service_period_idx ON subscriptions (user_id, lower(btrim(service_name)), start_date, end_date);
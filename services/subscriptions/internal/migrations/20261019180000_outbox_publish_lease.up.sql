@@ -0,0 +1,5 @@
+-- Аренда события публикацией в брокер: пока она не истекла, событие не возьмёт другой инстанс
+ALTER TABLE outbox ADD COLUMN publish_lease_until TIMESTAMP WITH TIME ZONE;
+
+-- Очистка outbox ищет события, уже опубликованные и разосланные на вебхуки
+CREATE INDEX outbox_published_idx ON outbox (published_at) WHERE published_at IS NOT NULL;
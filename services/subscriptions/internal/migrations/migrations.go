@@ -0,0 +1,8 @@
+package migrations
+
+import "embed"
+
+// SQL миграции вшиваются в бинарник, поэтому приложение не зависит от рабочего каталога
+
+//go:embed *.sql
+var FS embed.FS
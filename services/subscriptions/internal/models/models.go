@@ -1,5 +1,6 @@
 package models
 
+import "time"
 
 type Subscription struct {
 	Id          int       `json:"id,omitempty"`
@@ -15,3 +16,176 @@ type ShowSubscSum struct {
 	StartDate string `json:"start_date"`
 	EndDate   string `json:"end_date"`
 }
+
+// Подписки за период и их итоговая сумма
+
+type SubscriptionSum struct {
+	Items []Subscription `json:"items"`
+	Total int            `json:"total" example:"1200"`
+}
+
+// Подписка в API v2: даты в формате ISO 8601 год-месяц, без служебного поля total_sum
+
+type SubscriptionV2 struct {
+	Id          int    `json:"id"`
+	ServiceName string `json:"service_name" example:"Netflix"`
+	Price       int    `json:"price" example:"400"`
+	UserId      string `json:"user_id" example:"60601fee-2bf1-4721-ae6f-7636e79a0cba"`
+	StartDate   string `json:"start_date" example:"2025-07"`
+	EndDate     string `json:"end_date,omitempty" example:"2025-12"`
+}
+
+// Сумма в API v2 отдаётся отдельным полем, а не строкой "Итого" в конце списка
+
+type SubscriptionSumV2 struct {
+	Items []SubscriptionV2 `json:"items"`
+	Total int              `json:"total"`
+}
+
+// Ошибка в API v2: машиночитаемый код и описание
+
+type ErrorV2 struct {
+	Error ErrorBodyV2 `json:"error"`
+}
+
+type ErrorBodyV2 struct {
+	Code    string `json:"code" example:"not_found"`
+	Message string `json:"message" example:"subscription not found"`
+}
+
+// Расходы пользователя за месяц: сумма подписок, действующих в этом месяце, и разбивка по сервисам
+
+type MonthlySpending struct {
+	Month    string            `json:"month" example:"2025-07"`
+	Total    int               `json:"total" example:"1200"`
+	Services []ServiceSpending `json:"services"`
+}
+
+type ServiceSpending struct {
+	ServiceName string `json:"service_name" example:"Netflix"`
+	Total       int    `json:"total" example:"400"`
+}
+
+// Прогноз расходов по месяцам. В Expiring — подписки, для которых месяц последний
+
+type Forecast struct {
+	Months []ForecastMonth `json:"months"`
+	Total  int             `json:"total" example:"7200"`
+}
+
+type ForecastMonth struct {
+	MonthlySpending
+	Expiring []ServiceSpending `json:"expiring"`
+}
+
+// Бюджет пользователя на месяц. Пустой service_name — общий бюджет на все подписки
+
+type Budget struct {
+	Id           int    `json:"id"`
+	UserId       string `json:"user_id" example:"60601fee-2bf1-4721-ae6f-7636e79a0cba"`
+	ServiceName  string `json:"service_name,omitempty" example:"Netflix"`
+	MonthlyLimit int    `json:"monthly_limit" example:"1000"`
+}
+
+// Сервис из каталога. Имя подписки, совпавшее с name или одним из aliases без учёта регистра, заменяется на name
+
+type CatalogEntry struct {
+	Id           int      `json:"id"`
+	Name         string   `json:"name" example:"Netflix"`
+	Aliases      []string `json:"aliases" example:"netflix.com,NFLX"`
+	Category     string   `json:"category,omitempty" example:"video"`
+	DefaultPrice int      `json:"default_price" example:"400"`
+	LogoURL      string   `json:"logo_url,omitempty" example:"https://cdn.example.com/logos/netflix.png"`
+}
+
+// Превышение бюджета в месяце. На бюджет и месяц хранится одна запись с последней посчитанной суммой
+
+type BudgetAlert struct {
+	Id           int    `json:"id"`
+	BudgetId     int    `json:"budget_id"`
+	UserId       string `json:"user_id" example:"60601fee-2bf1-4721-ae6f-7636e79a0cba"`
+	ServiceName  string `json:"service_name,omitempty" example:"Netflix"`
+	Month        string `json:"month" example:"2025-07"`
+	MonthlyLimit int    `json:"monthly_limit" example:"1000"`
+	Spent        int    `json:"spent" example:"1200"`
+	CreatedAt    string `json:"created_at" example:"2025-07-01T12:00:00Z"`
+}
+
+// Действующая подписка с точными датами: по ним планировщик напоминаний считает дни продления
+
+type ActiveSubscription struct {
+	Id          int
+	ServiceName string
+	Price       int
+	UserId      string
+	StartDate   time.Time
+	EndDate     *time.Time
+}
+
+// Напоминание о продлении (renewal) или окончании (ending) подписки, Date — день события
+
+type Reminder struct {
+	Kind           string `json:"kind" example:"renewal"`
+	SubscriptionId int    `json:"subscription_id"`
+	UserId         string `json:"user_id" example:"60601fee-2bf1-4721-ae6f-7636e79a0cba"`
+	ServiceName    string `json:"service_name" example:"Netflix"`
+	Price          int    `json:"price" example:"400"`
+	Date           string `json:"date" example:"2025-08-01"`
+}
+
+// Событие изменения подписки. Пишется в таблицу outbox в одной транзакции с изменением,
+// Data — подписка после создания или изменения, перед удалением
+
+type Event struct {
+	Id        int          `json:"id"`
+	Type      string       `json:"type" example:"subscription.created"`
+	UserId    string       `json:"user_id" example:"60601fee-2bf1-4721-ae6f-7636e79a0cba"`
+	Data      Subscription `json:"data"`
+	CreatedAt string       `json:"created_at" example:"2025-07-01T12:00:00Z"`
+}
+
+// Адрес, на который отправляются события подписок пользователя. Secret виден только в ответе на создание
+
+type Webhook struct {
+	Id        int    `json:"id"`
+	UserId    string `json:"user_id" example:"60601fee-2bf1-4721-ae6f-7636e79a0cba"`
+	URL       string `json:"url" example:"https://billing.example.com/hooks/subscriptions"`
+	Secret    string `json:"secret,omitempty" example:"5f2b8c0e9a..."`
+	CreatedAt string `json:"created_at" example:"2025-07-01T12:00:00Z"`
+}
+
+// Доставка события на вебхук: pending — ждёт попытки, delivered или failed после последней попытки
+
+type WebhookDelivery struct {
+	Id             int    `json:"id"`
+	WebhookId      int    `json:"webhook_id"`
+	EventId        int    `json:"event_id"`
+	EventType      string `json:"event_type" example:"subscription.created"`
+	Status         string `json:"status" example:"delivered"`
+	Attempts       int    `json:"attempts" example:"1"`
+	LastStatusCode int    `json:"last_status_code,omitempty" example:"200"`
+	LastError      string `json:"last_error,omitempty"`
+	NextAttemptAt  string `json:"next_attempt_at,omitempty" example:"2025-07-01T12:00:10Z"`
+	DeliveredAt    string `json:"delivered_at,omitempty" example:"2025-07-01T12:00:01Z"`
+	CreatedAt      string `json:"created_at" example:"2025-07-01T12:00:00Z"`
+}
+
+// Доставка, взятая отправителем в работу, вместе с адресом, секретом и самим событием
+
+type PendingDelivery struct {
+	Id       int
+	Attempts int
+	URL      string
+	Secret   string
+	Event    Event
+}
+
+// Итог одной попытки доставки. StatusCode 0 — ответа не было
+
+type DeliveryAttempt struct {
+	Status        string
+	StatusCode    int
+	Error         string
+	At            time.Time
+	NextAttemptAt time.Time
+}
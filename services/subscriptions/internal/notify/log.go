@@ -0,0 +1,29 @@
+package notify
+
+import (
+	"context"
+	"log/slog"
+	"subscriptions/internal/models"
+)
+
+// Пишет напоминания в лог, используется по умолчанию и при локальной разработке
+
+type Log struct {
+	logger *slog.Logger
+}
+
+func NewLog(logger *slog.Logger) *Log {
+	return &Log{logger: logger}
+}
+
+func (l *Log) Notify(ctx context.Context, r models.Reminder) error {
+	l.logger.InfoContext(ctx, "subscription reminder",
+		"kind", r.Kind,
+		"subscription_id", r.SubscriptionId,
+		"user_id", r.UserId,
+		"service_name", r.ServiceName,
+		"price", r.Price,
+		"date", r.Date,
+	)
+	return nil
+}
@@ -0,0 +1,44 @@
+package notify_test
+
+import (
+	"context"
+	"strings"
+	"subscriptions/internal/config"
+	"subscriptions/internal/models"
+	"subscriptions/internal/notify"
+	"subscriptions/internal/notify/smtpstub"
+	"testing"
+	"time"
+)
+
+func TestSMTP(t *testing.T) {
+	stub, err := smtpstub.Start("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { stub.Close() })
+
+	n := notify.NewSMTP(config.SMTPConfig{Addr: stub.Addr(), From: "subscriptions@localhost", To: "{user_id}@example.com"})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	r := models.Reminder{Kind: "ending", SubscriptionId: 1, UserId: "42", ServiceName: "Netflix\r\nBcc: x@example.com", Price: 400, Date: "2025-08-01"}
+	if err := n.Notify(ctx, r); err != nil {
+		t.Fatal(err)
+	}
+
+	msgs := stub.Messages()
+	if len(msgs) != 1 {
+		t.Fatalf("got %d messages, want 1", len(msgs))
+	}
+	if msgs[0].From != "subscriptions@localhost" || len(msgs[0].To) != 1 || msgs[0].To[0] != "42@example.com" {
+		t.Fatalf("unexpected envelope %+v", msgs[0])
+	}
+	if !strings.Contains(msgs[0].Data, "Subject: Subscription Netflix  Bcc: x@example.com ends on 2025-08-01") {
+		t.Fatalf("unexpected message:\n%s", msgs[0].Data)
+	}
+	if strings.Contains(msgs[0].Data, "\nBcc:") {
+		t.Fatalf("header injected:\n%s", msgs[0].Data)
+	}
+}
@@ -0,0 +1,114 @@
+package notify
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/smtp"
+	"strings"
+	"subscriptions/internal/config"
+	"subscriptions/internal/models"
+	"subscriptions/internal/reminders"
+	"time"
+)
+
+// Отправляет напоминание письмом. Адрес получателя строится из шаблона cfg.To, в котором {user_id} заменяется на id пользователя
+
+type SMTP struct {
+	cfg config.SMTPConfig
+}
+
+func NewSMTP(cfg config.SMTPConfig) *SMTP {
+	return &SMTP{cfg: cfg}
+}
+
+func (m *SMTP) Notify(ctx context.Context, r models.Reminder) error {
+	to := strings.ReplaceAll(m.cfg.To, "{user_id}", r.UserId)
+
+	if err := m.send(ctx, to, message(m.cfg.From, to, r)); err != nil {
+		return fmt.Errorf("SMTP: error during sending to %s: %w", to, err)
+	}
+
+	return nil
+}
+
+// То же, что smtp.SendMail, но соединение ограничено ctx
+
+func (m *SMTP) send(ctx context.Context, to string, msg []byte) error {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", m.cfg.Addr)
+	if err != nil {
+		return err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	host, _, _ := net.SplitHostPort(m.cfg.Addr)
+
+	c, err := smtp.NewClient(conn, host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer c.Close()
+
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			return err
+		}
+	}
+	if m.cfg.Username != "" {
+		if err := c.Auth(smtp.PlainAuth("", m.cfg.Username, m.cfg.Password, host)); err != nil {
+			return err
+		}
+	}
+
+	if err := c.Mail(m.cfg.From); err != nil {
+		return err
+	}
+	if err := c.Rcpt(to); err != nil {
+		return err
+	}
+
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+
+	return c.Quit()
+}
+
+// Название сервиса приходит от пользователя, переводы строк в нём дали бы подставить свои заголовки письма
+
+var singleLine = strings.NewReplacer("\r", " ", "\n", " ")
+
+func message(from string, to string, r models.Reminder) []byte {
+	name := singleLine.Replace(r.ServiceName)
+	subject := fmt.Sprintf("Subscription %s renews on %s", name, r.Date)
+	body := fmt.Sprintf("Your subscription %s will be renewed on %s for %d.", name, r.Date, r.Price)
+	if r.Kind == reminders.KindEnding {
+		subject = fmt.Sprintf("Subscription %s ends on %s", name, r.Date)
+		body = fmt.Sprintf("Your subscription %s ends on %s.", name, r.Date)
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", from)
+	fmt.Fprintf(&b, "To: %s\r\n", to)
+	fmt.Fprintf(&b, "Subject: %s\r\n", subject)
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().UTC().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(body)
+	b.WriteString("\r\n")
+
+	return []byte(b.String())
+}
@@ -0,0 +1,144 @@
+package smtpstub
+
+import (
+	"io"
+	"log/slog"
+	"net"
+	"net/textproto"
+	"strings"
+	"sync"
+)
+
+// Локальная заглушка SMTP сервера для разработки и тестов: принимает письма без проверок,
+// пишет их в лог и хранит в памяти. Поддерживает только команды, которые нужны net/smtp
+
+type Message struct {
+	From string
+	To   []string
+	Data string
+}
+
+type Server struct {
+	ln       net.Listener
+	wg       sync.WaitGroup
+	mu       sync.Mutex
+	messages []Message
+}
+
+func Start(addr string) (*Server, error) {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Server{ln: ln}
+
+	s.wg.Add(1)
+	go s.serve()
+
+	return s, nil
+}
+
+func (s *Server) Addr() string {
+	return s.ln.Addr().String()
+}
+
+// Принятые письма в порядке получения
+
+func (s *Server) Messages() []Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]Message(nil), s.messages...)
+}
+
+func (s *Server) Close() error {
+	err := s.ln.Close()
+	s.wg.Wait()
+	return err
+}
+
+func (s *Server) serve() {
+	defer s.wg.Done()
+
+	for {
+		conn, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			defer conn.Close()
+
+			if err := s.handle(textproto.NewConn(conn)); err != nil && err != io.EOF {
+				slog.Warn("smtp stub: connection error", "error", err)
+			}
+		}()
+	}
+}
+
+func (s *Server) handle(c *textproto.Conn) error {
+	if err := c.PrintfLine("220 smtpstub ESMTP"); err != nil {
+		return err
+	}
+
+	var msg Message
+	for {
+		line, err := c.ReadLine()
+		if err != nil {
+			return err
+		}
+
+		cmd, arg, _ := strings.Cut(line, " ")
+		reply := "250 OK"
+
+		switch strings.ToUpper(cmd) {
+		case "EHLO", "HELO":
+			reply = "250 smtpstub"
+		case "MAIL":
+			msg = Message{From: address(arg)}
+		case "RCPT":
+			msg.To = append(msg.To, address(arg))
+		case "DATA":
+			if err := c.PrintfLine("354 End data with <CR><LF>.<CR><LF>"); err != nil {
+				return err
+			}
+			data, err := io.ReadAll(c.DotReader())
+			if err != nil {
+				return err
+			}
+			msg.Data = string(data)
+			s.save(msg)
+			msg = Message{}
+		case "RSET":
+			msg = Message{}
+		case "NOOP":
+		case "QUIT":
+			return c.PrintfLine("221 Bye")
+		default:
+			reply = "502 Command not implemented"
+		}
+
+		if err := c.PrintfLine("%s", reply); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *Server) save(msg Message) {
+	s.mu.Lock()
+	s.messages = append(s.messages, msg)
+	s.mu.Unlock()
+
+	slog.Info("smtp stub received message", "from", msg.From, "to", msg.To, "data", msg.Data)
+}
+
+// FROM:<a@b> или TO:<a@b> → a@b
+
+func address(arg string) string {
+	_, addr, _ := strings.Cut(arg, ":")
+	addr, _, _ = strings.Cut(strings.TrimSpace(addr), " ")
+	return strings.Trim(addr, "<>")
+}
@@ -0,0 +1,7 @@
+package outbox
+
+import "time"
+
+func (r *Relay) SetNow(now func() time.Time) {
+	r.now = now
+}
@@ -0,0 +1,62 @@
+package outbox
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+// Сколько ждать подтверждения JetStream, если у ctx нет своего срока
+
+const ackTimeout = 5 * time.Second
+
+// Публикация в JetStream. Темы <subject_prefix>.> должны входить в поток, иначе публикация вернёт ошибку.
+// Id сообщения уходит в заголовке Nats-Msg-Id, по нему поток отбрасывает дубликаты
+
+type NATS struct {
+	conn *nats.Conn
+	js   jetstream.JetStream
+}
+
+func NewNATS(url string) (*NATS, error) {
+	conn, err := nats.Connect(url, nats.Name("subscriptions"), nats.MaxReconnects(-1))
+	if err != nil {
+		return nil, fmt.Errorf("connect to nats: %w", err)
+	}
+
+	js, err := jetstream.New(conn)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("connect to jetstream: %w", err)
+	}
+
+	return &NATS{conn: conn, js: js}, nil
+}
+
+// Возвращается после PubAck: поток сохранил сообщение или узнал в нём дубликат
+
+func (n *NATS) Publish(ctx context.Context, msg Message) error {
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, ackTimeout)
+		defer cancel()
+	}
+
+	m := nats.NewMsg(msg.Subject)
+	m.Data = msg.Data
+
+	if _, err := n.js.PublishMsg(ctx, m, jetstream.WithMsgID(msg.Id)); err != nil {
+		return fmt.Errorf("publish %s: %w", msg.Subject, err)
+	}
+
+	return nil
+}
+
+// Отправляет накопленные сообщения и закрывает соединение
+
+func (n *NATS) Close() error {
+	return n.conn.Drain()
+}
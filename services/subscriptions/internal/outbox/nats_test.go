@@ -0,0 +1,76 @@
+package outbox_test
+
+import (
+	"context"
+	"subscriptions/internal/outbox"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+func TestNATS(t *testing.T) {
+	ctx := context.Background()
+
+	ns, err := server.NewServer(&server.Options{Host: "127.0.0.1", Port: server.RANDOM_PORT, NoLog: true, NoSigs: true, JetStream: true, StoreDir: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	go ns.Start()
+	t.Cleanup(ns.Shutdown)
+	if !ns.ReadyForConnections(5 * time.Second) {
+		t.Fatal("nats server is not ready")
+	}
+
+	pub, err := outbox.NewNATS(ns.ClientURL())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { pub.Close() })
+
+	msg := outbox.Message{Subject: "subscriptions.subscription.created", Id: "7", Data: []byte(`{"id":7}`)}
+
+	// Без потока на эти темы подтверждения нет, и публикация не считается успешной
+	if err := pub.Publish(ctx, msg); err == nil {
+		t.Fatal("publish without a stream succeeded")
+	}
+
+	conn, err := nats.Connect(ns.ClientURL())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(conn.Close)
+	js, err := jetstream.New(conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stream, err := js.CreateStream(ctx, jetstream.StreamConfig{Name: "SUBSCRIPTIONS", Subjects: []string{"subscriptions.>"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Повтор с тем же id поток отбрасывает
+	for range 2 {
+		if err := pub.Publish(ctx, msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	info, err := stream.Info(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.State.Msgs != 1 {
+		t.Fatalf("stream has %d messages, want 1", info.State.Msgs)
+	}
+
+	m, err := stream.GetMsg(ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Subject != msg.Subject || m.Header.Get(nats.MsgIdHdr) != "7" || string(m.Data) != `{"id":7}` {
+		t.Fatalf("unexpected message %s %v %s", m.Subject, m.Header, m.Data)
+	}
+}
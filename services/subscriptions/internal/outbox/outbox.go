@@ -0,0 +1,124 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"strconv"
+	"subscriptions/internal/config"
+	"subscriptions/internal/models"
+	"time"
+)
+
+// Сообщение для брокера. Id — id события в outbox, по нему получатель отбрасывает повторы
+
+type Message struct {
+	Subject string
+	Id      string
+	Data    []byte
+}
+
+// Брокер сообщений. Publish возвращается, когда брокер принял сообщение
+
+type Publisher interface {
+	Publish(ctx context.Context, msg Message) error
+}
+
+// События outbox, ещё не опубликованные в брокер
+
+type Store interface {
+	ClaimEventsRequest(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]models.Event, error)
+	ReleaseEventsRequest(ctx context.Context, ids []int) error
+	MarkPublishedRequest(ctx context.Context, ids []int) error
+	DeleteProcessedEventsRequest(ctx context.Context, before time.Time, limit int) (int, error)
+}
+
+type Relay struct {
+	store Store
+	pub   Publisher
+	cfg   config.EventsConfig
+	now   func() time.Time
+}
+
+func NewRelay(store Store, pub Publisher, cfg config.EventsConfig) *Relay {
+	return &Relay{store: store, pub: pub, cfg: cfg, now: time.Now}
+}
+
+// Раз в poll_interval публикует новые события и удаляет старые обработанные, пока не отменён ctx
+
+func (r *Relay) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.cfg.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := r.Process(ctx); err != nil && ctx.Err() == nil {
+			slog.ErrorContext(ctx, "Relay: error during publishing of outbox events", "error", err)
+		}
+		if _, err := r.Cleanup(ctx); err != nil && ctx.Err() == nil {
+			slog.ErrorContext(ctx, "Relay: error during cleanup of outbox events", "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Один проход: берёт события в аренду, публикует их по порядку id и отмечает опубликованные. На первой ошибке
+// проход останавливается, чтобы не нарушить порядок, уже опубликованные события всё равно отмечаются,
+// а с остальных аренда снимается, чтобы следующий проход начал с них
+
+func (r *Relay) Process(ctx context.Context) (int, error) {
+	events, err := r.store.ClaimEventsRequest(ctx, r.now(), r.cfg.Lease, r.cfg.BatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	var published []int
+	var pubErr error
+	for _, e := range events {
+		data, err := json.Marshal(e)
+		if err != nil {
+			pubErr = err
+			break
+		}
+
+		msg := Message{Subject: r.cfg.SubjectPrefix + "." + e.Type, Id: strconv.Itoa(e.Id), Data: data}
+		if pubErr = r.pub.Publish(ctx, msg); pubErr != nil {
+			break
+		}
+		published = append(published, e.Id)
+	}
+
+	if len(published) > 0 {
+		// Отметка не зависит от отмены ctx: сообщения уже в брокере, иначе они уйдут повторно
+		if err := r.store.MarkPublishedRequest(context.WithoutCancel(ctx), published); err != nil {
+			return 0, err
+		}
+	}
+
+	if rest := events[len(published):]; len(rest) > 0 {
+		ids := make([]int, len(rest))
+		for i, e := range rest {
+			ids[i] = e.Id
+		}
+		if err := r.store.ReleaseEventsRequest(context.WithoutCancel(ctx), ids); err != nil {
+			return len(published), errors.Join(pubErr, err)
+		}
+	}
+
+	return len(published), pubErr
+}
+
+// Удаляет до batch_size событий, опубликованных и разосланных на вебхуки раньше чем retention назад
+
+func (r *Relay) Cleanup(ctx context.Context) (int, error) {
+	if r.cfg.Retention == 0 {
+		return 0, nil
+	}
+
+	return r.store.DeleteProcessedEventsRequest(ctx, r.now().Add(-r.cfg.Retention), r.cfg.BatchSize)
+}
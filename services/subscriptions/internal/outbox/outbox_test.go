@@ -0,0 +1,128 @@
+package outbox_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"subscriptions/internal/config"
+	"subscriptions/internal/models"
+	"subscriptions/internal/outbox"
+	"subscriptions/internal/service"
+	"subscriptions/internal/storage/memory"
+	"testing"
+	"time"
+)
+
+const userId = "60601fee-2bf1-4721-ae6f-7636e79a0cba"
+
+var cfg = config.EventsConfig{SubjectPrefix: "subscriptions", BatchSize: 10, Lease: time.Minute, Retention: time.Hour}
+
+// Брокер, который отказывает после заданного числа сообщений
+
+type flaky struct {
+	*outbox.Memory
+	left int
+}
+
+func (f *flaky) Publish(ctx context.Context, msg outbox.Message) error {
+	if f.left == 0 {
+		return errors.New("broker unavailable")
+	}
+	f.left--
+	return f.Memory.Publish(ctx, msg)
+}
+
+func TestRelay(t *testing.T) {
+	ctx := context.Background()
+	st := memory.NewStorage()
+	s := service.NewService(st)
+
+	created, err := s.CreateSub(ctx, models.Subscription{ServiceName: "Netflix", Price: 400, UserId: userId, StartDate: "2025-07-01"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := created.Id
+	if _, _, err := s.UpdateSub(ctx, models.Subscription{Id: id, ServiceName: "Netflix", Price: 500, UserId: userId, StartDate: "2025-07-01"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.DeleteSub(ctx, id); err != nil {
+		t.Fatal(err)
+	}
+
+	// Брокер принимает одно сообщение: остальные остаются в outbox и уходят следующим проходом в том же порядке
+	pub := &flaky{Memory: outbox.NewMemory(), left: 1}
+	r := outbox.NewRelay(st, pub, cfg)
+
+	if n, err := r.Process(ctx); err == nil || n != 1 {
+		t.Fatalf("first pass published %d, error %v", n, err)
+	}
+
+	pub.left = -1
+	if n, err := r.Process(ctx); err != nil || n != 2 {
+		t.Fatalf("second pass published %d, error %v", n, err)
+	}
+	if n, err := r.Process(ctx); err != nil || n != 0 {
+		t.Fatalf("published events were published again: %d, error %v", n, err)
+	}
+
+	msgs := pub.Messages()
+	want := []string{"subscriptions." + service.EventSubCreated, "subscriptions." + service.EventSubUpdated, "subscriptions." + service.EventSubDeleted}
+	if len(msgs) != len(want) {
+		t.Fatalf("got %d messages, want %d", len(msgs), len(want))
+	}
+	for i, msg := range msgs {
+		if msg.Subject != want[i] {
+			t.Fatalf("message %d subject %q, want %q", i, msg.Subject, want[i])
+		}
+	}
+
+	var e models.Event
+	if err := json.Unmarshal(msgs[1].Data, &e); err != nil {
+		t.Fatal(err)
+	}
+	if e.Data.Id != id || e.Data.Price != 500 || msgs[1].Id == msgs[0].Id {
+		t.Fatalf("unexpected message %+v with event %+v", msgs[1], e)
+	}
+}
+
+// Два инстанса не публикуют одно событие, а обработанные события удаляются после retention
+
+func TestRelayLeaseAndCleanup(t *testing.T) {
+	ctx := context.Background()
+	st := memory.NewStorage()
+	s := service.NewService(st)
+
+	for _, name := range []string{"Netflix", "Spotify"} {
+		if _, err := s.CreateSub(ctx, models.Subscription{ServiceName: name, Price: 400, UserId: userId, StartDate: "2025-07-01"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Первый инстанс взял события и завис, не успев опубликовать
+	if events, err := st.ClaimEventsRequest(ctx, time.Now(), cfg.Lease, cfg.BatchSize); err != nil || len(events) != 2 {
+		t.Fatalf("claimed %+v, error %v", events, err)
+	}
+
+	pub := outbox.NewMemory()
+	r := outbox.NewRelay(st, pub, cfg)
+	if n, err := r.Process(ctx); err != nil || n != 0 {
+		t.Fatalf("leased events were published: %d, error %v", n, err)
+	}
+
+	r.SetNow(func() time.Time { return time.Now().Add(cfg.Lease) })
+	if n, err := r.Process(ctx); err != nil || n != 2 {
+		t.Fatalf("published %d after lease, error %v", n, err)
+	}
+
+	// Пока события не разосланы на вебхуки, они остаются в outbox
+	r.SetNow(func() time.Time { return time.Now().Add(2 * cfg.Retention) })
+	if n, err := r.Cleanup(ctx); err != nil || n != 0 {
+		t.Fatalf("undispatched events were deleted: %d, error %v", n, err)
+	}
+	if _, err := st.DispatchEventsRequest(ctx, cfg.BatchSize); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := r.Cleanup(ctx); err != nil || n != 2 {
+		t.Fatalf("deleted %d events, error %v", n, err)
+	}
+}
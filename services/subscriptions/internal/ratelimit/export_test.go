@@ -0,0 +1,14 @@
+package ratelimit
+
+import "time"
+
+func (m *Memory) SetClock(now func() time.Time) {
+	m.now = now
+}
+
+func (m *Memory) Len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return len(m.buckets)
+}
@@ -0,0 +1,46 @@
+package ratelimit_test
+
+import (
+	"context"
+	"subscriptions/internal/ratelimit"
+	"testing"
+	"time"
+)
+
+func TestMemory(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2025, 7, 1, 0, 0, 0, 0, time.UTC)
+
+	m := ratelimit.NewMemory()
+	m.SetClock(func() time.Time { return now })
+
+	limit := ratelimit.Limit{Rate: 1, Burst: 2}
+
+	for i := 0; i < 2; i++ {
+		if d, _ := m.Allow(ctx, "user", limit); !d.Allowed {
+			t.Fatalf("request %d denied within burst", i+1)
+		}
+	}
+
+	d, _ := m.Allow(ctx, "user", limit)
+	if d.Allowed || d.Remaining != 0 || d.RetryAfter != time.Second {
+		t.Fatalf("over burst: %+v", d)
+	}
+
+	if d, _ := m.Allow(ctx, "other", limit); !d.Allowed {
+		t.Fatal("other key must have its own bucket")
+	}
+
+	now = now.Add(time.Second)
+
+	if d, _ := m.Allow(ctx, "user", limit); !d.Allowed {
+		t.Fatal("token must be refilled after 1s")
+	}
+
+	now = now.Add(time.Hour)
+	m.Allow(ctx, "user", limit)
+
+	if m.Len() != 1 {
+		t.Fatalf("buckets = %d, want idle bucket swept", m.Len())
+	}
+}
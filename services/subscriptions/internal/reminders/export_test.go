@@ -0,0 +1,7 @@
+package reminders
+
+import "time"
+
+func (s *Scheduler) SetNow(now func() time.Time) {
+	s.now = now
+}
@@ -0,0 +1,156 @@
+package reminders
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"subscriptions/internal/config"
+	"subscriptions/internal/models"
+	"time"
+)
+
+// Виды напоминаний
+
+const (
+	KindRenewal = "renewal"
+	KindEnding  = "ending"
+)
+
+const dateLayout = "2006-01-02"
+
+// Подписки и отметки об отправленных напоминаниях. Отметка ставится до отправки,
+// поэтому несколько инстансов с общей базой не отправят одно напоминание дважды
+
+type Store interface {
+	ActiveSubsRequest(ctx context.Context, from time.Time, to time.Time) ([]models.ActiveSubscription, error)
+	ClaimReminderRequest(ctx context.Context, r models.Reminder) (bool, error)
+	ReleaseReminderRequest(ctx context.Context, r models.Reminder) error
+}
+
+type Notifier interface {
+	Notify(ctx context.Context, r models.Reminder) error
+}
+
+type Scheduler struct {
+	store    Store
+	notifier Notifier
+	interval time.Duration
+	window   time.Duration
+	now      func() time.Time
+}
+
+func NewScheduler(store Store, notifier Notifier, cfg config.RemindersConfig) *Scheduler {
+	return &Scheduler{
+		store:    store,
+		notifier: notifier,
+		interval: cfg.Interval,
+		window:   cfg.Window,
+		now:      time.Now,
+	}
+}
+
+// Сканирует подписки сразу и затем раз в interval, пока не отменён ctx
+
+func (s *Scheduler) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		if n, err := s.Scan(ctx); err != nil {
+			slog.ErrorContext(ctx, "Scheduler: error during reminders scan", "error", err, "sent", n)
+		} else if n > 0 {
+			slog.InfoContext(ctx, "reminders sent", "count", n)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Один проход: находит продления и окончания от начала текущего дня до now + window
+// и отправляет ещё не отправленные напоминания. Возвращает число отправленных
+
+func (s *Scheduler) Scan(ctx context.Context) (int, error) {
+	now := s.now().UTC()
+	from := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	to := now.Add(s.window)
+
+	subs, err := s.store.ActiveSubsRequest(ctx, from, to)
+	if err != nil {
+		return 0, err
+	}
+
+	sent := 0
+	var errs []error
+	for _, sub := range subs {
+		for _, r := range due(sub, from, to) {
+			claimed, err := s.store.ClaimReminderRequest(ctx, r)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			if !claimed {
+				continue
+			}
+
+			if err := s.notifier.Notify(ctx, r); err != nil {
+				slog.ErrorContext(ctx, "Scheduler: error during reminder notification", "error", err, "subscription_id", r.SubscriptionId, "kind", r.Kind)
+				// Снимаем отметку, чтобы повторить на следующем проходе
+				if err := s.store.ReleaseReminderRequest(ctx, r); err != nil {
+					errs = append(errs, err)
+				}
+				errs = append(errs, err)
+				continue
+			}
+			sent++
+		}
+	}
+
+	return sent, errors.Join(errs...)
+}
+
+// Подписка продлевается каждый месяц в день начала, в коротких месяцах — в последний день.
+// Продления в день окончания нет, вместо него напоминание об окончании
+
+func due(sub models.ActiveSubscription, from time.Time, to time.Time) []models.Reminder {
+	var reminders []models.Reminder
+
+	reminder := func(kind string, date time.Time) models.Reminder {
+		return models.Reminder{
+			Kind:           kind,
+			SubscriptionId: sub.Id,
+			UserId:         sub.UserId,
+			ServiceName:    sub.ServiceName,
+			Price:          sub.Price,
+			Date:           date.Format(dateLayout),
+		}
+	}
+
+	start := sub.StartDate.UTC()
+	n := max(1, (from.Year()-start.Year())*12+int(from.Month()-start.Month()))
+	renewal := addMonths(start, n)
+	if renewal.Before(from) {
+		renewal = addMonths(start, n+1)
+	}
+	if !renewal.After(to) && (sub.EndDate == nil || renewal.Before(*sub.EndDate)) {
+		reminders = append(reminders, reminder(KindRenewal, renewal))
+	}
+
+	if sub.EndDate != nil && !sub.EndDate.Before(from) && !sub.EndDate.After(to) {
+		reminders = append(reminders, reminder(KindEnding, sub.EndDate.UTC()))
+	}
+
+	return reminders
+}
+
+// Дата через n месяцев после t с тем же днём, но не позже конца месяца
+
+func addMonths(t time.Time, n int) time.Time {
+	first := time.Date(t.Year(), t.Month()+time.Month(n), 1, 0, 0, 0, 0, time.UTC)
+	last := first.AddDate(0, 1, -1).Day()
+
+	return time.Date(first.Year(), first.Month(), min(t.Day(), last), 0, 0, 0, 0, time.UTC)
+}
@@ -0,0 +1,76 @@
+package reminders_test
+
+import (
+	"context"
+	"errors"
+	"subscriptions/internal/config"
+	"subscriptions/internal/models"
+	"subscriptions/internal/reminders"
+	"subscriptions/internal/storage/memory"
+	"testing"
+	"time"
+)
+
+const userId = "60601fee-2bf1-4721-ae6f-7636e79a0cba"
+
+type recorder struct {
+	sent []models.Reminder
+	err  error
+}
+
+func (r *recorder) Notify(ctx context.Context, rem models.Reminder) error {
+	if r.err != nil {
+		return r.err
+	}
+	r.sent = append(r.sent, rem)
+	return nil
+}
+
+func TestScan(t *testing.T) {
+	ctx := context.Background()
+	st := memory.NewStorage()
+
+	subs := []models.Subscription{
+		{ServiceName: "Netflix", Price: 400, UserId: userId, StartDate: "2025-01-31"},                        // продление 2025-02-28
+		{ServiceName: "Spotify", Price: 200, UserId: userId, StartDate: "2024-12-01", EndDate: "2025-03-01"}, // окончание 2025-03-01
+		{ServiceName: "Yandex", Price: 300, UserId: userId, StartDate: "2025-01-15"},                         // продление 2025-03-15, вне окна
+	}
+	for _, sub := range subs {
+		if _, err := st.CreateSubRequest(ctx, sub); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	n := &recorder{err: errors.New("unavailable")}
+	s := reminders.NewScheduler(st, n, config.RemindersConfig{Interval: time.Hour, Window: 72 * time.Hour})
+	s.SetNow(func() time.Time { return time.Date(2025, 2, 27, 9, 0, 0, 0, time.UTC) })
+
+	// Ошибка отправки снимает отметку, напоминания уйдут на следующем проходе
+	if sent, err := s.Scan(ctx); err == nil || sent != 0 {
+		t.Fatalf("failing notifier: sent %d, error %v", sent, err)
+	}
+
+	n.err = nil
+	sent, err := s.Scan(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sent != 2 {
+		t.Fatalf("sent %d reminders, want 2: %+v", sent, n.sent)
+	}
+
+	want := []models.Reminder{
+		{Kind: reminders.KindRenewal, SubscriptionId: 1, UserId: userId, ServiceName: "Netflix", Price: 400, Date: "2025-02-28"},
+		{Kind: reminders.KindEnding, SubscriptionId: 2, UserId: userId, ServiceName: "Spotify", Price: 200, Date: "2025-03-01"},
+	}
+	for i, r := range want {
+		if n.sent[i] != r {
+			t.Fatalf("reminder %d: got %+v, want %+v", i, n.sent[i], r)
+		}
+	}
+
+	// Повторный проход ничего не отправляет
+	if sent, err := s.Scan(ctx); err != nil || sent != 0 {
+		t.Fatalf("second scan: sent %d, error %v", sent, err)
+	}
+}
@@ -2,7 +2,10 @@ package router
 
 import (
 	"net/http"
+	"subscriptions/internal/metrics"
 	"subscriptions/internal/middleware"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
 )
 
 type Handlers interface {
@@ -12,52 +15,137 @@ type Handlers interface {
 	UpdateSub(w http.ResponseWriter, r *http.Request)
 	DeleteSub(w http.ResponseWriter, r *http.Request)
 	ShowSubscSum(w http.ResponseWriter, r *http.Request)
+	Summary(w http.ResponseWriter, r *http.Request)
+}
+
+// Новые возможности добавляются только в v2
+
+type HandlersV2 interface {
+	Handlers
+	MonthlySpending(w http.ResponseWriter, r *http.Request)
+	Forecast(w http.ResponseWriter, r *http.Request)
+	CreateBudget(w http.ResponseWriter, r *http.Request)
+	ReadBudget(w http.ResponseWriter, r *http.Request)
+	ReadBudgets(w http.ResponseWriter, r *http.Request)
+	UpdateBudget(w http.ResponseWriter, r *http.Request)
+	DeleteBudget(w http.ResponseWriter, r *http.Request)
+	ReadAlerts(w http.ResponseWriter, r *http.Request)
+	CreateWebhook(w http.ResponseWriter, r *http.Request)
+	ReadWebhook(w http.ResponseWriter, r *http.Request)
+	ReadWebhooks(w http.ResponseWriter, r *http.Request)
+	DeleteWebhook(w http.ResponseWriter, r *http.Request)
+	ReadDeliveries(w http.ResponseWriter, r *http.Request)
+	ReadCatalog(w http.ResponseWriter, r *http.Request)
+	ReadCatalogEntry(w http.ResponseWriter, r *http.Request)
+	CreateCatalogEntry(w http.ResponseWriter, r *http.Request)
+	UpdateCatalogEntry(w http.ResponseWriter, r *http.Request)
+	DeleteCatalogEntry(w http.ResponseWriter, r *http.Request)
 }
+
 type Router struct {
-	r Handlers
+	v1 Handlers
+	v2 HandlersV2
 }
 
-func NewRouter(a Handlers) *Router {
+func NewRouter(v1 Handlers, v2 HandlersV2) *Router {
 	return &Router{
-		r: a,
+		v1: v1,
+		v2: v2,
 	}
 }
 
+// Дата объявления устаревшими v1 и POST .../sum/{service} и дата их отключения (RFC 9745 и RFC 8594)
+
+const (
+	deprecationDate = "@1792368000" // 2026-10-19
+	sunsetDate      = "Mon, 19 Apr 2027 00:00:00 GMT"
+)
+
+// Метод входит в шаблон маршрута: на известный путь с другим методом ServeMux сам отвечает 405 с заголовком Allow,
+// на неизвестный путь, в том числе /subscriptions/1/extra, — 404.
+// v1 доступна и под /api/v1, и по старым путям без префикса, обе с заголовками об устаревании
+
 func (router *Router) InitRoutes(mux *http.ServeMux) {
-	mux.HandleFunc("/subscriptions", func(w http.ResponseWriter, r *http.Request) {
-		switch r.Method {
-		case http.MethodGet:
-			router.r.ReadSubs(w, r)
-		case http.MethodPost:
-			router.r.CreateSub(w, r)
-		default:
-			http.Error(w, "this method are not allowed on this path", http.StatusForbidden)
-		}
-	})
-
-	mux.HandleFunc("/subscriptions/", func(w http.ResponseWriter, r *http.Request) {
-		switch r.Method {
-		case http.MethodDelete:
-			router.r.DeleteSub(w, r)
-		case http.MethodPut:
-			router.r.UpdateSub(w, r)
-		case http.MethodGet:
-			router.r.ReadSub(w, r)
-		default:
-			http.Error(w, "this method are not allowed on this path", http.StatusForbidden)
-		}
-	})
-	mux.HandleFunc("/subscriptions/sum/", func(w http.ResponseWriter, r *http.Request) {
-		switch r.Method {
-		case http.MethodPost:
-			router.r.ShowSubscSum(w, r)
-		default:
-			http.Error(w, "this method are not allowed on this path", http.StatusForbidden)
+	v1 := deprecated("/api/v2/subscriptions")
+	for _, prefix := range []string{"", "/api/v1"} {
+		routes(mux, prefix, router.v1, v1, v1)
+	}
+
+	// В v2 устарел только POST суммы: его заменяет GET /summary
+	routes(mux, "/api/v2", router.v2, nil, deprecated("/api/v2/subscriptions/summary"))
+	mux.HandleFunc("GET /api/v2/subscriptions/analytics/monthly", router.v2.MonthlySpending)
+	mux.HandleFunc("GET /api/v2/subscriptions/forecast", router.v2.Forecast)
+
+	mux.HandleFunc("GET /api/v2/budgets", router.v2.ReadBudgets)
+	mux.HandleFunc("POST /api/v2/budgets", router.v2.CreateBudget)
+	mux.HandleFunc("GET /api/v2/budgets/alerts", router.v2.ReadAlerts)
+	mux.HandleFunc("GET /api/v2/budgets/{id}", router.v2.ReadBudget)
+	mux.HandleFunc("PUT /api/v2/budgets/{id}", router.v2.UpdateBudget)
+	mux.HandleFunc("DELETE /api/v2/budgets/{id}", router.v2.DeleteBudget)
+
+	mux.HandleFunc("GET /api/v2/webhooks", router.v2.ReadWebhooks)
+	mux.HandleFunc("POST /api/v2/webhooks", router.v2.CreateWebhook)
+	mux.HandleFunc("GET /api/v2/webhooks/{id}", router.v2.ReadWebhook)
+	mux.HandleFunc("DELETE /api/v2/webhooks/{id}", router.v2.DeleteWebhook)
+	mux.HandleFunc("GET /api/v2/webhooks/{id}/deliveries", router.v2.ReadDeliveries)
+
+	// Каталог читают все, изменяет администратор: доступ к /api/v2/admin проверяет middleware.AdminAuth
+	mux.HandleFunc("GET /api/v2/services", router.v2.ReadCatalog)
+	mux.HandleFunc("GET /api/v2/services/{id}", router.v2.ReadCatalogEntry)
+	mux.HandleFunc("POST /api/v2/admin/services", router.v2.CreateCatalogEntry)
+	mux.HandleFunc("PUT /api/v2/admin/services/{id}", router.v2.UpdateCatalogEntry)
+	mux.HandleFunc("DELETE /api/v2/admin/services/{id}", router.v2.DeleteCatalogEntry)
+}
+
+func routes(mux *http.ServeMux, prefix string, h Handlers, wrap func(http.HandlerFunc) http.HandlerFunc, wrapSum func(http.HandlerFunc) http.HandlerFunc) {
+	if wrap == nil {
+		wrap = func(f http.HandlerFunc) http.HandlerFunc { return f }
+	}
+
+	mux.HandleFunc("GET "+prefix+"/subscriptions", wrap(h.ReadSubs))
+	mux.HandleFunc("POST "+prefix+"/subscriptions", wrap(h.CreateSub))
+	mux.HandleFunc("GET "+prefix+"/subscriptions/summary", wrap(h.Summary))
+	mux.HandleFunc("GET "+prefix+"/subscriptions/{id}", wrap(h.ReadSub))
+	mux.HandleFunc("PUT "+prefix+"/subscriptions/{id}", wrap(h.UpdateSub))
+	mux.HandleFunc("DELETE "+prefix+"/subscriptions/{id}", wrap(h.DeleteSub))
+	mux.HandleFunc("POST "+prefix+"/subscriptions/sum/{service}", wrapSum(h.ShowSubscSum))
+}
+
+// Заголовки об устаревании со ссылкой на замену
+
+func deprecated(successor string) func(http.HandlerFunc) http.HandlerFunc {
+	link := "<" + successor + `>; rel="successor-version"`
+
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Deprecation", deprecationDate)
+			w.Header().Set("Sunset", sunsetDate)
+			w.Header().Add("Link", link)
+			next(w, r)
 		}
-	})
+	}
 }
 
-func (router *Router) WrapMiddle(mux *http.ServeMux) http.Handler {
-	finalmux := middleware.Middleware(mux)
+// limiter может быть nil, тогда ограничение частоты запросов отключено.
+// CORS снаружи лимитера, чтобы ответ 429 тоже был доступен странице. Проверка администратора внутри лимитера,
+// чтобы подбор токена тоже ограничивался
+
+func (router *Router) WrapMiddle(mux *http.ServeMux, m *metrics.Metrics, limiter *middleware.RateLimiter, cors *middleware.CORS, admin *middleware.AdminAuth) http.Handler {
+	var finalmux http.Handler = admin.Handler(mux)
+	if limiter != nil {
+		finalmux = limiter.Handler(finalmux)
+	}
+	finalmux = cors.Handler(finalmux)
+	finalmux = middleware.Metrics(m, mux, finalmux)
+	finalmux = middleware.Logging(finalmux)
+	finalmux = middleware.RequestID(finalmux)
+	finalmux = otelhttp.NewHandler(finalmux, "http.server",
+		otelhttp.WithSpanNameFormatter(func(_ string, r *http.Request) string {
+			if _, route := mux.Handler(r); route != "" {
+				return route
+			}
+			return r.Method
+		}),
+	)
 	return finalmux
 }
@@ -1,9 +1,158 @@
 package router_test
 
-import "testing"
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"subscriptions/internal/router"
+	"testing"
+)
 
+// Заглушка обработчиков: отвечает именем вызванного метода
 
+type handlers struct{}
+
+func (handlers) CreateSub(w http.ResponseWriter, r *http.Request)    { w.Write([]byte("CreateSub")) }
+func (handlers) ReadSub(w http.ResponseWriter, r *http.Request)      { w.Write([]byte("ReadSub")) }
+func (handlers) ReadSubs(w http.ResponseWriter, r *http.Request)     { w.Write([]byte("ReadSubs")) }
+func (handlers) UpdateSub(w http.ResponseWriter, r *http.Request)    { w.Write([]byte("UpdateSub")) }
+func (handlers) DeleteSub(w http.ResponseWriter, r *http.Request)    { w.Write([]byte("DeleteSub")) }
+func (handlers) ShowSubscSum(w http.ResponseWriter, r *http.Request) { w.Write([]byte("ShowSubscSum")) }
+func (handlers) Summary(w http.ResponseWriter, r *http.Request)      { w.Write([]byte("Summary")) }
+
+// Вторая версия отвечает теми же именами с суффиксом V2
+
+type handlersV2 struct{}
+
+func (handlersV2) CreateSub(w http.ResponseWriter, r *http.Request) { w.Write([]byte("CreateSubV2")) }
+func (handlersV2) ReadSub(w http.ResponseWriter, r *http.Request)   { w.Write([]byte("ReadSubV2")) }
+func (handlersV2) ReadSubs(w http.ResponseWriter, r *http.Request)  { w.Write([]byte("ReadSubsV2")) }
+func (handlersV2) UpdateSub(w http.ResponseWriter, r *http.Request) { w.Write([]byte("UpdateSubV2")) }
+func (handlersV2) DeleteSub(w http.ResponseWriter, r *http.Request) { w.Write([]byte("DeleteSubV2")) }
+func (handlersV2) ShowSubscSum(w http.ResponseWriter, r *http.Request) {
+	w.Write([]byte("ShowSubscSumV2"))
+}
+func (handlersV2) Summary(w http.ResponseWriter, r *http.Request) { w.Write([]byte("SummaryV2")) }
+func (handlersV2) MonthlySpending(w http.ResponseWriter, r *http.Request) {
+	w.Write([]byte("MonthlySpendingV2"))
+}
+func (handlersV2) Forecast(w http.ResponseWriter, r *http.Request) { w.Write([]byte("ForecastV2")) }
+func (handlersV2) CreateBudget(w http.ResponseWriter, r *http.Request) {
+	w.Write([]byte("CreateBudgetV2"))
+}
+func (handlersV2) ReadBudget(w http.ResponseWriter, r *http.Request) { w.Write([]byte("ReadBudgetV2")) }
+func (handlersV2) ReadBudgets(w http.ResponseWriter, r *http.Request) {
+	w.Write([]byte("ReadBudgetsV2"))
+}
+func (handlersV2) UpdateBudget(w http.ResponseWriter, r *http.Request) {
+	w.Write([]byte("UpdateBudgetV2"))
+}
+func (handlersV2) DeleteBudget(w http.ResponseWriter, r *http.Request) {
+	w.Write([]byte("DeleteBudgetV2"))
+}
+func (handlersV2) ReadAlerts(w http.ResponseWriter, r *http.Request) { w.Write([]byte("ReadAlertsV2")) }
+func (handlersV2) CreateWebhook(w http.ResponseWriter, r *http.Request) {
+	w.Write([]byte("CreateWebhookV2"))
+}
+func (handlersV2) ReadWebhook(w http.ResponseWriter, r *http.Request) {
+	w.Write([]byte("ReadWebhookV2"))
+}
+func (handlersV2) ReadWebhooks(w http.ResponseWriter, r *http.Request) {
+	w.Write([]byte("ReadWebhooksV2"))
+}
+func (handlersV2) DeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	w.Write([]byte("DeleteWebhookV2"))
+}
+func (handlersV2) ReadDeliveries(w http.ResponseWriter, r *http.Request) {
+	w.Write([]byte("ReadDeliveriesV2"))
+}
+func (handlersV2) ReadCatalog(w http.ResponseWriter, r *http.Request) {
+	w.Write([]byte("ReadCatalogV2"))
+}
+func (handlersV2) ReadCatalogEntry(w http.ResponseWriter, r *http.Request) {
+	w.Write([]byte("ReadCatalogEntryV2"))
+}
+func (handlersV2) CreateCatalogEntry(w http.ResponseWriter, r *http.Request) {
+	w.Write([]byte("CreateCatalogEntryV2"))
+}
+func (handlersV2) UpdateCatalogEntry(w http.ResponseWriter, r *http.Request) {
+	w.Write([]byte("UpdateCatalogEntryV2"))
+}
+func (handlersV2) DeleteCatalogEntry(w http.ResponseWriter, r *http.Request) {
+	w.Write([]byte("DeleteCatalogEntryV2"))
+}
 
 func TestRouter(t *testing.T) {
-	
-}
\ No newline at end of file
+	mux := http.NewServeMux()
+	router.NewRouter(handlers{}, handlersV2{}).InitRoutes(mux)
+
+	tests := []struct {
+		method  string
+		path    string
+		status  int
+		handler string
+		allow   string
+	}{
+		{http.MethodGet, "/subscriptions", http.StatusOK, "ReadSubs", ""},
+		{http.MethodPost, "/subscriptions", http.StatusOK, "CreateSub", ""},
+		{http.MethodGet, "/subscriptions/1", http.StatusOK, "ReadSub", ""},
+		{http.MethodPut, "/subscriptions/1", http.StatusOK, "UpdateSub", ""},
+		{http.MethodDelete, "/subscriptions/1", http.StatusOK, "DeleteSub", ""},
+		{http.MethodPost, "/subscriptions/sum/Netflix", http.StatusOK, "ShowSubscSum", ""},
+		{http.MethodDelete, "/subscriptions", http.StatusMethodNotAllowed, "", "GET, HEAD, POST"},
+		{http.MethodPost, "/subscriptions/1", http.StatusMethodNotAllowed, "", "DELETE, GET, HEAD, PUT"},
+		{http.MethodGet, "/subscriptions/sum/Netflix", http.StatusMethodNotAllowed, "", "POST"},
+		{http.MethodGet, "/subscriptions/1/extra", http.StatusNotFound, "", ""},
+		{http.MethodGet, "/unknown", http.StatusNotFound, "", ""},
+		{http.MethodGet, "/api/v1/subscriptions/1", http.StatusOK, "ReadSub", ""},
+		{http.MethodPost, "/api/v1/subscriptions/sum/Netflix", http.StatusOK, "ShowSubscSum", ""},
+		{http.MethodGet, "/api/v2/subscriptions", http.StatusOK, "ReadSubsV2", ""},
+		{http.MethodPost, "/api/v2/subscriptions", http.StatusOK, "CreateSubV2", ""},
+		{http.MethodDelete, "/api/v2/subscriptions/1", http.StatusOK, "DeleteSubV2", ""},
+		{http.MethodPost, "/api/v2/subscriptions/sum/Netflix", http.StatusOK, "ShowSubscSumV2", ""},
+		{http.MethodPost, "/api/v2/subscriptions/1", http.StatusMethodNotAllowed, "", "DELETE, GET, HEAD, PUT"},
+		{http.MethodGet, "/api/v3/subscriptions", http.StatusNotFound, "", ""},
+		{http.MethodGet, "/subscriptions/summary?from=2025-01&to=2025-12", http.StatusOK, "Summary", ""},
+		{http.MethodGet, "/api/v2/subscriptions/summary?service=Netflix", http.StatusOK, "SummaryV2", ""},
+		{http.MethodGet, "/api/v2/subscriptions/analytics/monthly", http.StatusOK, "MonthlySpendingV2", ""},
+		{http.MethodGet, "/api/v1/subscriptions/analytics/monthly", http.StatusNotFound, "", ""},
+		{http.MethodGet, "/api/v2/subscriptions/forecast?months=6", http.StatusOK, "ForecastV2", ""},
+		{http.MethodPost, "/api/v2/budgets", http.StatusOK, "CreateBudgetV2", ""},
+		{http.MethodGet, "/api/v2/budgets/alerts", http.StatusOK, "ReadAlertsV2", ""},
+		{http.MethodPut, "/api/v2/budgets/3", http.StatusOK, "UpdateBudgetV2", ""},
+		{http.MethodPost, "/api/v2/budgets/3", http.StatusMethodNotAllowed, "", "DELETE, GET, HEAD, PUT"},
+		{http.MethodPost, "/api/v2/webhooks", http.StatusOK, "CreateWebhookV2", ""},
+		{http.MethodGet, "/api/v2/webhooks/2/deliveries", http.StatusOK, "ReadDeliveriesV2", ""},
+		{http.MethodGet, "/api/v2/services", http.StatusOK, "ReadCatalogV2", ""},
+		{http.MethodGet, "/api/v2/services/4", http.StatusOK, "ReadCatalogEntryV2", ""},
+		{http.MethodPost, "/api/v2/admin/services", http.StatusOK, "CreateCatalogEntryV2", ""},
+		{http.MethodPut, "/api/v2/admin/services/4", http.StatusOK, "UpdateCatalogEntryV2", ""},
+		{http.MethodPut, "/api/v2/services/4", http.StatusMethodNotAllowed, "", "GET, HEAD"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.path, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			mux.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.path, nil))
+
+			if rec.Code != tt.status {
+				t.Fatalf("status %d, want %d", rec.Code, tt.status)
+			}
+			if tt.handler != "" && rec.Body.String() != tt.handler {
+				t.Fatalf("handler %q, want %q", rec.Body.String(), tt.handler)
+			}
+			if got := rec.Header().Get("Allow"); got != tt.allow {
+				t.Fatalf("Allow %q, want %q", got, tt.allow)
+			}
+
+			// Ответы v1, в том числе по старым путям без префикса, и POST суммы в v2 помечены как устаревшие
+			deprecated := tt.status == http.StatusOK && (!strings.HasPrefix(tt.path, "/api/v2/") || strings.Contains(tt.path, "/sum/"))
+			if got := rec.Header().Get("Deprecation") != ""; got != deprecated {
+				t.Fatalf("Deprecation header present %v, want %v", got, deprecated)
+			}
+			if deprecated && (rec.Header().Get("Sunset") == "" || !strings.Contains(rec.Header().Get("Link"), "successor-version")) {
+				t.Fatalf("v1 response without Sunset or Link: %v", rec.Header())
+			}
+		})
+	}
+}
@@ -0,0 +1,197 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"subscriptions/internal/models"
+	"subscriptions/internal/store"
+	"time"
+)
+
+// Проверка уникальности идёт в той же транзакции, что и запись. Ограничение UNIQUE в таблице остаётся
+// последней защитой от одновременных запросов. Имя сервиса приводится к каталогу, как и у подписок,
+// иначе бюджет не совпадёт с их расходами. Записанный бюджет читается в той же транзакции, как и у подписок
+
+func (service *ServiceMethods) CreateBudget(ctx context.Context, b models.Budget) (*models.Budget, error) {
+	var created *models.Budget
+
+	err := service.s.WithTx(ctx, func(tx store.Storage) error {
+		var err error
+		if b.ServiceName, err = resolveServiceName(ctx, tx, b.ServiceName); err != nil {
+			return err
+		}
+		if err = checkBudgetUnique(ctx, tx, b); err != nil {
+			return err
+		}
+
+		id, err := tx.CreateBudgetRequest(ctx, b)
+		if err != nil {
+			return err
+		}
+		created, err = tx.ReadBudgetRequest(ctx, id)
+		return err
+	})
+
+	if err != nil {
+		slog.ErrorContext(ctx, "CreateBudget method: error", "error", err)
+		return nil, err
+	}
+
+	return created, nil
+}
+
+func (service *ServiceMethods) ReadBudget(ctx context.Context, id int) (*models.Budget, error) {
+	b, err := service.s.ReadBudgetRequest(ctx, id)
+	if err != nil {
+		slog.ErrorContext(ctx, "ReadBudget method: error", "error", err)
+		return nil, err
+	}
+
+	return b, nil
+}
+
+func (service *ServiceMethods) ReadBudgets(ctx context.Context, userId string) ([]models.Budget, error) {
+	budgets, err := service.s.ReadBudgetsRequest(ctx, userId)
+	if err != nil {
+		slog.ErrorContext(ctx, "ReadBudgets method: error", "error", err)
+		return nil, err
+	}
+
+	return budgets, nil
+}
+
+func (service *ServiceMethods) UpdateBudget(ctx context.Context, b models.Budget) (*models.Budget, error) {
+	var updated *models.Budget
+
+	err := service.s.WithTx(ctx, func(tx store.Storage) error {
+		var err error
+		if b.ServiceName, err = resolveServiceName(ctx, tx, b.ServiceName); err != nil {
+			return err
+		}
+		if err = checkBudgetUnique(ctx, tx, b); err != nil {
+			return err
+		}
+		if err = tx.UpdateBudgetRequest(ctx, b); err != nil {
+			return err
+		}
+		updated, err = tx.ReadBudgetRequest(ctx, b.Id)
+		return err
+	})
+
+	if err != nil {
+		slog.ErrorContext(ctx, "UpdateBudget method: error", "error", err)
+		return nil, err
+	}
+
+	return updated, nil
+}
+
+func (service *ServiceMethods) DeleteBudget(ctx context.Context, id int) error {
+	err := service.s.DeleteBudgetRequest(ctx, id)
+	if err != nil {
+		slog.ErrorContext(ctx, "DeleteBudget method: error", "error", err)
+		return err
+	}
+
+	return nil
+}
+
+func (service *ServiceMethods) ReadAlerts(ctx context.Context, userId string) ([]models.BudgetAlert, error) {
+	alerts, err := service.s.ReadAlertsRequest(ctx, userId)
+	if err != nil {
+		slog.ErrorContext(ctx, "ReadAlerts method: error", "error", err)
+		return nil, err
+	}
+
+	return alerts, nil
+}
+
+// У пользователя не больше одного бюджета на сервис и одного общего. Сам бюджет при обновлении не мешает
+
+func checkBudgetUnique(ctx context.Context, tx store.Storage, b models.Budget) error {
+	budgets, err := tx.ReadBudgetsRequest(ctx, b.UserId)
+	if err != nil {
+		return err
+	}
+
+	for _, other := range budgets {
+		if other.Id != b.Id && other.ServiceName == b.ServiceName {
+			return ErrBudgetExists
+		}
+	}
+
+	return nil
+}
+
+// Сравнивает бюджеты пользователя с расходами в месяце month (YYYY-MM) и записывает предупреждения
+// о превышении, снимая их с бюджетов, где расход уже в пределах лимита. Расход берётся из MonthlySpendingRequest, как в аналитике и прогнозе.
+// Возвращает предупреждения, записанные этой проверкой
+
+func (service *ServiceMethods) EvaluateBudgets(ctx context.Context, userId string, month string) ([]models.BudgetAlert, error) {
+	m, err := time.Parse("2006-01", month)
+	if err != nil {
+		return nil, fmt.Errorf("EvaluateBudgets method: %w", err)
+	}
+
+	var alerts []models.BudgetAlert
+
+	err = service.s.WithTx(ctx, func(tx store.Storage) error {
+		budgets, err := tx.ReadBudgetsRequest(ctx, userId)
+		if err != nil || len(budgets) == 0 {
+			return err
+		}
+
+		date := m.Format(time.DateOnly)
+		months, err := tx.MonthlySpendingRequest(ctx, userId, date, date)
+		if err != nil {
+			return err
+		}
+
+		// Расход по store.ServiceKey, как в аналитике. Пустой ключ — расход по всем сервисам, для общего бюджета
+		spent := map[string]int{}
+		for _, ms := range months {
+			for _, svc := range ms.Services {
+				spent[store.ServiceKey(svc.ServiceName)] += svc.Total
+			}
+			spent[""] += ms.Total
+		}
+
+		// Предупреждение за месяц, в котором расход снова уложился в лимит, больше не актуально
+		for _, b := range budgets {
+			key := store.ServiceKey(b.ServiceName)
+			if spent[key] <= b.MonthlyLimit {
+				if err := tx.DeleteAlertRequest(ctx, b.Id, month); err != nil {
+					return err
+				}
+				continue
+			}
+
+			alert := models.BudgetAlert{
+				BudgetId:     b.Id,
+				UserId:       userId,
+				ServiceName:  b.ServiceName,
+				Month:        month,
+				MonthlyLimit: b.MonthlyLimit,
+				Spent:        spent[key],
+			}
+			if err := tx.SaveAlertRequest(ctx, alert); err != nil {
+				return err
+			}
+			alerts = append(alerts, alert)
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		slog.ErrorContext(ctx, "EvaluateBudgets method: error", "error", err)
+		return nil, err
+	}
+
+	for _, a := range alerts {
+		slog.InfoContext(ctx, "budget exceeded", "budget_id", a.BudgetId, "service_name", a.ServiceName, "month", a.Month, "limit", a.MonthlyLimit, "spent", a.Spent)
+	}
+
+	return alerts, nil
+}
@@ -0,0 +1,192 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"subscriptions/internal/metrics"
+	"subscriptions/internal/models"
+	"time"
+)
+
+// Хранилище кэша. Значения передаются в JSON, поэтому реализацией может быть и Redis
+
+type Cache interface {
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	DeletePrefix(ctx context.Context, prefix string) error
+}
+
+// Кэширует списки, суммы, помесячные расходы и прогноз пользователя поверх Service. Ключи начинаются с id пользователя,
+// поэтому любое изменение его подписок сбрасывает все его запросы одним DeletePrefix.
+// Чтение, начатое до записи, может положить в кэш старые данные уже после сброса, это ограничено ttl
+
+type CachedService struct {
+	Service
+	c   Cache
+	ttl time.Duration
+	m   *metrics.Metrics
+}
+
+func NewCachedService(s Service, c Cache, ttl time.Duration, m *metrics.Metrics) *CachedService {
+	return &CachedService{Service: s, c: c, ttl: ttl, m: m}
+}
+
+func userPrefix(userId string) string {
+	return "subs:" + userId + ":"
+}
+
+func (cs *CachedService) ReadSubs(ctx context.Context, userId string) ([]models.Subscription, error) {
+	return cached(cs, ctx, "read_subs", userPrefix(userId)+"all", func() ([]models.Subscription, error) {
+		return cs.Service.ReadSubs(ctx, userId)
+	})
+}
+
+func (cs *CachedService) ShowSubscSum(ctx context.Context, serviceName string, userId string, startPeriod string, EndPeriod string) (*models.SubscriptionSum, error) {
+	key := userPrefix(userId) + "sum:" + strings.Join([]string{serviceName, startPeriod, EndPeriod}, "|")
+
+	return cached(cs, ctx, "show_subs_sum", key, func() (*models.SubscriptionSum, error) {
+		return cs.Service.ShowSubscSum(ctx, serviceName, userId, startPeriod, EndPeriod)
+	})
+}
+
+func (cs *CachedService) MonthlySpending(ctx context.Context, userId string, startPeriod string, endPeriod string) ([]models.MonthlySpending, error) {
+	key := userPrefix(userId) + "monthly:" + startPeriod + "|" + endPeriod
+
+	return cached(cs, ctx, "monthly_spending", key, func() ([]models.MonthlySpending, error) {
+		return cs.Service.MonthlySpending(ctx, userId, startPeriod, endPeriod)
+	})
+}
+
+func (cs *CachedService) Forecast(ctx context.Context, userId string, startPeriod string, endPeriod string) (*models.Forecast, error) {
+	key := userPrefix(userId) + "forecast:" + startPeriod + "|" + endPeriod
+
+	return cached(cs, ctx, "forecast", key, func() (*models.Forecast, error) {
+		return cs.Service.Forecast(ctx, userId, startPeriod, endPeriod)
+	})
+}
+
+func (cs *CachedService) CreateSub(ctx context.Context, sub models.Subscription) (*models.Subscription, error) {
+	created, err := cs.Service.CreateSub(ctx, sub)
+	if err != nil {
+		return nil, err
+	}
+
+	cs.invalidate(ctx, created.UserId)
+
+	return created, nil
+}
+
+// Подписка может перейти к другому пользователю, поэтому сбрасываются запросы и прежнего, и нового владельца
+
+func (cs *CachedService) UpdateSub(ctx context.Context, sub models.Subscription) (*models.Subscription, string, error) {
+	updated, prevUserId, err := cs.Service.UpdateSub(ctx, sub)
+	if err != nil {
+		return nil, "", err
+	}
+
+	cs.invalidate(ctx, prevUserId, updated.UserId)
+
+	return updated, prevUserId, nil
+}
+
+func (cs *CachedService) DeleteSub(ctx context.Context, id int) (*models.Subscription, error) {
+	deleted, err := cs.Service.DeleteSub(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	cs.invalidate(ctx, deleted.UserId)
+
+	return deleted, nil
+}
+
+// Изменение каталога переименовывает подписки любых пользователей, поэтому сбрасывается весь кэш
+
+func (cs *CachedService) CreateCatalogEntry(ctx context.Context, e models.CatalogEntry) (*models.CatalogEntry, error) {
+	created, err := cs.Service.CreateCatalogEntry(ctx, e)
+	if err != nil {
+		return nil, err
+	}
+
+	cs.invalidateAll(ctx)
+
+	return created, nil
+}
+
+func (cs *CachedService) UpdateCatalogEntry(ctx context.Context, e models.CatalogEntry) (*models.CatalogEntry, error) {
+	updated, err := cs.Service.UpdateCatalogEntry(ctx, e)
+	if err != nil {
+		return nil, err
+	}
+
+	cs.invalidateAll(ctx)
+
+	return updated, nil
+}
+
+func (cs *CachedService) DeleteCatalogEntry(ctx context.Context, id int) error {
+	if err := cs.Service.DeleteCatalogEntry(ctx, id); err != nil {
+		return err
+	}
+
+	cs.invalidateAll(ctx)
+
+	return nil
+}
+
+func (cs *CachedService) invalidateAll(ctx context.Context) {
+	if err := cs.c.DeletePrefix(ctx, "subs:"); err != nil {
+		slog.WarnContext(ctx, "cache: error during invalidation", "error", err)
+	}
+}
+
+func (cs *CachedService) invalidate(ctx context.Context, userIds ...string) {
+	for _, userId := range userIds {
+		if userId == "" {
+			continue
+		}
+		if err := cs.c.DeletePrefix(ctx, userPrefix(userId)); err != nil {
+			slog.WarnContext(ctx, "cache: error during invalidation", "user_id", userId, "error", err)
+		}
+	}
+}
+
+// Ошибка кэша не ломает запрос: данные читаются из сервиса, как без кэша
+
+func cached[T any](cs *CachedService, ctx context.Context, query string, key string, load func() (T, error)) (T, error) {
+	data, ok, err := cs.c.Get(ctx, key)
+	if err != nil {
+		slog.WarnContext(ctx, "cache: error during read", "key", key, "error", err)
+	}
+
+	if ok {
+		var value T
+		if err := json.Unmarshal(data, &value); err == nil {
+			cs.m.CacheRequests.WithLabelValues(query, "hit").Inc()
+			return value, nil
+		}
+	}
+
+	result := "miss"
+	if err != nil {
+		result = "error"
+	}
+	cs.m.CacheRequests.WithLabelValues(query, result).Inc()
+
+	value, err := load()
+	if err != nil {
+		return value, err
+	}
+
+	data, err = json.Marshal(value)
+	if err == nil {
+		err = cs.c.Set(ctx, key, data, cs.ttl)
+	}
+	if err != nil {
+		slog.WarnContext(ctx, "cache: error during write", "key", key, "error", err)
+	}
+
+	return value, nil
+}
@@ -0,0 +1,193 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"subscriptions/internal/models"
+	"subscriptions/internal/store"
+)
+
+// Пробелы по краям убираются, пробелы внутри имени схлопываются в один
+
+func normalizeServiceName(name string) string {
+	return strings.Join(strings.Fields(name), " ")
+}
+
+// Имя сервиса из запроса заменяется каноническим именем записи каталога, если совпадает с ним или с псевдонимом.
+// Имя не из каталога сохраняется как есть после нормализации пробелов
+
+func resolveServiceName(ctx context.Context, tx store.Storage, name string) (string, error) {
+	name = normalizeServiceName(name)
+	if name == "" {
+		return "", nil
+	}
+
+	e, err := tx.FindCatalogEntryRequest(ctx, name)
+	if errors.Is(err, ErrCatalogEntryNotFound) {
+		return name, nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	return e.Name, nil
+}
+
+// Псевдонимы без повторов и без совпадающих с именем, сравнение без учёта регистра
+
+func normalizeCatalogEntry(e models.CatalogEntry) models.CatalogEntry {
+	e.Name = normalizeServiceName(e.Name)
+	e.Category = strings.TrimSpace(e.Category)
+
+	aliases := []string{}
+	seen := map[string]bool{strings.ToLower(e.Name): true}
+	for _, a := range e.Aliases {
+		a = normalizeServiceName(a)
+		if a == "" || seen[strings.ToLower(a)] {
+			continue
+		}
+		seen[strings.ToLower(a)] = true
+		aliases = append(aliases, a)
+	}
+	e.Aliases = aliases
+
+	return e
+}
+
+// Имя и псевдонимы не должны совпадать с именем или псевдонимом другой записи, иначе разрешение имени неоднозначно.
+// Проверка идёт в транзакции записи под блокировкой каталога, уникальный индекс по имени остаётся последней защитой
+
+func checkCatalogUnique(ctx context.Context, tx store.Storage, e models.CatalogEntry) error {
+	for _, name := range append([]string{e.Name}, e.Aliases...) {
+		other, err := tx.FindCatalogEntryRequest(ctx, name)
+		if errors.Is(err, ErrCatalogEntryNotFound) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if other.Id != e.Id {
+			return fmt.Errorf("%w: %q matches %q", ErrCatalogConflict, name, other.Name)
+		}
+	}
+
+	return nil
+}
+
+// Подписки и бюджеты, записанные под именем или псевдонимом записи до её появления в каталоге,
+// переводятся на каноническое имя, иначе их суммы не сойдутся с новыми записями
+
+func renameToCatalog(ctx context.Context, tx store.Storage, e models.CatalogEntry, oldNames ...string) error {
+	var keys []string
+	for _, name := range append(append([]string{e.Name}, e.Aliases...), oldNames...) {
+		keys = append(keys, store.ServiceKey(name))
+	}
+
+	return tx.RenameServiceRequest(ctx, keys, e.Name)
+}
+
+func (service *ServiceMethods) CreateCatalogEntry(ctx context.Context, e models.CatalogEntry) (*models.CatalogEntry, error) {
+	e = normalizeCatalogEntry(e)
+
+	var created *models.CatalogEntry
+
+	err := service.s.WithTx(ctx, func(tx store.Storage) error {
+		if err := tx.LockCatalogRequest(ctx); err != nil {
+			return err
+		}
+		if err := checkCatalogUnique(ctx, tx, e); err != nil {
+			return err
+		}
+
+		id, err := tx.CreateCatalogEntryRequest(ctx, e)
+		if err != nil {
+			return err
+		}
+		if created, err = tx.ReadCatalogEntryRequest(ctx, id); err != nil {
+			return err
+		}
+		return renameToCatalog(ctx, tx, *created)
+	})
+
+	if err != nil {
+		slog.ErrorContext(ctx, "CreateCatalogEntry method: error", "error", err)
+		return nil, err
+	}
+
+	return created, nil
+}
+
+func (service *ServiceMethods) ReadCatalogEntry(ctx context.Context, id int) (*models.CatalogEntry, error) {
+	e, err := service.s.ReadCatalogEntryRequest(ctx, id)
+	if err != nil {
+		slog.ErrorContext(ctx, "ReadCatalogEntry method: error", "error", err)
+		return nil, err
+	}
+
+	return e, nil
+}
+
+func (service *ServiceMethods) ReadCatalog(ctx context.Context) ([]models.CatalogEntry, error) {
+	entries, err := service.s.ReadCatalogRequest(ctx)
+	if err != nil {
+		slog.ErrorContext(ctx, "ReadCatalog method: error", "error", err)
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+// Записи под прежним каноническим именем тоже переименовываются, под снятыми псевдонимами — нет
+
+func (service *ServiceMethods) UpdateCatalogEntry(ctx context.Context, e models.CatalogEntry) (*models.CatalogEntry, error) {
+	e = normalizeCatalogEntry(e)
+
+	var updated *models.CatalogEntry
+
+	err := service.s.WithTx(ctx, func(tx store.Storage) error {
+		if err := tx.LockCatalogRequest(ctx); err != nil {
+			return err
+		}
+		old, err := tx.ReadCatalogEntryRequest(ctx, e.Id)
+		if err != nil {
+			return err
+		}
+		if err := checkCatalogUnique(ctx, tx, e); err != nil {
+			return err
+		}
+		if err := tx.UpdateCatalogEntryRequest(ctx, e); err != nil {
+			return err
+		}
+
+		if updated, err = tx.ReadCatalogEntryRequest(ctx, e.Id); err != nil {
+			return err
+		}
+		return renameToCatalog(ctx, tx, *updated, old.Name)
+	})
+
+	if err != nil {
+		slog.ErrorContext(ctx, "UpdateCatalogEntry method: error", "error", err)
+		return nil, err
+	}
+
+	return updated, nil
+}
+
+func (service *ServiceMethods) DeleteCatalogEntry(ctx context.Context, id int) error {
+	err := service.s.WithTx(ctx, func(tx store.Storage) error {
+		if _, err := tx.ReadCatalogEntryRequest(ctx, id); err != nil {
+			return err
+		}
+		return tx.DeleteCatalogEntryRequest(ctx, id)
+	})
+
+	if err != nil {
+		slog.ErrorContext(ctx, "DeleteCatalogEntry method: error", "error", err)
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,94 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"subscriptions/internal/models"
+	"subscriptions/internal/store"
+	"time"
+)
+
+// Хранилища отдают даты с точностью до месяца в формате MM-YYYY
+
+const monthLayout = "01-2006"
+
+// Прогноз расходов с месяца startPeriod по месяц endPeriod (YYYY-MM-DD) по действующим подпискам.
+// Расходы по месяцам берутся из MonthlySpendingRequest, поэтому условие активности подписки одно с аналитикой.
+// Подписка перестаёт учитываться после месяца окончания и попадает в Expiring этого месяца.
+// С суммой ShowSubscSum за тот же период прогноз расходится намеренно: сумма берёт цену каждой подписки один раз
+// и только если подписка началась и закончилась внутри периода, поэтому бессрочные в неё не входят. Прогноз же
+// считает цену за каждый месяц, в котором подписка действует, включая бессрочные, а границы периода берёт по месяцам
+
+func (service *ServiceMethods) Forecast(ctx context.Context, userId string, startPeriod string, endPeriod string) (*models.Forecast, error) {
+	if _, err := time.Parse(time.DateOnly, startPeriod); err != nil {
+		return nil, fmt.Errorf("Forecast method: %w", err)
+	}
+	if _, err := time.Parse(time.DateOnly, endPeriod); err != nil {
+		return nil, fmt.Errorf("Forecast method: %w", err)
+	}
+
+	var months []models.MonthlySpending
+	var subs []models.Subscription
+
+	err := service.s.WithReadTx(ctx, func(tx store.Storage) error {
+		var err error
+		if months, err = tx.MonthlySpendingRequest(ctx, userId, startPeriod, endPeriod); err != nil {
+			return err
+		}
+		subs, err = tx.ReadSubsRequest(ctx, userId)
+		return err
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "Forecast method: error", "error", err)
+		return nil, err
+	}
+
+	// Подписка действует в месяце своего окончания, поэтому в Expiring попадает только то, что уже есть в расходах месяца
+	expiring := map[string][]models.ServiceSpending{}
+	for _, sub := range subs {
+		if sub.EndDate == "" {
+			continue
+		}
+		end, err := time.Parse(monthLayout, sub.EndDate)
+		if err != nil {
+			slog.ErrorContext(ctx, "Forecast method: error", "id", sub.Id, "error", err)
+			return nil, fmt.Errorf("Forecast method: %w", err)
+		}
+		month := end.Format("2006-01")
+		expiring[month] = addSpending(expiring[month], sub.ServiceName, sub.Price)
+	}
+
+	forecast := &models.Forecast{Months: []models.ForecastMonth{}}
+	for _, m := range months {
+		month := models.ForecastMonth{MonthlySpending: m, Expiring: expiring[m.Month]}
+		if month.Expiring == nil {
+			month.Expiring = []models.ServiceSpending{}
+		}
+
+		forecast.Total += m.Total
+		forecast.Months = append(forecast.Months, month)
+	}
+
+	return forecast, nil
+}
+
+// Добавляет сумму к сервису, сохраняя порядок по store.ServiceKey, как в ответе аналитики.
+// Из имён одного сервиса, как и там, остаётся наименьшее
+
+func addSpending(services []models.ServiceSpending, name string, cost int) []models.ServiceSpending {
+	name = strings.TrimSpace(name)
+	key := store.ServiceKey(name)
+
+	i, found := slices.BinarySearchFunc(services, key, func(s models.ServiceSpending, key string) int {
+		return strings.Compare(store.ServiceKey(s.ServiceName), key)
+	})
+	if found {
+		services[i].Total += cost
+		services[i].ServiceName = min(services[i].ServiceName, name)
+		return services
+	}
+	return slices.Insert(services, i, models.ServiceSpending{ServiceName: name, Total: cost})
+}
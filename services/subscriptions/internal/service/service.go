@@ -1,91 +1,169 @@
 package service
 
 import (
-	"log"
+	"context"
+	"log/slog"
 	"subscriptions/internal/models"
+	"subscriptions/internal/store"
 )
 
+// Ошибки хранилища сервис отдаёт наверх как есть
 
-type Storage interface {
-	CreateSubRequest(sub models.Subscription)(string, error)
-	ReadSubRequest(id int) (*models.Subscription, error)
-	ReadSubsRequest(userId string) ([]models.Subscription, error)
-	UpdateSubRequest(sub models.Subscription) error
-	DeleteSubRequest(id int) error
-	ShowSubscSumRequest(serviceName string, userId string, startPeriod string, EndPeriod string) ([]models.Subscription, error)
-}
+var (
+	ErrNotFound             = store.ErrNotFound
+	ErrBudgetNotFound       = store.ErrBudgetNotFound
+	ErrWebhookNotFound      = store.ErrWebhookNotFound
+	ErrCatalogEntryNotFound = store.ErrCatalogEntryNotFound
+	ErrBudgetExists         = store.ErrBudgetExists
+	ErrCatalogConflict      = store.ErrCatalogConflict
+)
 
 type ServiceMethods struct {
-	s Storage
+	s store.Storage
 }
 
-func NewService(a Storage) *ServiceMethods {
+func NewService(a store.Storage) *ServiceMethods {
 	return &ServiceMethods{
 		s: a,
 	}
 }
 
-func (service *ServiceMethods) CreateSub(sub models.Subscription)(string, error) {
-	userId, err := service.s.CreateSubRequest(sub)
+// Изменения подписок и их события в outbox пишутся в одной транзакции.
+// Имя сервиса перед записью приводится к имени из каталога. Созданная запись читается в той же транзакции,
+// то есть с основной базы, а не с реплики, которая могла её ещё не получить
+
+func (service *ServiceMethods) CreateSub(ctx context.Context, sub models.Subscription) (*models.Subscription, error) {
+	var created *models.Subscription
+
+	err := service.s.WithTx(ctx, func(tx store.Storage) error {
+		var err error
+		if sub.ServiceName, err = resolveServiceName(ctx, tx, sub.ServiceName); err != nil {
+			return err
+		}
+		id, err := tx.CreateSubRequest(ctx, sub)
+		if err != nil {
+			return err
+		}
+		if created, err = tx.ReadSubRequest(ctx, id); err != nil {
+			return err
+		}
+		return addEvent(ctx, tx, EventSubCreated, *created)
+	})
+
 	if err != nil {
-		log.Print(err.Error(), "CreateSub method")
-		return "", err
+		slog.ErrorContext(ctx, "CreateSub method: error", "error", err)
+		return nil, err
 	}
-	return userId, nil
+	return created, nil
 }
 
-func (service *ServiceMethods) ReadSub(id int) (*models.Subscription, error) {
-	sub, err := service.s.ReadSubRequest(id)
+func (service *ServiceMethods) ReadSub(ctx context.Context, id int) (*models.Subscription, error) {
+	sub, err := service.s.ReadSubRequest(ctx, id)
 
 	if err != nil {
-		log.Printf("ReadSub method: error:%v", err.Error())
+		slog.ErrorContext(ctx, "ReadSub method: error", "error", err)
 		return nil, err
 	}
 
 	return sub, nil
 }
 
-func (service *ServiceMethods) ReadSubs(userId string) ([]models.Subscription, error) {
-	subs, err := service.s.ReadSubsRequest(userId)
+func (service *ServiceMethods) ReadSubs(ctx context.Context, userId string) ([]models.Subscription, error) {
+	subs, err := service.s.ReadSubsRequest(ctx, userId)
 
 	if err != nil {
-		log.Printf("ReadSubs method: error:%v", err.Error())
+		slog.ErrorContext(ctx, "ReadSubs method: error", "error", err)
 		return nil, err
 	}
 
 	return subs, nil
 }
 
-func (service *ServiceMethods) UpdateSub(sub models.Subscription) error {
-	err := service.s.UpdateSubRequest(sub)
+// Отсутствие записи определяет сам UPDATE, отдельной проверки перед ним нет.
+// Кроме новой записи возвращает её владельца до обновления: подписка может перейти к другому пользователю
+
+func (service *ServiceMethods) UpdateSub(ctx context.Context, sub models.Subscription) (*models.Subscription, string, error) {
+	var updated *models.Subscription
+	var prevUserId string
+
+	err := service.s.WithTx(ctx, func(tx store.Storage) error {
+		var err error
+		if sub.ServiceName, err = resolveServiceName(ctx, tx, sub.ServiceName); err != nil {
+			return err
+		}
+		if updated, prevUserId, err = tx.UpdateSubRequest(ctx, sub); err != nil {
+			return err
+		}
+		return addEvent(ctx, tx, EventSubUpdated, *updated)
+	})
 
 	if err != nil {
-		log.Printf("UpdateSub method: error:%v", err.Error())
-		return err
+		slog.ErrorContext(ctx, "UpdateSub method: error", "error", err)
+		return nil, "", err
 	}
 
-	return nil
+	return updated, prevUserId, nil
 }
 
-func (service *ServiceMethods) DeleteSub(id int) error {
-	err := service.s.DeleteSubRequest(id)
+// Событие об удалении пишется до удаления, чтобы в нём была удаляемая подписка.
+// Если запись удалили между чтением и DELETE, транзакция откатывается вместе с событием
+
+func (service *ServiceMethods) DeleteSub(ctx context.Context, id int) (*models.Subscription, error) {
+	var deleted *models.Subscription
+
+	err := service.s.WithTx(ctx, func(tx store.Storage) error {
+		var err error
+		if deleted, err = tx.ReadSubRequest(ctx, id); err != nil {
+			return err
+		}
+		if err = addEvent(ctx, tx, EventSubDeleted, *deleted); err != nil {
+			return err
+		}
+		return tx.DeleteSubRequest(ctx, id)
+	})
 
 	if err != nil {
-		log.Printf("DeleteSub method: error:%v", err.Error())
-		return err
+		slog.ErrorContext(ctx, "DeleteSub method: error", "error", err)
+		return nil, err
 	}
 
-	return nil
+	return deleted, nil
 }
 
-func (service *ServiceMethods) ShowSubscSum(serviceName string, userId string, startPeriod string, EndPeriod string) ([]models.Subscription, error) {
+// Список подписок и итоговая сумма читаются в одной транзакции, чтобы сумма совпадала со строками.
+// Пустой serviceName означает все сервисы пользователя, псевдоним из каталога заменяется каноническим именем
 
-	subs, err := service.s.ShowSubscSumRequest(serviceName, userId, startPeriod, EndPeriod)
+func (service *ServiceMethods) ShowSubscSum(ctx context.Context, serviceName string, userId string, startPeriod string, EndPeriod string) (*models.SubscriptionSum, error) {
+	var sum *models.SubscriptionSum
+
+	err := service.s.WithReadTx(ctx, func(tx store.Storage) error {
+		name, err := resolveServiceName(ctx, tx, serviceName)
+		if err != nil {
+			return err
+		}
+		sum, err = tx.ShowSubscSumRequest(ctx, name, userId, startPeriod, EndPeriod)
+		return err
+	})
 
 	if err != nil {
-		log.Printf("ShowSubscSum method: error:%v", err.Error())
+		slog.ErrorContext(ctx, "ShowSubscSum method: error", "error", err)
 		return nil, err
 	}
 
-	return subs, nil
+	// Пустой период отдаётся как [], а не null
+	if sum.Items == nil {
+		sum.Items = []models.Subscription{}
+	}
+
+	return sum, nil
+}
+
+func (service *ServiceMethods) MonthlySpending(ctx context.Context, userId string, startPeriod string, endPeriod string) ([]models.MonthlySpending, error) {
+	months, err := service.s.MonthlySpendingRequest(ctx, userId, startPeriod, endPeriod)
+	if err != nil {
+		slog.ErrorContext(ctx, "MonthlySpending method: error", "error", err)
+		return nil, err
+	}
+
+	return months, nil
 }
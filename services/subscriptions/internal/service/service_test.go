@@ -1,2 +1,341 @@
 package service_test
 
+import (
+	"context"
+	"errors"
+	"slices"
+	"subscriptions/internal/cache"
+	"subscriptions/internal/metrics"
+	"subscriptions/internal/models"
+	"subscriptions/internal/service"
+	"subscriptions/internal/storage/memory"
+	"testing"
+	"time"
+)
+
+const userId = "60601fee-2bf1-4721-ae6f-7636e79a0cba"
+
+func TestShowSubscSum(t *testing.T) {
+	ctx := context.Background()
+	s := service.NewService(memory.NewStorage())
+
+	subs := []models.Subscription{
+		{ServiceName: "Netflix", Price: 400, UserId: userId, StartDate: "2025-01-01", EndDate: "2025-03-01"},
+		{ServiceName: "Netflix", Price: 500, UserId: userId, StartDate: "2025-04-01", EndDate: "2025-06-01"},
+		{ServiceName: "Netflix", Price: 700, UserId: userId, StartDate: "2025-06-01"},                        // без даты окончания
+		{ServiceName: "Netflix", Price: 900, UserId: userId, StartDate: "2024-12-01", EndDate: "2025-02-01"}, // начало до периода
+		{ServiceName: "Spotify", Price: 200, UserId: userId, StartDate: "2025-01-01", EndDate: "2025-02-01"},
+		{ServiceName: "Netflix", Price: 300, UserId: "0f6a6a54-5c1c-4b38-9a4e-0c1a2b3c4d5e", StartDate: "2025-01-01", EndDate: "2025-02-01"},
+	}
+
+	for _, sub := range subs {
+		if _, err := s.CreateSub(ctx, sub); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got, err := s.ShowSubscSum(ctx, "Netflix", userId, "2025-01-01", "2025-12-31")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(got.Items) != 2 {
+		t.Fatalf("got %d subscriptions, want 2: %+v", len(got.Items), got)
+	}
+
+	if got.Total != 900 {
+		t.Fatalf("total = %d, want 900", got.Total)
+	}
+}
+
+func TestCachedService(t *testing.T) {
+	ctx := context.Background()
+	st := memory.NewStorage()
+	s := service.NewCachedService(service.NewService(st), cache.NewLRU(10), time.Minute, metrics.NewMetrics())
+
+	sub := models.Subscription{ServiceName: "Netflix", Price: 400, UserId: userId, StartDate: "2025-01-01", EndDate: "2025-03-01"}
+
+	if _, err := s.CreateSub(ctx, sub); err != nil {
+		t.Fatal(err)
+	}
+	if subs, _ := s.ReadSubs(ctx, userId); len(subs) != 1 {
+		t.Fatalf("got %d subscriptions, want 1", len(subs))
+	}
+
+	// Запись мимо сервиса не сбрасывает кэш
+	if _, err := st.CreateSubRequest(ctx, sub); err != nil {
+		t.Fatal(err)
+	}
+	if subs, _ := s.ReadSubs(ctx, userId); len(subs) != 1 {
+		t.Fatalf("got %d subscriptions, want cached 1", len(subs))
+	}
+
+	if _, err := s.DeleteSub(ctx, 1); err != nil {
+		t.Fatal(err)
+	}
+	if subs, _ := s.ReadSubs(ctx, userId); len(subs) != 1 || subs[0].Id != 2 {
+		t.Fatalf("after delete: got %+v, want only subscription 2", subs)
+	}
+
+	// Передача подписки другому пользователю сбрасывает кэш прежнего владельца
+	sub.Id, sub.UserId = 2, "0f6a6a54-5c1c-4b38-9a4e-0c1a2b3c4d5e"
+	if _, prevUserId, err := s.UpdateSub(ctx, sub); err != nil || prevUserId != userId {
+		t.Fatalf("update: previous owner %q, err %v", prevUserId, err)
+	}
+	if subs, _ := s.ReadSubs(ctx, userId); len(subs) != 0 {
+		t.Fatalf("after owner change: got %+v, want none", subs)
+	}
+
+	// Удаление записи каталога меняет разрешение псевдонимов, поэтому сбрасывает суммы всех пользователей
+	sub.Id, sub.UserId = 0, userId
+	if _, err := s.CreateSub(ctx, sub); err != nil {
+		t.Fatal(err)
+	}
+	entry, err := s.CreateCatalogEntry(ctx, models.CatalogEntry{Name: "Netflix", Aliases: []string{"nflx"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sum, err := s.ShowSubscSum(ctx, "nflx", userId, "2025-01-01", "2025-12-31"); err != nil || len(sum.Items) != 1 {
+		t.Fatalf("sum by alias: %+v, err %v", sum, err)
+	}
+	if err := s.DeleteCatalogEntry(ctx, entry.Id); err != nil {
+		t.Fatal(err)
+	}
+	if sum, err := s.ShowSubscSum(ctx, "nflx", userId, "2025-01-01", "2025-12-31"); err != nil || len(sum.Items) != 0 {
+		t.Fatalf("sum by alias after catalog delete: %+v, err %v", sum, err)
+	}
+}
+
+func TestMonthlySpending(t *testing.T) {
+	ctx := context.Background()
+	s := service.NewService(memory.NewStorage())
+
+	subs := []models.Subscription{
+		{ServiceName: "Netflix", Price: 400, UserId: userId, StartDate: "2025-01-15", EndDate: "2025-03-10"},
+		{ServiceName: "Spotify", Price: 200, UserId: userId, StartDate: "2025-02-01"},
+		{ServiceName: "Netflix", Price: 300, UserId: "0f6a6a54-5c1c-4b38-9a4e-0c1a2b3c4d5e", StartDate: "2025-01-01"},
+	}
+
+	for _, sub := range subs {
+		if _, err := s.CreateSub(ctx, sub); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	months, err := s.MonthlySpending(ctx, userId, "2024-12-01", "2025-04-30")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var totals []int
+	for _, m := range months {
+		totals = append(totals, m.Total)
+	}
+	if want := []int{0, 400, 600, 600, 200}; !slices.Equal(totals, want) {
+		t.Fatalf("totals = %v, want %v", totals, want)
+	}
+	if months[0].Month != "2024-12" || len(months[0].Services) != 0 || len(months[2].Services) != 2 {
+		t.Fatalf("unexpected months %+v", months)
+	}
+}
+
+func TestForecast(t *testing.T) {
+	ctx := context.Background()
+	s := service.NewService(memory.NewStorage())
+
+	subs := []models.Subscription{
+		{ServiceName: "Netflix", Price: 400, UserId: userId, StartDate: "2025-01-01", EndDate: "2025-06-15"},
+		{ServiceName: "Spotify", Price: 200, UserId: userId, StartDate: "2025-05-01"},
+		{ServiceName: "Spotify", Price: 100, UserId: userId, StartDate: "2025-09-01"},                       // начнётся внутри прогноза
+		{ServiceName: "Yandex", Price: 300, UserId: userId, StartDate: "2024-01-01", EndDate: "2024-12-01"}, // уже закончилась
+	}
+
+	for _, sub := range subs {
+		if _, err := s.CreateSub(ctx, sub); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	forecast, err := s.Forecast(ctx, userId, "2025-05-01", "2025-10-01")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var totals []int
+	for _, m := range forecast.Months {
+		totals = append(totals, m.Total)
+	}
+	if want := []int{600, 600, 200, 200, 300, 300}; !slices.Equal(totals, want) {
+		t.Fatalf("totals = %v, want %v", totals, want)
+	}
+	if forecast.Total != 2200 {
+		t.Fatalf("total = %d, want 2200", forecast.Total)
+	}
+
+	june := forecast.Months[1]
+	if len(june.Expiring) != 1 || june.Expiring[0].ServiceName != "Netflix" || len(forecast.Months[2].Expiring) != 0 {
+		t.Fatalf("unexpected expirations %+v", forecast.Months)
+	}
+	if sep := forecast.Months[4]; len(sep.Services) != 1 || sep.Services[0].Total != 300 {
+		t.Fatalf("services of one name must be merged: %+v", sep.Services)
+	}
+}
+
+// Прогноз и сумма за один период считаются по-разному, см. Forecast: сумма берёт каждую подписку,
+// целиком лежащую в периоде, один раз, а прогноз — цену за каждый месяц действия, включая бессрочные
+
+func TestForecastAgainstSummary(t *testing.T) {
+	ctx := context.Background()
+	s := service.NewService(memory.NewStorage())
+
+	subs := []models.Subscription{
+		{ServiceName: "Netflix", Price: 400, UserId: userId, StartDate: "2025-01-01", EndDate: "2025-03-01"},
+		{ServiceName: "Spotify", Price: 200, UserId: userId, StartDate: "2025-02-01"}, // без даты окончания
+	}
+	for _, sub := range subs {
+		if _, err := s.CreateSub(ctx, sub); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	sum, err := s.ShowSubscSum(ctx, "", userId, "2025-01-01", "2025-06-01")
+	if err != nil {
+		t.Fatal(err)
+	}
+	forecast, err := s.Forecast(ctx, userId, "2025-01-01", "2025-06-01")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(sum.Items) != 1 || sum.Items[0].ServiceName != "Netflix" || sum.Total != 400 {
+		t.Fatalf("summary must skip the subscription without end date: %+v", sum)
+	}
+
+	perService := map[string]int{}
+	for _, m := range forecast.Months {
+		for _, svc := range m.Services {
+			perService[svc.ServiceName] += svc.Total
+		}
+	}
+
+	// Подписка из суммы входит в прогноз за каждый из трёх месяцев действия, бессрочная — с февраля по июнь
+	if len(forecast.Months) != 6 || perService["Netflix"] != 3*sum.Total || perService["Spotify"] != 5*200 {
+		t.Fatalf("forecast by service %v, months %d", perService, len(forecast.Months))
+	}
+	if forecast.Total != perService["Netflix"]+perService["Spotify"] {
+		t.Fatalf("forecast total = %d, want %d", forecast.Total, perService["Netflix"]+perService["Spotify"])
+	}
+}
+
+func TestEvaluateBudgets(t *testing.T) {
+	ctx := context.Background()
+	s := service.NewService(memory.NewStorage())
+
+	subs := []models.Subscription{
+		{ServiceName: "Netflix", Price: 400, UserId: userId, StartDate: "2025-01-01"},
+		{ServiceName: "Spotify", Price: 200, UserId: userId, StartDate: "2025-01-01", EndDate: "2025-06-01"},
+	}
+	for _, sub := range subs {
+		if _, err := s.CreateSub(ctx, sub); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	total, err := s.CreateBudget(ctx, models.Budget{UserId: userId, MonthlyLimit: 500})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.CreateBudget(ctx, models.Budget{UserId: userId, ServiceName: "Netflix", MonthlyLimit: 400}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.CreateBudget(ctx, models.Budget{UserId: userId, MonthlyLimit: 100}); !errors.Is(err, service.ErrBudgetExists) {
+		t.Fatalf("second overall budget: err = %v, want ErrBudgetExists", err)
+	}
+
+	// В мае действуют обе подписки: общий бюджет превышен, бюджет Netflix ровно исчерпан
+	alerts, err := s.EvaluateBudgets(ctx, userId, "2025-05")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(alerts) != 1 || alerts[0].BudgetId != total.Id || alerts[0].Spent != 600 {
+		t.Fatalf("unexpected alerts %+v", alerts)
+	}
+
+	// В июле Spotify уже закончилась
+	if alerts, _ := s.EvaluateBudgets(ctx, userId, "2025-07"); len(alerts) != 0 {
+		t.Fatalf("unexpected alerts after expiration %+v", alerts)
+	}
+
+	// Повторная проверка того же месяца не создаёт второе предупреждение
+	if _, err := s.EvaluateBudgets(ctx, userId, "2025-05"); err != nil {
+		t.Fatal(err)
+	}
+	if stored, _ := s.ReadAlerts(ctx, userId); len(stored) != 1 {
+		t.Fatalf("got %d stored alerts, want 1", len(stored))
+	}
+
+	// После повышения лимита предупреждение за май снимается
+	if _, err := s.UpdateBudget(ctx, models.Budget{Id: total.Id, UserId: userId, MonthlyLimit: 600}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.EvaluateBudgets(ctx, userId, "2025-05"); err != nil {
+		t.Fatal(err)
+	}
+	if stored, _ := s.ReadAlerts(ctx, userId); len(stored) != 0 {
+		t.Fatalf("alert under the limit must be removed, got %+v", stored)
+	}
+
+	if err := s.DeleteBudget(ctx, total.Id); err != nil {
+		t.Fatal(err)
+	}
+	if stored, _ := s.ReadAlerts(ctx, userId); len(stored) != 0 {
+		t.Fatalf("alerts of deleted budget must be removed, got %+v", stored)
+	}
+}
+
+func TestCatalogRename(t *testing.T) {
+	ctx := context.Background()
+	s := service.NewService(memory.NewStorage())
+
+	// Подписки записаны до появления сервиса в каталоге, в разном регистре и под псевдонимом
+	for _, name := range []string{"netflix", "NFLX"} {
+		sub := models.Subscription{ServiceName: name, Price: 300, UserId: userId, StartDate: "2025-01-01"}
+		if _, err := s.CreateSub(ctx, sub); err != nil {
+			t.Fatal(err)
+		}
+	}
+	budget, err := s.CreateBudget(ctx, models.Budget{UserId: userId, ServiceName: "Netflix ", MonthlyLimit: 200})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Бюджет сравнивается с расходом без учёта регистра и пробелов, псевдоним до переименования не учитывается
+	if alerts, err := s.EvaluateBudgets(ctx, userId, "2025-05"); err != nil || len(alerts) != 1 || alerts[0].Spent != 300 {
+		t.Fatalf("before rename: alerts %+v, err %v", alerts, err)
+	}
+
+	if _, err := s.CreateCatalogEntry(ctx, models.CatalogEntry{Name: "Netflix", Aliases: []string{"nflx"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	subs, err := s.ReadSubs(ctx, userId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, sub := range subs {
+		if sub.ServiceName != "Netflix" {
+			t.Fatalf("subscription %d not renamed: %q", sub.Id, sub.ServiceName)
+		}
+	}
+	if b, _ := s.ReadBudget(ctx, budget.Id); b.ServiceName != "Netflix" {
+		t.Fatalf("budget not renamed: %q", b.ServiceName)
+	}
+
+	alerts, err := s.EvaluateBudgets(ctx, userId, "2025-05")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(alerts) != 1 || alerts[0].Spent != 600 {
+		t.Fatalf("after rename: unexpected alerts %+v", alerts)
+	}
+}
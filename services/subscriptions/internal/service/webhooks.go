@@ -0,0 +1,100 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"subscriptions/internal/models"
+	"subscriptions/internal/store"
+)
+
+// Типы событий подписок в outbox
+
+const (
+	EventSubCreated = "subscription.created"
+	EventSubUpdated = "subscription.updated"
+	EventSubDeleted = "subscription.deleted"
+)
+
+// Пишет событие с состоянием подписки в той же транзакции, что и её изменение
+
+func addEvent(ctx context.Context, tx store.Storage, eventType string, sub models.Subscription) error {
+	return tx.AddEventRequest(ctx, models.Event{Type: eventType, UserId: sub.UserId, Data: sub})
+}
+
+// Секрет для подписи событий генерируется при регистрации и возвращается только в ответе на неё
+
+func (service *ServiceMethods) CreateWebhook(ctx context.Context, wh models.Webhook) (*models.Webhook, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, err
+	}
+	wh.Secret = hex.EncodeToString(secret)
+
+	var created *models.Webhook
+
+	err := service.s.WithTx(ctx, func(tx store.Storage) error {
+		id, err := tx.CreateWebhookRequest(ctx, wh)
+		if err != nil {
+			return err
+		}
+		created, err = tx.ReadWebhookRequest(ctx, id)
+		return err
+	})
+
+	if err != nil {
+		slog.ErrorContext(ctx, "CreateWebhook method: error", "error", err)
+		return nil, err
+	}
+
+	return created, nil
+}
+
+func (service *ServiceMethods) ReadWebhook(ctx context.Context, id int) (*models.Webhook, error) {
+	wh, err := service.s.ReadWebhookRequest(ctx, id)
+	if err != nil {
+		slog.ErrorContext(ctx, "ReadWebhook method: error", "error", err)
+		return nil, err
+	}
+
+	wh.Secret = ""
+
+	return wh, nil
+}
+
+func (service *ServiceMethods) ReadWebhooks(ctx context.Context, userId string) ([]models.Webhook, error) {
+	webhooks, err := service.s.ReadWebhooksRequest(ctx, userId)
+	if err != nil {
+		slog.ErrorContext(ctx, "ReadWebhooks method: error", "error", err)
+		return nil, err
+	}
+
+	for i := range webhooks {
+		webhooks[i].Secret = ""
+	}
+
+	return webhooks, nil
+}
+
+// Журнал доставок удаляется вместе с вебхуком
+
+func (service *ServiceMethods) DeleteWebhook(ctx context.Context, id int) error {
+	err := service.s.DeleteWebhookRequest(ctx, id)
+	if err != nil {
+		slog.ErrorContext(ctx, "DeleteWebhook method: error", "error", err)
+		return err
+	}
+
+	return nil
+}
+
+func (service *ServiceMethods) ReadDeliveries(ctx context.Context, webhookId int) ([]models.WebhookDelivery, error) {
+	deliveries, err := service.s.ReadDeliveriesRequest(ctx, webhookId)
+	if err != nil {
+		slog.ErrorContext(ctx, "ReadDeliveries method: error", "error", err)
+		return nil, err
+	}
+
+	return deliveries, nil
+}
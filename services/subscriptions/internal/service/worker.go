@@ -1,36 +1,91 @@
 package service
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
+	"subscriptions/internal/metrics"
 	"subscriptions/internal/models"
+	"subscriptions/internal/tracing"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type JobType string
 
 const (
-	JobCreate  JobType = "create"
-	JobUpdate  JobType = "update"
-	JobDelete  JobType = "delete"
-	JobShowOne JobType = "show_one"
-	JobShowAll JobType = "show_all"
-	JobShowSum JobType = "show_all_sum"
+	JobCreate   JobType = "create"
+	JobUpdate   JobType = "update"
+	JobDelete   JobType = "delete"
+	JobShowOne  JobType = "show_one"
+	JobShowAll  JobType = "show_all"
+	JobShowSum  JobType = "show_all_sum"
+	JobMonthly  JobType = "monthly_spending"
+	JobForecast JobType = "forecast"
+
+	JobBudgetCreate  JobType = "budget_create"
+	JobBudgetUpdate  JobType = "budget_update"
+	JobBudgetDelete  JobType = "budget_delete"
+	JobBudgetShowOne JobType = "budget_show_one"
+	JobBudgetShowAll JobType = "budget_show_all"
+	JobBudgetCheck   JobType = "budget_check"
+	JobAlertsShowAll JobType = "alerts_show_all"
+
+	JobWebhookCreate     JobType = "webhook_create"
+	JobWebhookDelete     JobType = "webhook_delete"
+	JobWebhookShowOne    JobType = "webhook_show_one"
+	JobWebhookShowAll    JobType = "webhook_show_all"
+	JobDeliveriesShowAll JobType = "deliveries_show_all"
+
+	JobCatalogCreate  JobType = "catalog_create"
+	JobCatalogUpdate  JobType = "catalog_update"
+	JobCatalogDelete  JobType = "catalog_delete"
+	JobCatalogShowOne JobType = "catalog_show_one"
+	JobCatalogShowAll JobType = "catalog_show_all"
 )
 
 type Service interface {
-	CreateSub(sub models.Subscription) (string, error)                                                                   // Метод для создания записи. Возвращает id пользователя и ошибку.
-	ReadSub(id int) (*models.Subscription, error)                                                                        // Метод для чтения записи по её id.
-	ReadSubs(userId string) ([]models.Subscription, error)                                                               // Метод для чтения среза записей для конкретного пользователя.
-	UpdateSub(sub models.Subscription) error                                                                             // Метод для обновления записей методом Update.
-	DeleteSub(id int) error                                                                                              // Метод для удаления записи о подписке.
-	ShowSubscSum(serviceName string, userId string, startPeriod string, EndPeriod string) ([]models.Subscription, error) // Метод для получения сум подписок, для начала работы нужно -
+	CreateSub(ctx context.Context, sub models.Subscription) (*models.Subscription, error)                                                       // Метод для создания записи. Возвращает новую запись и ошибку.
+	ReadSub(ctx context.Context, id int) (*models.Subscription, error)                                                                          // Метод для чтения записи по её id.
+	ReadSubs(ctx context.Context, userId string) ([]models.Subscription, error)                                                                 // Метод для чтения среза записей для конкретного пользователя.
+	UpdateSub(ctx context.Context, sub models.Subscription) (*models.Subscription, string, error)                                               // Метод для обновления записей методом Update. Возвращает запись после обновления и прежнего владельца.
+	DeleteSub(ctx context.Context, id int) (*models.Subscription, error)                                                                        // Метод для удаления записи о подписке. Возвращает удалённую запись.
+	ShowSubscSum(ctx context.Context, serviceName string, userId string, startPeriod string, EndPeriod string) (*models.SubscriptionSum, error) // Метод для получения сум подписок, для начала работы нужно -
 	// отправить период внутри которого будем искать записи о подписках
+	MonthlySpending(ctx context.Context, userId string, startPeriod string, endPeriod string) ([]models.MonthlySpending, error) // Помесячные расходы пользователя за период
+	Forecast(ctx context.Context, userId string, startPeriod string, endPeriod string) (*models.Forecast, error)                // Прогноз расходов по действующим подпискам
+	CreateBudget(ctx context.Context, b models.Budget) (*models.Budget, error)
+	ReadBudget(ctx context.Context, id int) (*models.Budget, error)
+	ReadBudgets(ctx context.Context, userId string) ([]models.Budget, error)
+	UpdateBudget(ctx context.Context, b models.Budget) (*models.Budget, error)
+	DeleteBudget(ctx context.Context, id int) error
+	EvaluateBudgets(ctx context.Context, userId string, month string) ([]models.BudgetAlert, error) // Проверка бюджетов пользователя за месяц YYYY-MM
+	ReadAlerts(ctx context.Context, userId string) ([]models.BudgetAlert, error)
+	CreateWebhook(ctx context.Context, wh models.Webhook) (*models.Webhook, error) // Возвращает вебхук вместе с секретом для подписи
+	ReadWebhook(ctx context.Context, id int) (*models.Webhook, error)
+	ReadWebhooks(ctx context.Context, userId string) ([]models.Webhook, error)
+	DeleteWebhook(ctx context.Context, id int) error
+	ReadDeliveries(ctx context.Context, webhookId int) ([]models.WebhookDelivery, error)
+	CreateCatalogEntry(ctx context.Context, e models.CatalogEntry) (*models.CatalogEntry, error) // Возвращает запись после нормализации имени и псевдонимов
+	ReadCatalogEntry(ctx context.Context, id int) (*models.CatalogEntry, error)
+	ReadCatalog(ctx context.Context) ([]models.CatalogEntry, error)
+	UpdateCatalogEntry(ctx context.Context, e models.CatalogEntry) (*models.CatalogEntry, error)
+	DeleteCatalogEntry(ctx context.Context, id int) error
 }
 
 type Job struct {
-	Type    JobType
-	Request models.Subscription
-	Result  chan JobResult
+	Ctx       context.Context
+	Type      JobType
+	Request   models.Subscription
+	Budget    models.Budget       // Данные для задач с бюджетами
+	Webhook   models.Webhook      // Данные для задач с вебхуками
+	Catalog   models.CatalogEntry // Данные для задач с каталогом сервисов
+	Result    chan JobResult      // nil у фоновых задач, результат которых никто не ждёт
+	QueueSpan trace.Span          // Спан ожидания в очереди, закрывается воркером при получении задачи
 }
 
 type JobResult struct {
@@ -38,92 +93,249 @@ type JobResult struct {
 	Error  error
 }
 
+// Доля заполнения очереди, начиная с которой пул считается перегруженным
+
+const saturationThreshold = 0.9
+
 type WorkerPool struct {
-	s Service
+	s    Service
+	m    *metrics.Metrics
+	jobs chan Job
+	quit chan struct{}
+	wg   sync.WaitGroup
 }
 
-var jobChan chan Job
+var ErrPoolStopped = errors.New("worker pool is stopped")
 
-func StartWorkerPool(numWorkers int, se Service) *WorkerPool {
-	jobChan = make(chan Job, 1000)
+func StartWorkerPool(numWorkers int, queueSize int, se Service, m *metrics.Metrics) *WorkerPool {
+	wp := &WorkerPool{s: se, m: m, jobs: make(chan Job, queueSize), quit: make(chan struct{})}
 
-	wp := WorkerPool{s: se}
+	m.RegisterQueue(func() int { return len(wp.jobs) }, cap(wp.jobs))
 
 	for i := 0; i < numWorkers; i++ {
-		go wp.worker(i, jobChan)
+		wp.wg.Add(1)
+		go wp.worker(i)
 	}
 
-	return &wp
+	return wp
+}
+
+// Останавливает воркеры и ждёт завершения текущих задач. Задачи, оставшиеся в очереди, не выполняются,
+// ожидающие их запросы получают ErrPoolStopped
+
+func (w *WorkerPool) Stop() {
+	close(w.quit)
+	w.wg.Wait()
+}
+
+// Проверка готовности: очередь задач не должна быть почти заполнена
+
+func (w *WorkerPool) CheckSaturation(ctx context.Context) error {
+	length, capacity := len(w.jobs), cap(w.jobs)
+	if float64(length) >= float64(capacity)*saturationThreshold {
+		return fmt.Errorf("worker pool queue is saturated: %d/%d jobs", length, capacity)
+	}
+	return nil
 }
 
-func (w *WorkerPool) worker(i int, jobs <-chan Job) {
+func (w *WorkerPool) worker(i int) {
+	defer w.wg.Done()
 
+	for {
+		select {
+		case <-w.quit:
+			return
+		case job := <-w.jobs:
+			w.process(i, job)
+		}
+	}
+}
+
+func (w *WorkerPool) process(i int, job Job) {
 	var err error
 	var result interface{}
-	for job := range jobs {
-		log.Printf("goroutine %v got task", i)
+
+	job.QueueSpan.End()
+
+	slog.DebugContext(job.Ctx, "worker got task", "worker", i, "job_type", job.Type)
+	w.m.BusyWorkers.Inc()
+	start := time.Now()
+	ctx, span := tracing.Start(job.Ctx, "service."+string(job.Type), attribute.Int("worker.id", i))
+	switch job.Type {
+	case JobCreate:
+		result, err = w.s.CreateSub(ctx, job.Request)
+	case JobUpdate:
+		var prevUserId string
+		if result, prevUserId, err = w.s.UpdateSub(ctx, job.Request); err == nil && prevUserId != job.Request.UserId {
+			// У прежнего владельца расход уменьшился, его бюджеты тоже проверяются заново
+			w.checkBudgets(job.Ctx, prevUserId)
+		}
+	case JobDelete:
+		var deleted *models.Subscription
+		if deleted, err = w.s.DeleteSub(ctx, job.Request.Id); err == nil {
+			job.Request.UserId = deleted.UserId
+		}
+	case JobShowOne:
+		result, err = w.s.ReadSub(ctx, job.Request.Id)
+	case JobShowAll:
+		result, err = w.s.ReadSubs(ctx, job.Request.UserId)
+	case JobShowSum:
+		result, err = w.s.ShowSubscSum(ctx, job.Request.ServiceName, job.Request.UserId, job.Request.StartDate, job.Request.EndDate)
+	case JobMonthly:
+		result, err = w.s.MonthlySpending(ctx, job.Request.UserId, job.Request.StartDate, job.Request.EndDate)
+	case JobForecast:
+		result, err = w.s.Forecast(ctx, job.Request.UserId, job.Request.StartDate, job.Request.EndDate)
+	case JobBudgetCreate:
+		result, err = w.s.CreateBudget(ctx, job.Budget)
+	case JobBudgetUpdate:
+		result, err = w.s.UpdateBudget(ctx, job.Budget)
+	case JobBudgetDelete:
+		err = w.s.DeleteBudget(ctx, job.Budget.Id)
+	case JobBudgetShowOne:
+		result, err = w.s.ReadBudget(ctx, job.Budget.Id)
+	case JobBudgetShowAll:
+		result, err = w.s.ReadBudgets(ctx, job.Budget.UserId)
+	case JobBudgetCheck:
+		result, err = w.s.EvaluateBudgets(ctx, job.Request.UserId, job.Request.StartDate)
+	case JobAlertsShowAll:
+		result, err = w.s.ReadAlerts(ctx, job.Request.UserId)
+	case JobWebhookCreate:
+		result, err = w.s.CreateWebhook(ctx, job.Webhook)
+	case JobWebhookDelete:
+		err = w.s.DeleteWebhook(ctx, job.Webhook.Id)
+	case JobWebhookShowOne:
+		result, err = w.s.ReadWebhook(ctx, job.Webhook.Id)
+	case JobWebhookShowAll:
+		result, err = w.s.ReadWebhooks(ctx, job.Webhook.UserId)
+	case JobDeliveriesShowAll:
+		result, err = w.s.ReadDeliveries(ctx, job.Webhook.Id)
+	case JobCatalogCreate:
+		result, err = w.s.CreateCatalogEntry(ctx, job.Catalog)
+	case JobCatalogUpdate:
+		result, err = w.s.UpdateCatalogEntry(ctx, job.Catalog)
+	case JobCatalogDelete:
+		err = w.s.DeleteCatalogEntry(ctx, job.Catalog.Id)
+	case JobCatalogShowOne:
+		result, err = w.s.ReadCatalogEntry(ctx, job.Catalog.Id)
+	case JobCatalogShowAll:
+		result, err = w.s.ReadCatalog(ctx)
+	}
+	tracing.End(span, err)
+	w.m.JobDuration.WithLabelValues(string(job.Type)).Observe(time.Since(start).Seconds())
+	w.m.BusyWorkers.Dec()
+	slog.DebugContext(job.Ctx, "worker completed task", "worker", i, "job_type", job.Type)
+
+	// После изменения подписки или бюджета бюджеты пользователя проверяются отдельной задачей
+	if err == nil {
 		switch job.Type {
-		case JobCreate:
-			result, err = w.s.CreateSub(job.Request)
-		case JobUpdate:
-			err = w.s.UpdateSub(job.Request)
-		case JobDelete:
-			err = w.s.DeleteSub(job.Request.Id)
-		case JobShowOne:
-			result, err = w.s.ReadSub(job.Request.Id)
-		case JobShowAll:
-			result, err = w.s.ReadSubs(job.Request.UserId)
-		case JobShowSum:
-			result, err = w.s.ShowSubscSum(job.Request.ServiceName, job.Request.UserId, job.Request.StartDate, job.Request.EndDate)
+		case JobCreate, JobUpdate, JobDelete:
+			w.checkBudgets(job.Ctx, job.Request.UserId)
+		case JobBudgetCreate, JobBudgetUpdate:
+			w.checkBudgets(job.Ctx, job.Budget.UserId)
 		}
-		log.Printf("goroutine %v completed task", i)
-		job.Result <- JobResult{Result: result, Error: err}
 	}
 
+	if job.Result == nil {
+		if err != nil {
+			slog.ErrorContext(job.Ctx, "background job failed", "job_type", job.Type, "error", err)
+		}
+		return
+	}
+	job.Result <- JobResult{Result: result, Error: err}
 }
 
-func (w *WorkerPool) AsyncCreateSub(sub models.Subscription) (string, error) {
-	jobresult := make(chan JobResult, 1)
+// Ставит проверку бюджетов за текущий месяц в очередь, не дожидаясь результата. Воркер сам кладёт задачу
+// в очередь, поэтому при заполненной очереди проверка пропускается, а не блокирует воркер.
+// Контекст запроса не отменяет проверку, но сохраняет трассировку
 
-	jobChan <- Job{Type: JobCreate, Request: sub, Result: jobresult}
+func (w *WorkerPool) checkBudgets(ctx context.Context, userId string) {
+	ctx = context.WithoutCancel(ctx)
+	month := time.Now().UTC().Format("2006-01")
 
-	res := <-jobresult
+	_, queueSpan := tracing.Start(ctx, "worker_pool.queue_wait", attribute.String("job.type", string(JobBudgetCheck)))
 
-	strResult, ok := res.Result.(string)
-
-	if !ok {
-		return "", res.Error
+	select {
+	case w.jobs <- Job{Ctx: ctx, Type: JobBudgetCheck, Request: models.Subscription{UserId: userId, StartDate: month}, QueueSpan: queueSpan}:
+	default:
+		tracing.End(queueSpan, fmt.Errorf("queue is full"))
+		slog.WarnContext(ctx, "worker pool queue is full, budget check skipped", "user_id", userId)
 	}
+}
+
+// Ставит задачу в очередь и ждёт результат, пока не отменён контекст запроса
 
-	return strResult, res.Error
+func (w *WorkerPool) run(ctx context.Context, jobType JobType, sub models.Subscription) JobResult {
+	return w.runJob(ctx, Job{Type: jobType, Request: sub})
 }
 
-func (w *WorkerPool) AsyncUpdateSub(sub models.Subscription) error {
+func (w *WorkerPool) runJob(ctx context.Context, job Job) JobResult {
 	jobresult := make(chan JobResult, 1)
 
-	jobChan <- Job{Type: JobUpdate, Request: sub, Result: jobresult}
+	_, queueSpan := tracing.Start(ctx, "worker_pool.queue_wait", attribute.String("job.type", string(job.Type)))
 
-	res := <-jobresult
+	job.Ctx, job.Result, job.QueueSpan = ctx, jobresult, queueSpan
 
-	return res.Error
+	select {
+	case w.jobs <- job:
+	case <-ctx.Done():
+		tracing.End(queueSpan, ctx.Err())
+		return JobResult{Error: ctx.Err()}
+	case <-w.quit:
+		tracing.End(queueSpan, ErrPoolStopped)
+		return JobResult{Error: ErrPoolStopped}
+	}
+
+	select {
+	case res := <-jobresult:
+		return res
+	case <-ctx.Done():
+		return JobResult{Error: ctx.Err()}
+	case <-w.quit:
+		return JobResult{Error: ErrPoolStopped}
+	}
 }
 
-func (w *WorkerPool) AsyncDeleteSub(sub models.Subscription) error {
-	jobresult := make(chan JobResult, 1)
+func (w *WorkerPool) AsyncCreateSub(ctx context.Context, sub models.Subscription) (*models.Subscription, error) {
+	res := w.run(ctx, JobCreate, sub)
+	if res.Error != nil {
+		return nil, res.Error
+	}
 
-	jobChan <- Job{Type: JobDelete, Request: sub, Result: jobresult}
+	created, ok := res.Result.(*models.Subscription)
 
-	res := <-jobresult
+	if !ok || created == nil {
+		return nil, fmt.Errorf("incorrect type or no created sub, %v", ok)
+	}
 
-	return res.Error
+	return created, nil
 }
 
-func (w *WorkerPool) AsyncReadSub(sub models.Subscription) (*models.Subscription, error) {
-	jobresult := make(chan JobResult, 1)
+func (w *WorkerPool) AsyncUpdateSub(ctx context.Context, sub models.Subscription) (*models.Subscription, error) {
+	res := w.run(ctx, JobUpdate, sub)
+	if res.Error != nil {
+		return nil, res.Error
+	}
 
-	jobChan <- Job{Type: JobShowOne, Request: sub, Result: jobresult}
+	updated, ok := res.Result.(*models.Subscription)
 
-	res := <-jobresult
+	if !ok || updated == nil {
+		return nil, fmt.Errorf("incorrect type or no updated sub, %v", ok)
+	}
+
+	return updated, nil
+}
+
+func (w *WorkerPool) AsyncDeleteSub(ctx context.Context, sub models.Subscription) error {
+	res := w.run(ctx, JobDelete, sub)
+
+	return res.Error
+}
+
+func (w *WorkerPool) AsyncReadSub(ctx context.Context, sub models.Subscription) (*models.Subscription, error) {
+	res := w.run(ctx, JobShowOne, sub)
+	if res.Error != nil {
+		return nil, res.Error
+	}
 
 	subscr, ok := res.Result.(*models.Subscription)
 
@@ -134,34 +346,230 @@ func (w *WorkerPool) AsyncReadSub(sub models.Subscription) (*models.Subscription
 	return subscr, res.Error
 }
 
-func (w *WorkerPool) AsyncReadSubs(sub models.Subscription) ([]models.Subscription, error) {
-	jobresult := make(chan JobResult, 1)
+func (w *WorkerPool) AsyncReadSubs(ctx context.Context, sub models.Subscription) ([]models.Subscription, error) {
+	res := w.run(ctx, JobShowAll, sub)
+	if res.Error != nil {
+		return nil, res.Error
+	}
 
-	jobChan <- Job{Type: JobShowAll, Request: sub, Result: jobresult}
+	// Пустой список подписок не ошибка
+	subscriptions, ok := res.Result.([]models.Subscription)
 
-	res := <-jobresult
+	if !ok {
+		return nil, fmt.Errorf("incorrect type of subs, %v", ok)
+	}
 
-	subscriptions, ok := res.Result.([]models.Subscription)
+	return subscriptions, nil
+}
 
-	if !ok || subscriptions == nil {
-		return nil, fmt.Errorf("incorrect type or no subs, %v", ok)
+func (w *WorkerPool) AsyncShowSubscSum(ctx context.Context, sub models.Subscription) (*models.SubscriptionSum, error) {
+	res := w.run(ctx, JobShowSum, sub)
+	if res.Error != nil {
+		return nil, res.Error
 	}
 
-	return subscriptions, res.Error
+	sum, ok := res.Result.(*models.SubscriptionSum)
+
+	if !ok || sum == nil {
+		return nil, fmt.Errorf("incorrect type or no subs sum, %v", ok)
+	}
+
+	return sum, nil
 }
 
-func (w *WorkerPool) AsyncShowSubscSum(sub models.Subscription) ([]models.Subscription, error) {
-	jobresult := make(chan JobResult, 1)
+func (w *WorkerPool) AsyncMonthlySpending(ctx context.Context, sub models.Subscription) ([]models.MonthlySpending, error) {
+	res := w.run(ctx, JobMonthly, sub)
+	if res.Error != nil {
+		return nil, res.Error
+	}
 
-	jobChan <- Job{Type: JobShowSum, Request: sub, Result: jobresult}
+	months, ok := res.Result.([]models.MonthlySpending)
 
-	res := <-jobresult
+	if !ok {
+		return nil, fmt.Errorf("incorrect type of monthly spending, %v", ok)
+	}
 
-	subscriptions, ok := res.Result.([]models.Subscription)
+	return months, nil
+}
+
+func (w *WorkerPool) AsyncForecast(ctx context.Context, sub models.Subscription) (*models.Forecast, error) {
+	res := w.run(ctx, JobForecast, sub)
+	if res.Error != nil {
+		return nil, res.Error
+	}
+
+	forecast, ok := res.Result.(*models.Forecast)
+
+	if !ok || forecast == nil {
+		return nil, fmt.Errorf("incorrect type or no forecast, %v", ok)
+	}
+
+	return forecast, nil
+}
+
+func (w *WorkerPool) AsyncCreateBudget(ctx context.Context, b models.Budget) (*models.Budget, error) {
+	return asyncBudget(w.runJob(ctx, Job{Type: JobBudgetCreate, Budget: b}))
+}
+
+func (w *WorkerPool) AsyncUpdateBudget(ctx context.Context, b models.Budget) (*models.Budget, error) {
+	return asyncBudget(w.runJob(ctx, Job{Type: JobBudgetUpdate, Budget: b}))
+}
+
+func (w *WorkerPool) AsyncDeleteBudget(ctx context.Context, b models.Budget) error {
+	return w.runJob(ctx, Job{Type: JobBudgetDelete, Budget: b}).Error
+}
+
+func (w *WorkerPool) AsyncReadBudget(ctx context.Context, b models.Budget) (*models.Budget, error) {
+	return asyncBudget(w.runJob(ctx, Job{Type: JobBudgetShowOne, Budget: b}))
+}
+
+func asyncBudget(res JobResult) (*models.Budget, error) {
+	if res.Error != nil {
+		return nil, res.Error
+	}
+
+	budget, ok := res.Result.(*models.Budget)
+
+	if !ok || budget == nil {
+		return nil, fmt.Errorf("incorrect type or no budget, %v", ok)
+	}
+
+	return budget, nil
+}
+
+// Пустой список бюджетов или предупреждений не ошибка
+
+func (w *WorkerPool) AsyncReadBudgets(ctx context.Context, b models.Budget) ([]models.Budget, error) {
+	res := w.runJob(ctx, Job{Type: JobBudgetShowAll, Budget: b})
+	if res.Error != nil {
+		return nil, res.Error
+	}
+
+	budgets, ok := res.Result.([]models.Budget)
+
+	if !ok {
+		return nil, fmt.Errorf("incorrect type of budgets, %v", ok)
+	}
+
+	return budgets, nil
+}
+
+func (w *WorkerPool) AsyncReadAlerts(ctx context.Context, sub models.Subscription) ([]models.BudgetAlert, error) {
+	res := w.run(ctx, JobAlertsShowAll, sub)
+	if res.Error != nil {
+		return nil, res.Error
+	}
+
+	alerts, ok := res.Result.([]models.BudgetAlert)
+
+	if !ok {
+		return nil, fmt.Errorf("incorrect type of alerts, %v", ok)
+	}
+
+	return alerts, nil
+}
+
+func (w *WorkerPool) AsyncCreateWebhook(ctx context.Context, wh models.Webhook) (*models.Webhook, error) {
+	return asyncWebhook(w.runJob(ctx, Job{Type: JobWebhookCreate, Webhook: wh}))
+}
+
+func (w *WorkerPool) AsyncReadWebhook(ctx context.Context, wh models.Webhook) (*models.Webhook, error) {
+	return asyncWebhook(w.runJob(ctx, Job{Type: JobWebhookShowOne, Webhook: wh}))
+}
+
+func asyncWebhook(res JobResult) (*models.Webhook, error) {
+	if res.Error != nil {
+		return nil, res.Error
+	}
+
+	webhook, ok := res.Result.(*models.Webhook)
+
+	if !ok || webhook == nil {
+		return nil, fmt.Errorf("incorrect type or no webhook, %v", ok)
+	}
+
+	return webhook, nil
+}
+
+func (w *WorkerPool) AsyncDeleteWebhook(ctx context.Context, wh models.Webhook) error {
+	return w.runJob(ctx, Job{Type: JobWebhookDelete, Webhook: wh}).Error
+}
 
-	if !ok || subscriptions == nil {
-		return nil, fmt.Errorf("incorrect type or no subs, %v", ok)
+// Пустой список вебхуков или доставок не ошибка
+
+func (w *WorkerPool) AsyncReadWebhooks(ctx context.Context, wh models.Webhook) ([]models.Webhook, error) {
+	res := w.runJob(ctx, Job{Type: JobWebhookShowAll, Webhook: wh})
+	if res.Error != nil {
+		return nil, res.Error
+	}
+
+	webhooks, ok := res.Result.([]models.Webhook)
+
+	if !ok {
+		return nil, fmt.Errorf("incorrect type of webhooks, %v", ok)
+	}
+
+	return webhooks, nil
+}
+
+func (w *WorkerPool) AsyncReadDeliveries(ctx context.Context, wh models.Webhook) ([]models.WebhookDelivery, error) {
+	res := w.runJob(ctx, Job{Type: JobDeliveriesShowAll, Webhook: wh})
+	if res.Error != nil {
+		return nil, res.Error
+	}
+
+	deliveries, ok := res.Result.([]models.WebhookDelivery)
+
+	if !ok {
+		return nil, fmt.Errorf("incorrect type of deliveries, %v", ok)
+	}
+
+	return deliveries, nil
+}
+
+func (w *WorkerPool) AsyncCreateCatalogEntry(ctx context.Context, e models.CatalogEntry) (*models.CatalogEntry, error) {
+	return asyncCatalogEntry(w.runJob(ctx, Job{Type: JobCatalogCreate, Catalog: e}))
+}
+
+func (w *WorkerPool) AsyncUpdateCatalogEntry(ctx context.Context, e models.CatalogEntry) (*models.CatalogEntry, error) {
+	return asyncCatalogEntry(w.runJob(ctx, Job{Type: JobCatalogUpdate, Catalog: e}))
+}
+
+func (w *WorkerPool) AsyncReadCatalogEntry(ctx context.Context, e models.CatalogEntry) (*models.CatalogEntry, error) {
+	return asyncCatalogEntry(w.runJob(ctx, Job{Type: JobCatalogShowOne, Catalog: e}))
+}
+
+func asyncCatalogEntry(res JobResult) (*models.CatalogEntry, error) {
+	if res.Error != nil {
+		return nil, res.Error
+	}
+
+	entry, ok := res.Result.(*models.CatalogEntry)
+
+	if !ok || entry == nil {
+		return nil, fmt.Errorf("incorrect type or no catalog entry, %v", ok)
+	}
+
+	return entry, nil
+}
+
+func (w *WorkerPool) AsyncDeleteCatalogEntry(ctx context.Context, e models.CatalogEntry) error {
+	return w.runJob(ctx, Job{Type: JobCatalogDelete, Catalog: e}).Error
+}
+
+// Пустой каталог не ошибка
+
+func (w *WorkerPool) AsyncReadCatalog(ctx context.Context) ([]models.CatalogEntry, error) {
+	res := w.runJob(ctx, Job{Type: JobCatalogShowAll})
+	if res.Error != nil {
+		return nil, res.Error
+	}
+
+	entries, ok := res.Result.([]models.CatalogEntry)
+
+	if !ok {
+		return nil, fmt.Errorf("incorrect type of catalog entries, %v", ok)
 	}
 
-	return subscriptions, res.Error
+	return entries, nil
 }
@@ -0,0 +1,41 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"subscriptions/internal/models"
+)
+
+// Собирает помесячные расходы из строк (месяц YYYY-MM, сервис, сумма), отсортированных по месяцу.
+// Месяц без подписок приходит одной строкой с NULL вместо сервиса и попадает в ответ с нулевой суммой
+
+func ScanMonthly(rows *Rows) ([]models.MonthlySpending, error) {
+	defer rows.Close()
+
+	months := []models.MonthlySpending{}
+	for rows.Next() {
+		var month string
+		var serviceName sql.NullString
+		var total int
+
+		if err := rows.Scan(&month, &serviceName, &total); err != nil {
+			return nil, fmt.Errorf("ScanMonthly method: %w", err)
+		}
+
+		if n := len(months); n == 0 || months[n-1].Month != month {
+			months = append(months, models.MonthlySpending{Month: month, Services: []models.ServiceSpending{}})
+		}
+
+		if serviceName.Valid {
+			last := &months[len(months)-1]
+			last.Total += total
+			last.Services = append(last.Services, models.ServiceSpending{ServiceName: serviceName.String, Total: total})
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("ScanMonthly rows: %w", err)
+	}
+
+	return months, nil
+}
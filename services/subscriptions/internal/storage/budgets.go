@@ -0,0 +1,148 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+	"subscriptions/internal/models"
+	"subscriptions/internal/store"
+	"time"
+)
+
+const (
+	createBudget = "INSERT INTO budgets (user_id, service_name, monthly_limit) VALUES ($1, $2, $3) RETURNING id"
+	readBudget   = "SELECT id, user_id, service_name, monthly_limit FROM budgets WHERE id = $1"
+	readBudgets  = "SELECT id, user_id, service_name, monthly_limit FROM budgets WHERE user_id = $1 ORDER BY id"
+	updateBudget = "UPDATE budgets SET service_name = $1, monthly_limit = $2 WHERE id = $3"
+	deleteBudget = "DELETE FROM budgets WHERE id = $1"
+	deleteAlert  = "DELETE FROM budget_alerts WHERE budget_id = $1 AND month = $2"
+	saveAlert    = `INSERT INTO budget_alerts (budget_id, user_id, service_name, month, monthly_limit, spent) VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (budget_id, month) DO UPDATE SET monthly_limit = EXCLUDED.monthly_limit, spent = EXCLUDED.spent`
+	readAlerts = "SELECT id, budget_id, user_id, service_name, to_char(month, 'YYYY-MM'), monthly_limit, spent, created_at FROM budget_alerts WHERE user_id = $1 ORDER BY month DESC, id"
+)
+
+func (s *Storage) CreateBudgetRequest(ctx context.Context, b models.Budget) (int, error) {
+	var id int
+
+	err := s.queryRow(ctx, "create_budget", createBudget, []any{b.UserId, b.ServiceName, b.MonthlyLimit}, &id)
+	if err != nil {
+		if isUniqueViolation(err, "budgets_user_service_key") {
+			return 0, store.ErrBudgetExists
+		}
+		slog.ErrorContext(ctx, "CreateBudgetRequest: error during creation of budget record", "error", err)
+		return 0, err
+	}
+
+	return id, nil
+}
+
+func (s *Storage) ReadBudgetRequest(ctx context.Context, id int) (*models.Budget, error) {
+	var b models.Budget
+
+	err := s.readQueryRow(ctx, "read_budget", readBudget, []any{id}, &b.Id, &b.UserId, &b.ServiceName, &b.MonthlyLimit)
+
+	if err == sql.ErrNoRows {
+		slog.WarnContext(ctx, "ReadBudgetRequest: budget record not found", "id", id)
+		return nil, store.ErrBudgetNotFound
+	}
+
+	if err != nil {
+		slog.ErrorContext(ctx, "ReadBudgetRequest: error during read of budget record", "error", err)
+		return nil, err
+	}
+
+	return &b, nil
+}
+
+func (s *Storage) ReadBudgetsRequest(ctx context.Context, userId string) ([]models.Budget, error) {
+	rows, err := s.readQuery(ctx, "read_budgets", readBudgets, userId)
+	if err != nil {
+		slog.ErrorContext(ctx, "ReadBudgetsRequest: error during read of budget records", "error", err)
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var budgets []models.Budget
+	for rows.Next() {
+		var b models.Budget
+		if err := rows.Scan(&b.Id, &b.UserId, &b.ServiceName, &b.MonthlyLimit); err != nil {
+			slog.ErrorContext(ctx, "ReadBudgetsRequest: error during rowscan", "error", err)
+			return nil, err
+		}
+		budgets = append(budgets, b)
+	}
+
+	return budgets, rows.Err()
+}
+
+func (s *Storage) UpdateBudgetRequest(ctx context.Context, b models.Budget) error {
+	_, err := s.exec(ctx, "update_budget", updateBudget, b.ServiceName, b.MonthlyLimit, b.Id)
+	if err != nil {
+		if isUniqueViolation(err, "budgets_user_service_key") {
+			return store.ErrBudgetExists
+		}
+		slog.ErrorContext(ctx, "UpdateBudgetRequest: error during update of budget record", "error", err)
+		return err
+	}
+
+	return nil
+}
+
+// Предупреждения бюджета удаляются каскадно
+
+func (s *Storage) DeleteBudgetRequest(ctx context.Context, id int) error {
+	_, err := s.exec(ctx, "delete_budget", deleteBudget, id)
+	if err != nil {
+		slog.ErrorContext(ctx, "DeleteBudgetRequest: error during delete of budget record", "error", err)
+		return err
+	}
+
+	return nil
+}
+
+// Month предупреждения в формате YYYY-MM хранится как первое число месяца
+
+func (s *Storage) SaveAlertRequest(ctx context.Context, a models.BudgetAlert) error {
+	_, err := s.exec(ctx, "save_alert", saveAlert, a.BudgetId, a.UserId, a.ServiceName, a.Month+"-01", a.MonthlyLimit, a.Spent)
+	if err != nil {
+		slog.ErrorContext(ctx, "SaveAlertRequest: error during save of budget alert", "error", err)
+		return err
+	}
+
+	return nil
+}
+
+func (s *Storage) DeleteAlertRequest(ctx context.Context, budgetId int, month string) error {
+	_, err := s.exec(ctx, "delete_alert", deleteAlert, budgetId, month+"-01")
+	if err != nil {
+		slog.ErrorContext(ctx, "DeleteAlertRequest: error during delete of budget alert", "error", err)
+		return err
+	}
+
+	return nil
+}
+
+func (s *Storage) ReadAlertsRequest(ctx context.Context, userId string) ([]models.BudgetAlert, error) {
+	rows, err := s.readQuery(ctx, "read_alerts", readAlerts, userId)
+	if err != nil {
+		slog.ErrorContext(ctx, "ReadAlertsRequest: error during read of budget alerts", "error", err)
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var alerts []models.BudgetAlert
+	for rows.Next() {
+		var a models.BudgetAlert
+		var createdAt time.Time
+		if err := rows.Scan(&a.Id, &a.BudgetId, &a.UserId, &a.ServiceName, &a.Month, &a.MonthlyLimit, &a.Spent, &createdAt); err != nil {
+			slog.ErrorContext(ctx, "ReadAlertsRequest: error during rowscan", "error", err)
+			return nil, err
+		}
+		a.CreatedAt = createdAt.UTC().Format(time.RFC3339)
+		alerts = append(alerts, a)
+	}
+
+	return alerts, rows.Err()
+}
@@ -0,0 +1,155 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"subscriptions/internal/models"
+	"subscriptions/internal/store"
+
+	"github.com/lib/pq"
+)
+
+const (
+	// Режим конфликтует сам с собой, но не с чтением: писатели каталога идут по очереди, поиск имени не ждёт.
+	// Блокировка берётся первой в транзакции, до снимка REPEATABLE READ, иначе проверка не увидит чужой коммит
+	lockCatalog = "LOCK TABLE service_catalog IN SHARE ROW EXCLUSIVE MODE"
+
+	createCatalogEntry = "INSERT INTO service_catalog (name, aliases, category, default_price, logo_url) VALUES ($1, $2, $3, $4, $5) RETURNING id"
+	readCatalogEntry   = "SELECT id, name, aliases, category, default_price, logo_url FROM service_catalog WHERE id = $1"
+	readCatalog        = "SELECT id, name, aliases, category, default_price, logo_url FROM service_catalog ORDER BY name"
+	updateCatalogEntry = "UPDATE service_catalog SET name = $1, aliases = $2, category = $3, default_price = $4, logo_url = $5 WHERE id = $6"
+	deleteCatalogEntry = "DELETE FROM service_catalog WHERE id = $1"
+	findCatalogEntry   = `SELECT id, name, aliases, category, default_price, logo_url FROM service_catalog
+		WHERE lower(name) = lower($1) OR EXISTS (SELECT 1 FROM unnest(aliases) AS a WHERE lower(a) = lower($1))
+		ORDER BY lower(name) <> lower($1), id LIMIT 1`
+
+	renameSubs = "UPDATE subscriptions SET service_name = $1 WHERE lower(btrim(service_name)) = ANY($2) AND service_name <> $1"
+
+	// Бюджет переименовывается, только если он у пользователя один на все эти имена, иначе нарушится UNIQUE (user_id, service_name)
+	renameBudgets = `UPDATE budgets b SET service_name = $1 WHERE lower(btrim(b.service_name)) = ANY($2) AND b.service_name <> $1
+		AND (SELECT count(*) FROM budgets o WHERE o.user_id = b.user_id AND lower(btrim(o.service_name)) = ANY($2)) = 1`
+)
+
+func (s *Storage) LockCatalogRequest(ctx context.Context) error {
+	if _, err := s.exec(ctx, "lock_catalog", lockCatalog); err != nil {
+		slog.ErrorContext(ctx, "LockCatalogRequest: error during lock of catalog", "error", err)
+		return err
+	}
+
+	return nil
+}
+
+func (s *Storage) CreateCatalogEntryRequest(ctx context.Context, e models.CatalogEntry) (int, error) {
+	var id int
+
+	err := s.queryRow(ctx, "create_catalog_entry", createCatalogEntry, []any{e.Name, pq.Array(e.Aliases), e.Category, e.DefaultPrice, e.LogoURL}, &id)
+	if err != nil {
+		if isUniqueViolation(err, "service_catalog_name_key") {
+			return 0, fmt.Errorf("%w: %q", store.ErrCatalogConflict, e.Name)
+		}
+		slog.ErrorContext(ctx, "CreateCatalogEntryRequest: error during creation of catalog record", "error", err)
+		return 0, err
+	}
+
+	return id, nil
+}
+
+func (s *Storage) ReadCatalogEntryRequest(ctx context.Context, id int) (*models.CatalogEntry, error) {
+	var e models.CatalogEntry
+
+	err := s.readQueryRow(ctx, "read_catalog_entry", readCatalogEntry, []any{id}, &e.Id, &e.Name, pq.Array(&e.Aliases), &e.Category, &e.DefaultPrice, &e.LogoURL)
+
+	if err == sql.ErrNoRows {
+		slog.WarnContext(ctx, "ReadCatalogEntryRequest: catalog record not found", "id", id)
+		return nil, store.ErrCatalogEntryNotFound
+	}
+
+	if err != nil {
+		slog.ErrorContext(ctx, "ReadCatalogEntryRequest: error during read of catalog record", "error", err)
+		return nil, err
+	}
+
+	return &e, nil
+}
+
+func (s *Storage) ReadCatalogRequest(ctx context.Context) ([]models.CatalogEntry, error) {
+	rows, err := s.readQuery(ctx, "read_catalog", readCatalog)
+	if err != nil {
+		slog.ErrorContext(ctx, "ReadCatalogRequest: error during read of catalog records", "error", err)
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var entries []models.CatalogEntry
+	for rows.Next() {
+		var e models.CatalogEntry
+		if err := rows.Scan(&e.Id, &e.Name, pq.Array(&e.Aliases), &e.Category, &e.DefaultPrice, &e.LogoURL); err != nil {
+			slog.ErrorContext(ctx, "ReadCatalogRequest: error during rowscan", "error", err)
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+
+	return entries, rows.Err()
+}
+
+func (s *Storage) UpdateCatalogEntryRequest(ctx context.Context, e models.CatalogEntry) error {
+	_, err := s.exec(ctx, "update_catalog_entry", updateCatalogEntry, e.Name, pq.Array(e.Aliases), e.Category, e.DefaultPrice, e.LogoURL, e.Id)
+	if err != nil {
+		if isUniqueViolation(err, "service_catalog_name_key") {
+			return fmt.Errorf("%w: %q", store.ErrCatalogConflict, e.Name)
+		}
+		slog.ErrorContext(ctx, "UpdateCatalogEntryRequest: error during update of catalog record", "error", err)
+		return err
+	}
+
+	return nil
+}
+
+// Подписки хранят имя сервиса текстом, поэтому удаление записи каталога их не меняет
+
+func (s *Storage) DeleteCatalogEntryRequest(ctx context.Context, id int) error {
+	_, err := s.exec(ctx, "delete_catalog_entry", deleteCatalogEntry, id)
+	if err != nil {
+		slog.ErrorContext(ctx, "DeleteCatalogEntryRequest: error during delete of catalog record", "error", err)
+		return err
+	}
+
+	return nil
+}
+
+// Совпадение с каноническим именем важнее совпадения с псевдонимом другой записи
+
+func (s *Storage) FindCatalogEntryRequest(ctx context.Context, name string) (*models.CatalogEntry, error) {
+	var e models.CatalogEntry
+
+	err := s.readQueryRow(ctx, "find_catalog_entry", findCatalogEntry, []any{name}, &e.Id, &e.Name, pq.Array(&e.Aliases), &e.Category, &e.DefaultPrice, &e.LogoURL)
+
+	if err == sql.ErrNoRows {
+		return nil, store.ErrCatalogEntryNotFound
+	}
+
+	if err != nil {
+		slog.ErrorContext(ctx, "FindCatalogEntryRequest: error during search of catalog record", "error", err)
+		return nil, err
+	}
+
+	return &e, nil
+}
+
+func (s *Storage) RenameServiceRequest(ctx context.Context, keys []string, name string) error {
+	if _, err := s.exec(ctx, "rename_service_subs", renameSubs, name, pq.Array(keys)); err != nil {
+		slog.ErrorContext(ctx, "RenameServiceRequest: error during rename of subscriptions", "error", err)
+		return err
+	}
+
+	if _, err := s.exec(ctx, "rename_service_budgets", renameBudgets, name, pq.Array(keys)); err != nil {
+		slog.ErrorContext(ctx, "RenameServiceRequest: error during rename of budgets", "error", err)
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,74 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"subscriptions/internal/config"
+	"sync/atomic"
+	"time"
+)
+
+// Ожидает доступности базы при старте: база может подняться позже сервиса.
+// Пауза между попытками растёт экспоненциально до ConnectMaxBackoff, половина паузы случайна,
+// чтобы несколько инстансов не переподключались одновременно
+
+func Connect(ctx context.Context, db *sql.DB, cfg config.DatabaseConfig) error {
+	delay := cfg.ConnectBackoff
+
+	for attempt := 1; ; attempt++ {
+		err := db.PingContext(ctx)
+		if err == nil {
+			return nil
+		}
+
+		if attempt >= cfg.ConnectAttempts {
+			return fmt.Errorf("error during connect to database after %d attempts: %w", attempt, err)
+		}
+
+		wait := Jitter(delay)
+		slog.Warn("database is unavailable, retrying", "attempt", attempt, "retry_in", wait.String(), "error", err)
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("error during connect to database: %w", ctx.Err())
+		case <-time.After(wait):
+		}
+
+		delay = min(delay*2, cfg.ConnectMaxBackoff)
+	}
+}
+
+// Случайная пауза от d/2 до d
+
+func Jitter(d time.Duration) time.Duration {
+	if d <= 1 {
+		return d
+	}
+	half := d / 2
+	return half + rand.N(d-half)
+}
+
+// Состояние соединения с базой по результатам Ping. Переподключение выполняет сам пул database/sql,
+// здесь фиксируются только переходы, чтобы потеря и восстановление связи были видны в логах
+
+type connState struct {
+	lost atomic.Bool
+}
+
+func (c *connState) observe(err error) error {
+	if err != nil {
+		if !c.lost.Swap(true) {
+			slog.Error("database connection lost", "error", err)
+		}
+		return err
+	}
+
+	if c.lost.Swap(false) {
+		slog.Info("database connection restored")
+	}
+
+	return nil
+}
@@ -0,0 +1,78 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Форматы дат, которые принимает API. Postgres разбирает их сам, остальным реализациям нужен ParseDate
+
+var dateLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+	"2006-01",
+	"01-2006",
+}
+
+func TimeForm(start time.Time, end time.Time) (string, string) {
+	return start.Format("01-2006"), end.Format("01-2006")
+}
+
+// end_date может быть NULL для бессрочной подписки, тогда в ответе поле пустое
+
+func formDates(start time.Time, end sql.NullTime) (string, string) {
+	startDate, endDate := TimeForm(start, end.Time)
+	if !end.Valid {
+		endDate = ""
+	}
+	return startDate, endDate
+}
+
+// Пустая строка даты сохраняется как NULL, а не как ошибка приведения к timestamp
+
+func nullableDate(date string) any {
+	if date == "" {
+		return nil
+	}
+	return date
+}
+
+func ParseDate(date string) (time.Time, error) {
+	date = strings.TrimSpace(date)
+	for _, layout := range dateLayouts {
+		if t, err := time.Parse(layout, date); err == nil {
+			return t.UTC(), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid date %q", date)
+}
+
+// Дни месяца, в которые у подписки может быть продление между from и to. Подписка продлевается в день начала,
+// а в коротком месяце в последний день, поэтому последний день месяца покрывает и дни, которых в нём нет
+
+func RenewalDays(from time.Time, to time.Time) []int {
+	var days []int
+	seen := map[int]bool{}
+
+	add := func(day int) {
+		if !seen[day] {
+			seen[day] = true
+			days = append(days, day)
+		}
+	}
+
+	for d := from.UTC().Truncate(24 * time.Hour); !d.After(to) && len(days) < 31; d = d.AddDate(0, 0, 1) {
+		add(d.Day())
+		if d.AddDate(0, 0, 1).Day() == 1 {
+			for day := d.Day() + 1; day <= 31; day++ {
+				add(day)
+			}
+		}
+	}
+
+	return days
+}
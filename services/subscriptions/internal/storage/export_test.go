@@ -0,0 +1,44 @@
+package storage
+
+import "subscriptions/internal/config"
+
+// Тексты запросов нужны бенчмаркам для вывода планов через EXPLAIN
+
+const (
+	ReadSubsQuery         = readSubs
+	ShowSubsSumQuery      = showsubssum
+	ShowSubsTotalSumQuery = showsubstotalsum
+	MonthlySpendingQuery  = monthlySpending
+)
+
+// Выбор реплики проверяется без живого Postgres: доступность выставляется вручную
+
+type ReplicaSet = replicaSet
+
+func OpenReplicas(cfg config.DatabaseConfig) (*ReplicaSet, error) {
+	return openReplicas(cfg)
+}
+
+func (rs *replicaSet) SetHealthy(i int, healthy bool) {
+	rs.replicas[i].healthy.Store(healthy)
+}
+
+// Индекс выбранной реплики, -1 если запрос уйдёт на основную базу
+
+func (rs *replicaSet) Pick() int {
+	r := rs.pick()
+	for i, candidate := range rs.replicas {
+		if candidate == r {
+			return i
+		}
+	}
+	return -1
+}
+
+func (rs *replicaSet) Close() error {
+	return rs.close()
+}
+
+// Какие ошибки реплики переводят чтение на основную базу
+
+var IsConnError = isConnError
@@ -0,0 +1,152 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"subscriptions/internal/models"
+	"subscriptions/internal/store"
+	"time"
+)
+
+// Предупреждение уникально для бюджета и месяца, как UNIQUE (budget_id, month) в таблице
+
+type alertKey struct {
+	budgetId int
+	month    string
+}
+
+func (s *Storage) CreateBudgetRequest(ctx context.Context, b models.Budget) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if b.MonthlyLimit < 0 {
+		return 0, fmt.Errorf("monthly_limit must not be negative")
+	}
+	for _, other := range s.budgets {
+		if other.UserId == b.UserId && other.ServiceName == b.ServiceName {
+			return 0, store.ErrBudgetExists
+		}
+	}
+
+	b.Id = s.nextBudgetId
+	s.nextBudgetId++
+	s.budgets[b.Id] = b
+
+	return b.Id, nil
+}
+
+func (s *Storage) ReadBudgetRequest(ctx context.Context, id int) (*models.Budget, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	b, ok := s.budgets[id]
+	if !ok {
+		return nil, store.ErrBudgetNotFound
+	}
+
+	return &b, nil
+}
+
+func (s *Storage) ReadBudgetsRequest(ctx context.Context, userId string) ([]models.Budget, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var budgets []models.Budget
+	for _, id := range slices.Sorted(maps.Keys(s.budgets)) {
+		if b := s.budgets[id]; b.UserId == userId {
+			budgets = append(budgets, b)
+		}
+	}
+
+	return budgets, nil
+}
+
+// Пользователь бюджета не меняется, как и в UPDATE хранилищ на SQL
+
+func (s *Storage) UpdateBudgetRequest(ctx context.Context, b models.Budget) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	old, ok := s.budgets[b.Id]
+	if !ok {
+		return nil
+	}
+	if b.MonthlyLimit < 0 {
+		return fmt.Errorf("monthly_limit must not be negative")
+	}
+	for _, other := range s.budgets {
+		if other.Id != b.Id && other.UserId == old.UserId && other.ServiceName == b.ServiceName {
+			return store.ErrBudgetExists
+		}
+	}
+
+	old.ServiceName, old.MonthlyLimit = b.ServiceName, b.MonthlyLimit
+	s.budgets[b.Id] = old
+
+	return nil
+}
+
+func (s *Storage) DeleteBudgetRequest(ctx context.Context, id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.budgets, id)
+	maps.DeleteFunc(s.alerts, func(key alertKey, _ models.BudgetAlert) bool { return key.budgetId == id })
+
+	return nil
+}
+
+func (s *Storage) SaveAlertRequest(ctx context.Context, a models.BudgetAlert) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := alertKey{budgetId: a.BudgetId, month: a.Month}
+
+	if old, ok := s.alerts[key]; ok {
+		old.MonthlyLimit, old.Spent = a.MonthlyLimit, a.Spent
+		s.alerts[key] = old
+		return nil
+	}
+
+	a.Id = s.nextAlertId
+	s.nextAlertId++
+	a.CreatedAt = time.Now().UTC().Format(time.RFC3339)
+	s.alerts[key] = a
+
+	return nil
+}
+
+func (s *Storage) DeleteAlertRequest(ctx context.Context, budgetId int, month string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.alerts, alertKey{budgetId: budgetId, month: month})
+
+	return nil
+}
+
+// Сначала новые месяцы, как ORDER BY month DESC, id
+
+func (s *Storage) ReadAlertsRequest(ctx context.Context, userId string) ([]models.BudgetAlert, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var alerts []models.BudgetAlert
+	for _, a := range s.alerts {
+		if a.UserId == userId {
+			alerts = append(alerts, a)
+		}
+	}
+
+	slices.SortFunc(alerts, func(a, b models.BudgetAlert) int {
+		if c := strings.Compare(b.Month, a.Month); c != 0 {
+			return c
+		}
+		return a.Id - b.Id
+	})
+
+	return alerts, nil
+}
@@ -0,0 +1,165 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"subscriptions/internal/models"
+	"subscriptions/internal/store"
+)
+
+// Проверки повторяют ограничения таблицы service_catalog, в том числе уникальный индекс по lower(name)
+
+func (s *Storage) checkCatalogEntry(e models.CatalogEntry) error {
+	if e.Name == "" {
+		return fmt.Errorf("name must not be empty")
+	}
+	if e.DefaultPrice < 0 {
+		return fmt.Errorf("default_price must not be negative")
+	}
+	for _, other := range s.catalog {
+		if other.Id != e.Id && strings.EqualFold(other.Name, e.Name) {
+			return fmt.Errorf("%w: %q", store.ErrCatalogConflict, e.Name)
+		}
+	}
+
+	return nil
+}
+
+// Транзакция держит блокировку хранилища целиком, отдельная блокировка каталога не нужна
+
+func (s *Storage) LockCatalogRequest(ctx context.Context) error {
+	return nil
+}
+
+// Записи хранятся копиями, чтобы изменение среза псевдонимов снаружи не меняло хранилище
+
+func (s *Storage) CreateCatalogEntryRequest(ctx context.Context, e models.CatalogEntry) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.checkCatalogEntry(e); err != nil {
+		return 0, err
+	}
+
+	e.Id = s.nextCatalogId
+	s.nextCatalogId++
+	e.Aliases = slices.Clone(e.Aliases)
+	s.catalog[e.Id] = e
+
+	return e.Id, nil
+}
+
+func (s *Storage) ReadCatalogEntryRequest(ctx context.Context, id int) (*models.CatalogEntry, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	e, ok := s.catalog[id]
+	if !ok {
+		return nil, store.ErrCatalogEntryNotFound
+	}
+	e.Aliases = slices.Clone(e.Aliases)
+
+	return &e, nil
+}
+
+func (s *Storage) ReadCatalogRequest(ctx context.Context) ([]models.CatalogEntry, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var entries []models.CatalogEntry
+	for _, id := range slices.Sorted(maps.Keys(s.catalog)) {
+		e := s.catalog[id]
+		e.Aliases = slices.Clone(e.Aliases)
+		entries = append(entries, e)
+	}
+
+	slices.SortStableFunc(entries, func(a, b models.CatalogEntry) int { return strings.Compare(a.Name, b.Name) })
+
+	return entries, nil
+}
+
+func (s *Storage) UpdateCatalogEntryRequest(ctx context.Context, e models.CatalogEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.catalog[e.Id]; !ok {
+		return nil
+	}
+	if err := s.checkCatalogEntry(e); err != nil {
+		return err
+	}
+
+	e.Aliases = slices.Clone(e.Aliases)
+	s.catalog[e.Id] = e
+
+	return nil
+}
+
+func (s *Storage) DeleteCatalogEntryRequest(ctx context.Context, id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.catalog, id)
+
+	return nil
+}
+
+// Как и в SQL, совпадение с каноническим именем важнее совпадения с псевдонимом другой записи
+
+func (s *Storage) FindCatalogEntryRequest(ctx context.Context, name string) (*models.CatalogEntry, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var found *models.CatalogEntry
+	for _, id := range slices.Sorted(maps.Keys(s.catalog)) {
+		e := s.catalog[id]
+		if strings.EqualFold(e.Name, name) {
+			found = &e
+			break
+		}
+		if found == nil && slices.ContainsFunc(e.Aliases, func(a string) bool { return strings.EqualFold(a, name) }) {
+			found = &e
+		}
+	}
+
+	if found == nil {
+		return nil, store.ErrCatalogEntryNotFound
+	}
+	found.Aliases = slices.Clone(found.Aliases)
+
+	return found, nil
+}
+
+// Бюджет переименовывается, только если он у пользователя один на все эти имена, как и в SQL
+
+func (s *Storage) RenameServiceRequest(ctx context.Context, keys []string, name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	matches := func(serviceName string) bool { return slices.Contains(keys, store.ServiceKey(serviceName)) }
+
+	for id, rec := range s.subs {
+		if matches(rec.serviceName) {
+			rec.serviceName = name
+			s.subs[id] = rec
+		}
+	}
+
+	perUser := map[string]int{}
+	for _, b := range s.budgets {
+		if matches(b.ServiceName) {
+			perUser[b.UserId]++
+		}
+	}
+	for id, b := range s.budgets {
+		if matches(b.ServiceName) && perUser[b.UserId] == 1 {
+			b.ServiceName = name
+			s.budgets[id] = b
+		}
+	}
+
+	return nil
+}
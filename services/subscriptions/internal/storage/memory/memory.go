@@ -0,0 +1,367 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"sort"
+	"strings"
+	"subscriptions/internal/models"
+	"subscriptions/internal/storage"
+	"subscriptions/internal/store"
+	"sync"
+	"time"
+)
+
+// Хранилище в памяти с той же семантикой, что и Postgres: для тестов и локального запуска без базы
+
+type record struct {
+	id          int
+	serviceName string
+	price       int
+	userId      string
+	startDate   time.Time
+	endDate     *time.Time
+}
+
+type Storage struct {
+	mu     sync.RWMutex
+	nextId int
+	subs   map[int]record
+
+	nextBudgetId int
+	budgets      map[int]models.Budget
+	nextAlertId  int
+	alerts       map[alertKey]models.BudgetAlert
+
+	reminders map[reminderKey]struct{}
+
+	nextEventId    int
+	events         map[int]event
+	nextWebhookId  int
+	webhooks       map[int]models.Webhook
+	nextDeliveryId int
+	deliveries     map[int]delivery
+
+	nextCatalogId int
+	catalog       map[int]models.CatalogEntry
+}
+
+func NewStorage() *Storage {
+	return &Storage{
+		nextId:       1,
+		subs:         make(map[int]record),
+		nextBudgetId: 1,
+		budgets:      make(map[int]models.Budget),
+		nextAlertId:  1,
+		alerts:       make(map[alertKey]models.BudgetAlert),
+		reminders:    make(map[reminderKey]struct{}),
+
+		nextEventId:    1,
+		events:         make(map[int]event),
+		nextWebhookId:  1,
+		webhooks:       make(map[int]models.Webhook),
+		nextDeliveryId: 1,
+		deliveries:     make(map[int]delivery),
+
+		nextCatalogId: 1,
+		catalog:       make(map[int]models.CatalogEntry),
+	}
+}
+
+// Копия данных для транзакций, вызывается под блокировкой
+
+func (s *Storage) clone() *Storage {
+	return &Storage{
+		nextId:       s.nextId,
+		subs:         maps.Clone(s.subs),
+		nextBudgetId: s.nextBudgetId,
+		budgets:      maps.Clone(s.budgets),
+		nextAlertId:  s.nextAlertId,
+		alerts:       maps.Clone(s.alerts),
+		reminders:    maps.Clone(s.reminders),
+
+		nextEventId:    s.nextEventId,
+		events:         maps.Clone(s.events),
+		nextWebhookId:  s.nextWebhookId,
+		webhooks:       maps.Clone(s.webhooks),
+		nextDeliveryId: s.nextDeliveryId,
+		deliveries:     maps.Clone(s.deliveries),
+
+		nextCatalogId: s.nextCatalogId,
+		catalog:       maps.Clone(s.catalog),
+	}
+}
+
+// Транзакция работает над копией данных и подменяет ими исходные при успехе.
+// Блокировка держится до конца fn, поэтому остальные операции ждут коммита или отката
+
+func (s *Storage) WithTx(ctx context.Context, fn func(tx store.Storage) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tx := s.clone()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+
+	s.nextId, s.subs = tx.nextId, tx.subs
+	s.nextBudgetId, s.budgets = tx.nextBudgetId, tx.budgets
+	s.nextAlertId, s.alerts = tx.nextAlertId, tx.alerts
+	s.reminders = tx.reminders
+	s.nextEventId, s.events = tx.nextEventId, tx.events
+	s.nextWebhookId, s.webhooks = tx.nextWebhookId, tx.webhooks
+	s.nextDeliveryId, s.deliveries = tx.nextDeliveryId, tx.deliveries
+	s.nextCatalogId, s.catalog = tx.nextCatalogId, tx.catalog
+
+	return nil
+}
+
+// Чтение идёт по копии данных, изменения внутри fn отбрасываются
+
+func (s *Storage) WithReadTx(ctx context.Context, fn func(tx store.Storage) error) error {
+	s.mu.RLock()
+	tx := s.clone()
+	s.mu.RUnlock()
+
+	return fn(tx)
+}
+
+func (s *Storage) Ping(ctx context.Context) error {
+	return nil
+}
+
+func (s *Storage) Close() error {
+	return nil
+}
+
+// Разбор и проверка полей подписки, повторяет ограничения таблицы subscriptions
+
+func toRecord(sub models.Subscription) (record, error) {
+	start, err := storage.ParseDate(sub.StartDate)
+	if err != nil {
+		return record{}, err
+	}
+
+	rec := record{
+		id:          sub.Id,
+		serviceName: sub.ServiceName,
+		price:       sub.Price,
+		userId:      sub.UserId,
+		startDate:   start,
+	}
+
+	if sub.EndDate != "" {
+		end, err := storage.ParseDate(sub.EndDate)
+		if err != nil {
+			return record{}, err
+		}
+		rec.endDate = &end
+	}
+
+	if rec.serviceName == "" {
+		return record{}, fmt.Errorf("service_name must not be empty")
+	}
+	if rec.price < 0 {
+		return record{}, fmt.Errorf("price must not be negative")
+	}
+	if rec.endDate != nil && rec.endDate.Before(rec.startDate) {
+		return record{}, fmt.Errorf("end_date must not be before start_date")
+	}
+
+	return rec, nil
+}
+
+func (r record) toModel() models.Subscription {
+	sub := models.Subscription{
+		Id:          r.id,
+		ServiceName: r.serviceName,
+		Price:       r.price,
+		UserId:      r.userId,
+	}
+
+	var end time.Time
+	if r.endDate != nil {
+		end = *r.endDate
+	}
+
+	sub.StartDate, sub.EndDate = storage.TimeForm(r.startDate, end)
+	if r.endDate == nil {
+		sub.EndDate = ""
+	}
+
+	return sub
+}
+
+// Возвращает записи, отобранные filter, в порядке id
+
+func (s *Storage) selectSorted(filter func(record) bool) []models.Subscription {
+	var subs []models.Subscription
+	for _, rec := range s.subs {
+		if filter(rec) {
+			subs = append(subs, rec.toModel())
+		}
+	}
+
+	sort.Slice(subs, func(i, j int) bool { return subs[i].Id < subs[j].Id })
+
+	return subs
+}
+
+func (s *Storage) CreateSubRequest(ctx context.Context, sub models.Subscription) (int, error) {
+	rec, err := toRecord(sub)
+	if err != nil {
+		return 0, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rec.id = s.nextId
+	s.nextId++
+	s.subs[rec.id] = rec
+
+	return rec.id, nil
+}
+
+func (s *Storage) ReadSubRequest(ctx context.Context, id int) (*models.Subscription, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	rec, ok := s.subs[id]
+	if !ok {
+		return nil, store.ErrNotFound
+	}
+
+	sub := rec.toModel()
+
+	return &sub, nil
+}
+
+func (s *Storage) ReadSubsRequest(ctx context.Context, userId string) ([]models.Subscription, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.selectSorted(func(r record) bool { return r.userId == userId }), nil
+}
+
+func (s *Storage) UpdateSubRequest(ctx context.Context, sub models.Subscription) (*models.Subscription, string, error) {
+	rec, err := toRecord(sub)
+	if err != nil {
+		return nil, "", err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	prev, ok := s.subs[rec.id]
+	if !ok {
+		return nil, "", store.ErrNotFound
+	}
+	s.subs[rec.id] = rec
+
+	updated := rec.toModel()
+
+	return &updated, prev.userId, nil
+}
+
+func (s *Storage) DeleteSubRequest(ctx context.Context, id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.subs[id]; !ok {
+		return store.ErrNotFound
+	}
+
+	delete(s.subs, id)
+	maps.DeleteFunc(s.reminders, func(k reminderKey, _ struct{}) bool { return k.subscriptionId == id })
+
+	return nil
+}
+
+// Подписки без даты окончания не попадают в выборку, как и в SQL, где NULL <= $4 не выполняется.
+// Пустой serviceName означает все сервисы пользователя, имя сравнивается без учёта регистра и пробелов по краям
+
+func (s *Storage) ShowSubscSumRequest(ctx context.Context, serviceName string, userId string, startPeriod string, endPeriod string) (*models.SubscriptionSum, error) {
+	from, err := storage.ParseDate(startPeriod)
+	if err != nil {
+		return nil, err
+	}
+
+	to, err := storage.ParseDate(endPeriod)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	subs := s.selectSorted(func(r record) bool {
+		return r.userId == userId &&
+			(serviceName == "" || strings.EqualFold(strings.TrimSpace(r.serviceName), serviceName)) &&
+			!r.startDate.Before(from) &&
+			r.endDate != nil && !r.endDate.After(to)
+	})
+
+	var total int
+	for _, sub := range subs {
+		total += sub.Price
+	}
+
+	return &models.SubscriptionSum{Items: subs, Total: total}, nil
+}
+
+// Помесячные расходы с тем же условием активности, что и в SQL: подписка началась не позже месяца
+// и закончилась не раньше него
+
+func (s *Storage) MonthlySpendingRequest(ctx context.Context, userId string, startPeriod string, endPeriod string) ([]models.MonthlySpending, error) {
+	from, err := storage.ParseDate(startPeriod)
+	if err != nil {
+		return nil, err
+	}
+
+	to, err := storage.ParseDate(endPeriod)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	months := []models.MonthlySpending{}
+	for month := monthStart(from); !month.After(monthStart(to)); month = month.AddDate(0, 1, 0) {
+		// Группировка по store.ServiceKey, в ответе наименьшее из имён группы, как min(btrim(service_name)) в SQL
+		byService := map[string]models.ServiceSpending{}
+		for _, rec := range s.subs {
+			if rec.userId != userId || monthStart(rec.startDate).After(month) {
+				continue
+			}
+			if rec.endDate != nil && monthStart(*rec.endDate).Before(month) {
+				continue
+			}
+			key, name := store.ServiceKey(rec.serviceName), strings.TrimSpace(rec.serviceName)
+			group, ok := byService[key]
+			if !ok || name < group.ServiceName {
+				group.ServiceName = name
+			}
+			group.Total += rec.price
+			byService[key] = group
+		}
+
+		spending := models.MonthlySpending{Month: month.Format("2006-01"), Services: []models.ServiceSpending{}}
+		for _, key := range slices.Sorted(maps.Keys(byService)) {
+			spending.Total += byService[key].Total
+			spending.Services = append(spending.Services, byService[key])
+		}
+
+		months = append(months, spending)
+	}
+
+	return months, nil
+}
+
+func monthStart(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
@@ -0,0 +1,88 @@
+package memory
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"subscriptions/internal/models"
+	"time"
+)
+
+func (s *Storage) ClaimEventsRequest(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]models.Event, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var events []models.Event
+	for _, id := range slices.Sorted(maps.Keys(s.events)) {
+		if len(events) == limit {
+			break
+		}
+		e := s.events[id]
+		if !e.publishedAt.IsZero() || e.leaseUntil.After(now) {
+			continue
+		}
+		e.leaseUntil = now.Add(lease)
+		s.events[id] = e
+		events = append(events, e.Event)
+	}
+
+	return events, nil
+}
+
+func (s *Storage) ReleaseEventsRequest(ctx context.Context, ids []int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, id := range ids {
+		if e, ok := s.events[id]; ok && e.publishedAt.IsZero() {
+			e.leaseUntil = time.Time{}
+			s.events[id] = e
+		}
+	}
+
+	return nil
+}
+
+func (s *Storage) MarkPublishedRequest(ctx context.Context, ids []int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, id := range ids {
+		if e, ok := s.events[id]; ok {
+			e.publishedAt, e.leaseUntil = time.Now(), time.Time{}
+			s.events[id] = e
+		}
+	}
+
+	return nil
+}
+
+// Как и в SQL, вместе с событием удаляются его доставки, а события с недоставленными вебхуками остаются
+
+func (s *Storage) DeleteProcessedEventsRequest(ctx context.Context, before time.Time, limit int) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	pending := map[int]bool{}
+	for _, d := range s.deliveries {
+		if d.Status == "pending" {
+			pending[d.EventId] = true
+		}
+	}
+
+	n := 0
+	for _, id := range slices.Sorted(maps.Keys(s.events)) {
+		if n == limit {
+			break
+		}
+		e := s.events[id]
+		if e.publishedAt.IsZero() || !e.publishedAt.Before(before) || e.dispatchedAt.IsZero() || !e.dispatchedAt.Before(before) || pending[id] {
+			continue
+		}
+		delete(s.events, id)
+		maps.DeleteFunc(s.deliveries, func(_ int, d delivery) bool { return d.EventId == id })
+		n++
+	}
+
+	return n, nil
+}
@@ -0,0 +1,70 @@
+package memory
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"subscriptions/internal/models"
+	"subscriptions/internal/storage"
+	"time"
+)
+
+// Отметка об отправке уникальна для подписки, вида напоминания и дня, как первичный ключ reminders_sent
+
+type reminderKey struct {
+	subscriptionId int
+	kind           string
+	date           string
+}
+
+// Как и в SQL, из действующих в окне подписок берутся те, что в нём заканчиваются или продлеваются
+
+func (s *Storage) ActiveSubsRequest(ctx context.Context, from time.Time, to time.Time) ([]models.ActiveSubscription, error) {
+	days := storage.RenewalDays(from, to)
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var subs []models.ActiveSubscription
+	for _, id := range slices.Sorted(maps.Keys(s.subs)) {
+		rec := s.subs[id]
+		if rec.startDate.After(to) || rec.endDate != nil && rec.endDate.Before(from) {
+			continue
+		}
+		if (rec.endDate == nil || rec.endDate.After(to)) && !slices.Contains(days, rec.startDate.Day()) {
+			continue
+		}
+		subs = append(subs, models.ActiveSubscription{
+			Id:          rec.id,
+			ServiceName: rec.serviceName,
+			Price:       rec.price,
+			UserId:      rec.userId,
+			StartDate:   rec.startDate,
+			EndDate:     rec.endDate,
+		})
+	}
+
+	return subs, nil
+}
+
+func (s *Storage) ClaimReminderRequest(ctx context.Context, r models.Reminder) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := reminderKey{r.SubscriptionId, r.Kind, r.Date}
+	if _, ok := s.reminders[key]; ok {
+		return false, nil
+	}
+	s.reminders[key] = struct{}{}
+
+	return true, nil
+}
+
+func (s *Storage) ReleaseReminderRequest(ctx context.Context, r models.Reminder) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.reminders, reminderKey{r.SubscriptionId, r.Kind, r.Date})
+
+	return nil
+}
@@ -0,0 +1,208 @@
+package memory
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"subscriptions/internal/models"
+	"subscriptions/internal/store"
+	"time"
+)
+
+// Событие outbox, время создания доставок для него, публикации в брокер и конца аренды публикации.
+// Нулевое время соответствует NULL в SQL
+
+type event struct {
+	models.Event
+	dispatchedAt time.Time
+	publishedAt  time.Time
+	leaseUntil   time.Time
+}
+
+// Доставка и время следующей попытки, которое в модели хранится строкой
+
+type delivery struct {
+	models.WebhookDelivery
+	nextAttempt time.Time
+}
+
+func now() string {
+	return time.Now().UTC().Format(time.RFC3339)
+}
+
+func (s *Storage) AddEventRequest(ctx context.Context, e models.Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e.Id = s.nextEventId
+	e.CreatedAt = now()
+	s.nextEventId++
+	s.events[e.Id] = event{Event: e}
+
+	return nil
+}
+
+func (s *Storage) CreateWebhookRequest(ctx context.Context, wh models.Webhook) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	wh.Id = s.nextWebhookId
+	wh.CreatedAt = now()
+	s.nextWebhookId++
+	s.webhooks[wh.Id] = wh
+
+	return wh.Id, nil
+}
+
+func (s *Storage) ReadWebhookRequest(ctx context.Context, id int) (*models.Webhook, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	wh, ok := s.webhooks[id]
+	if !ok {
+		return nil, store.ErrWebhookNotFound
+	}
+
+	return &wh, nil
+}
+
+func (s *Storage) ReadWebhooksRequest(ctx context.Context, userId string) ([]models.Webhook, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var webhooks []models.Webhook
+	for _, id := range slices.Sorted(maps.Keys(s.webhooks)) {
+		if wh := s.webhooks[id]; wh.UserId == userId {
+			webhooks = append(webhooks, wh)
+		}
+	}
+
+	return webhooks, nil
+}
+
+// Доставки удаляются вместе с вебхуком, как ON DELETE CASCADE
+
+func (s *Storage) DeleteWebhookRequest(ctx context.Context, id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.webhooks, id)
+	maps.DeleteFunc(s.deliveries, func(_ int, d delivery) bool { return d.WebhookId == id })
+
+	return nil
+}
+
+func (s *Storage) ReadDeliveriesRequest(ctx context.Context, webhookId int) ([]models.WebhookDelivery, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var deliveries []models.WebhookDelivery
+	for _, id := range slices.Backward(slices.Sorted(maps.Keys(s.deliveries))) {
+		d := s.deliveries[id]
+		if d.WebhookId != webhookId {
+			continue
+		}
+		if d.Status == "pending" {
+			d.NextAttemptAt = d.nextAttempt.UTC().Format(time.RFC3339)
+		}
+		if deliveries = append(deliveries, d.WebhookDelivery); len(deliveries) == 100 {
+			break
+		}
+	}
+
+	return deliveries, nil
+}
+
+func (s *Storage) DispatchEventsRequest(ctx context.Context, limit int) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	n := 0
+	for _, id := range slices.Sorted(maps.Keys(s.events)) {
+		e := s.events[id]
+		if !e.dispatchedAt.IsZero() {
+			continue
+		}
+		if n == limit {
+			break
+		}
+
+		for _, whId := range slices.Sorted(maps.Keys(s.webhooks)) {
+			if s.webhooks[whId].UserId != e.UserId {
+				continue
+			}
+			d := delivery{
+				WebhookDelivery: models.WebhookDelivery{
+					Id:        s.nextDeliveryId,
+					WebhookId: whId,
+					EventId:   e.Id,
+					EventType: e.Type,
+					Status:    "pending",
+					CreatedAt: now(),
+				},
+				nextAttempt: time.Now(),
+			}
+			s.nextDeliveryId++
+			s.deliveries[d.Id] = d
+		}
+
+		e.dispatchedAt = time.Now()
+		s.events[id] = e
+		n++
+	}
+
+	return n, nil
+}
+
+func (s *Storage) ClaimDeliveriesRequest(ctx context.Context, at time.Time, lease time.Duration, limit int) ([]models.PendingDelivery, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	due := slices.Collect(maps.Values(s.deliveries))
+	due = slices.DeleteFunc(due, func(d delivery) bool { return d.Status != "pending" || d.nextAttempt.After(at) })
+	slices.SortFunc(due, func(a, b delivery) int {
+		if c := a.nextAttempt.Compare(b.nextAttempt); c != 0 {
+			return c
+		}
+		return a.Id - b.Id
+	})
+
+	var claimed []models.PendingDelivery
+	for _, d := range due[:min(limit, len(due))] {
+		wh := s.webhooks[d.WebhookId]
+		claimed = append(claimed, models.PendingDelivery{
+			Id:       d.Id,
+			Attempts: d.Attempts,
+			URL:      wh.URL,
+			Secret:   wh.Secret,
+			Event:    s.events[d.EventId].Event,
+		})
+
+		d.nextAttempt = at.Add(lease)
+		s.deliveries[d.Id] = d
+	}
+
+	return claimed, nil
+}
+
+func (s *Storage) SaveAttemptRequest(ctx context.Context, deliveryId int, a models.DeliveryAttempt) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	d, ok := s.deliveries[deliveryId]
+	if !ok {
+		return nil
+	}
+
+	d.Status = a.Status
+	d.Attempts++
+	d.LastStatusCode = a.StatusCode
+	d.LastError = a.Error
+	d.nextAttempt = a.NextAttemptAt
+	if a.Status == "delivered" {
+		d.DeliveredAt = a.At.UTC().Format(time.RFC3339)
+	}
+	s.deliveries[deliveryId] = d
+
+	return nil
+}
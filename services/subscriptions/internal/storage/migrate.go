@@ -0,0 +1,220 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+
+	"github.com/golang-migrate/migrate/v4"
+	"github.com/golang-migrate/migrate/v4/database"
+	"github.com/golang-migrate/migrate/v4/database/postgres"
+	"github.com/golang-migrate/migrate/v4/source"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
+)
+
+const migrationVersion = "SELECT version, dirty FROM schema_migrations LIMIT 1"
+
+// Создаёт драйвер golang-migrate поверх общего *sql.DB. Close драйвера не должен закрывать сам *sql.DB
+
+type MigrationDriver func(ctx context.Context, db *sql.DB) (database.Driver, error)
+
+// Обвязка над golang-migrate, общая для всех SQL хранилищ
+
+type Migrator struct {
+	db     *sql.DB
+	name   string
+	fsys   fs.FS
+	driver MigrationDriver
+}
+
+// Миграции берутся из embedded или, если задан path, из каталога на диске
+
+func NewMigrator(db *sql.DB, name string, embedded fs.FS, path string, driver MigrationDriver) *Migrator {
+	fsys := embedded
+	if path != "" {
+		fsys = os.DirFS(path)
+	}
+
+	return &Migrator{db: db, name: name, fsys: fsys, driver: driver}
+}
+
+// Драйвер создаётся на отдельном соединении из пула, а не через WithInstance:
+// тогда Close освобождает только это соединение и не закрывает общий *sql.DB
+
+func postgresDriver(ctx context.Context, db *sql.DB) (database.Driver, error) {
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error during database connection for migrations: %w", err)
+	}
+
+	driver, err := postgres.WithConnection(ctx, conn, &postgres.Config{})
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	return driver, nil
+}
+
+func (m *Migrator) source() (source.Driver, error) {
+	src, err := iofs.New(m.fsys, ".")
+	if err != nil {
+		return nil, fmt.Errorf("error during migration source initialization: %w", err)
+	}
+
+	return src, nil
+}
+
+func (m *Migrator) newMigrate() (*migrate.Migrate, error) {
+	src, err := m.source()
+	if err != nil {
+		return nil, err
+	}
+
+	driver, err := m.driver(context.Background(), m.db)
+	if err != nil {
+		src.Close()
+		return nil, fmt.Errorf("error during database driver initialization: %w", err)
+	}
+
+	mg, err := migrate.NewWithInstance("iofs", src, m.name, driver)
+	if err != nil {
+		src.Close()
+		driver.Close()
+		return nil, fmt.Errorf("error during migration initialization: %w", err)
+	}
+
+	return mg, nil
+}
+
+// Применяет steps миграций вверх, все оставшиеся при steps <= 0
+
+func (m *Migrator) MigrateUp(steps int) error {
+	mg, err := m.newMigrate()
+	if err != nil {
+		return err
+	}
+	defer mg.Close()
+
+	if steps > 0 {
+		err = mg.Steps(steps)
+	} else {
+		err = mg.Up()
+	}
+
+	if err != nil && !errors.Is(err, migrate.ErrNoChange) {
+		return fmt.Errorf("error during migration up: %w", err)
+	}
+
+	return nil
+}
+
+// Откатывает steps миграций, все при steps <= 0
+
+func (m *Migrator) MigrateDown(steps int) error {
+	mg, err := m.newMigrate()
+	if err != nil {
+		return err
+	}
+	defer mg.Close()
+
+	if steps > 0 {
+		err = mg.Steps(-steps)
+	} else {
+		err = mg.Down()
+	}
+
+	if err != nil && !errors.Is(err, migrate.ErrNoChange) {
+		return fmt.Errorf("error during migration down: %w", err)
+	}
+
+	return nil
+}
+
+func (m *Migrator) MigrationVersion() (version uint, dirty bool, err error) {
+	mg, err := m.newMigrate()
+	if err != nil {
+		return 0, false, err
+	}
+	defer mg.Close()
+
+	version, dirty, err = mg.Version()
+	if errors.Is(err, migrate.ErrNilVersion) {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, fmt.Errorf("error during read of migration version: %w", err)
+	}
+
+	return version, dirty, nil
+}
+
+// Принудительно выставляет версию без выполнения SQL, используется для снятия флага dirty
+
+func (m *Migrator) ForceMigration(version int) error {
+	mg, err := m.newMigrate()
+	if err != nil {
+		return err
+	}
+	defer mg.Close()
+
+	if err := mg.Force(version); err != nil {
+		return fmt.Errorf("error during migration force: %w", err)
+	}
+
+	return nil
+}
+
+// Проверяет, что в базе применена последняя известная миграция и она не осталась в состоянии dirty
+
+func (m *Migrator) CheckMigrations(ctx context.Context) error {
+	latest, err := m.latestMigration()
+	if err != nil {
+		return err
+	}
+
+	var version uint
+	var dirty bool
+
+	err = m.db.QueryRowContext(ctx, migrationVersion).Scan(&version, &dirty)
+	if err != nil {
+		return fmt.Errorf("error during read of migration version: %w", err)
+	}
+
+	if dirty {
+		return fmt.Errorf("migration %d is dirty", version)
+	}
+
+	if version != latest {
+		return fmt.Errorf("database is at migration %d, expected %d", version, latest)
+	}
+
+	return nil
+}
+
+func (m *Migrator) latestMigration() (uint, error) {
+	src, err := m.source()
+	if err != nil {
+		return 0, err
+	}
+	defer src.Close()
+
+	version, err := src.First()
+	if err != nil {
+		return 0, fmt.Errorf("error during read of migrations: %w", err)
+	}
+
+	for {
+		next, err := src.Next(version)
+		if errors.Is(err, fs.ErrNotExist) {
+			return version, nil
+		}
+		if err != nil {
+			return 0, fmt.Errorf("error during read of migrations: %w", err)
+		}
+		version = next
+	}
+}
@@ -0,0 +1,96 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"subscriptions/internal/models"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+const (
+	// Взятое событие откладывается на время аренды, как доставки вебхуков. SKIP LOCKED не даёт двум инстансам
+	// взять одно событие, а если взявший упадёт, событие возьмут снова после lease
+	claimEvents = `WITH claimed AS (
+			UPDATE outbox SET publish_lease_until = $2
+			WHERE id IN (SELECT id FROM outbox WHERE published_at IS NULL AND (publish_lease_until IS NULL OR publish_lease_until <= $1)
+				ORDER BY id LIMIT $3 FOR UPDATE SKIP LOCKED)
+			RETURNING id, event_type, user_id, payload, created_at
+		)
+		SELECT id, event_type, user_id, payload, created_at FROM claimed ORDER BY id`
+	releaseEvents = "UPDATE outbox SET publish_lease_until = NULL WHERE id = ANY($1) AND published_at IS NULL"
+	markPublished = "UPDATE outbox SET published_at = now(), publish_lease_until = NULL WHERE id = ANY($1)"
+
+	// Вместе с событием каскадом удаляется журнал его доставок, поэтому события с недоставленными вебхуками остаются
+	deleteProcessedEvents = `DELETE FROM outbox WHERE id IN (
+			SELECT o.id FROM outbox o WHERE o.published_at < $1 AND o.dispatched_at < $1
+				AND NOT EXISTS (SELECT 1 FROM webhook_deliveries d WHERE d.event_id = o.id AND d.status = 'pending')
+			ORDER BY o.id LIMIT $2)`
+)
+
+// Берёт в аренду до limit ещё не опубликованных в брокер событий в порядке записи
+
+func (s *Storage) ClaimEventsRequest(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]models.Event, error) {
+	rows, err := s.query(ctx, "claim_events", claimEvents, now, now.Add(lease), limit)
+	if err != nil {
+		slog.ErrorContext(ctx, "ClaimEventsRequest: error during claim of outbox events", "error", err)
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var events []models.Event
+	for rows.Next() {
+		var e models.Event
+		var payload []byte
+		var createdAt time.Time
+		if err := rows.Scan(&e.Id, &e.Type, &e.UserId, &payload, &createdAt); err != nil {
+			slog.ErrorContext(ctx, "ClaimEventsRequest: error during rowscan", "error", err)
+			return nil, err
+		}
+		if err := json.Unmarshal(payload, &e.Data); err != nil {
+			return nil, err
+		}
+		e.CreatedAt = createdAt.UTC().Format(time.RFC3339)
+		events = append(events, e)
+	}
+
+	return events, rows.Err()
+}
+
+// Снимает аренду с неопубликованных событий, чтобы следующий проход взял их сразу
+
+func (s *Storage) ReleaseEventsRequest(ctx context.Context, ids []int) error {
+	_, err := s.exec(ctx, "release_events", releaseEvents, pq.Array(ids))
+	if err != nil {
+		slog.ErrorContext(ctx, "ReleaseEventsRequest: error during update of outbox events", "error", err)
+		return err
+	}
+
+	return nil
+}
+
+func (s *Storage) MarkPublishedRequest(ctx context.Context, ids []int) error {
+	_, err := s.exec(ctx, "mark_published", markPublished, pq.Array(ids))
+	if err != nil {
+		slog.ErrorContext(ctx, "MarkPublishedRequest: error during update of outbox events", "error", err)
+		return err
+	}
+
+	return nil
+}
+
+// Удаляет до limit событий, опубликованных и разосланных на вебхуки раньше before
+
+func (s *Storage) DeleteProcessedEventsRequest(ctx context.Context, before time.Time, limit int) (int, error) {
+	res, err := s.exec(ctx, "delete_processed_events", deleteProcessedEvents, before, limit)
+	if err != nil {
+		slog.ErrorContext(ctx, "DeleteProcessedEventsRequest: error during delete of outbox events", "error", err)
+		return 0, err
+	}
+
+	n, err := res.RowsAffected()
+	return int(n), err
+}
@@ -0,0 +1,77 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+	"subscriptions/internal/models"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+const (
+	// Из действующих в окне подписок берутся те, что в нём заканчиваются или продлеваются: день начала входит в $3
+	activeSubs = `SELECT id, service_name, price, user_id, start_date, end_date FROM subscriptions
+		WHERE start_date <= $2 AND (end_date IS NULL OR end_date >= $1)
+			AND (end_date <= $2 OR extract(day FROM start_date AT TIME ZONE 'UTC')::int = ANY($3)) ORDER BY id`
+	claimReminder   = "INSERT INTO reminders_sent (subscription_id, kind, due_date) VALUES ($1, $2, $3) ON CONFLICT DO NOTHING"
+	releaseReminder = "DELETE FROM reminders_sent WHERE subscription_id = $1 AND kind = $2 AND due_date = $3"
+)
+
+// Подписки, у которых между from и to может быть продление или окончание. Точные даты считает планировщик
+
+func (s *Storage) ActiveSubsRequest(ctx context.Context, from time.Time, to time.Time) ([]models.ActiveSubscription, error) {
+	rows, err := s.readQuery(ctx, "active_subs", activeSubs, from, to, pq.Array(RenewalDays(from, to)))
+	if err != nil {
+		slog.ErrorContext(ctx, "ActiveSubsRequest: error during read of subscription records", "error", err)
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var subs []models.ActiveSubscription
+	for rows.Next() {
+		var sub models.ActiveSubscription
+		var endDate sql.NullTime
+		if err := rows.Scan(&sub.Id, &sub.ServiceName, &sub.Price, &sub.UserId, &sub.StartDate, &endDate); err != nil {
+			slog.ErrorContext(ctx, "ActiveSubsRequest: error during rowscan", "error", err)
+			return nil, err
+		}
+		sub.StartDate = sub.StartDate.UTC()
+		if endDate.Valid {
+			end := endDate.Time.UTC()
+			sub.EndDate = &end
+		}
+		subs = append(subs, sub)
+	}
+
+	return subs, rows.Err()
+}
+
+// Отмечает напоминание отправленным. false — отметка уже есть, напоминание отправлено раньше или другим инстансом
+
+func (s *Storage) ClaimReminderRequest(ctx context.Context, r models.Reminder) (bool, error) {
+	res, err := s.exec(ctx, "claim_reminder", claimReminder, r.SubscriptionId, r.Kind, r.Date)
+	if err != nil {
+		slog.ErrorContext(ctx, "ClaimReminderRequest: error during save of reminder", "error", err)
+		return false, err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return n == 1, nil
+}
+
+func (s *Storage) ReleaseReminderRequest(ctx context.Context, r models.Reminder) error {
+	_, err := s.exec(ctx, "release_reminder", releaseReminder, r.SubscriptionId, r.Kind, r.Date)
+	if err != nil {
+		slog.ErrorContext(ctx, "ReleaseReminderRequest: error during delete of reminder", "error", err)
+		return err
+	}
+
+	return nil
+}
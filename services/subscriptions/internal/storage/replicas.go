@@ -0,0 +1,149 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"subscriptions/internal/config"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Реплика для запросов на чтение. Недоступная реплика пропускается до следующей успешной проверки
+
+type replica struct {
+	name    string // DSN со скрытым паролем, только для логов
+	db      *sql.DB
+	healthy atomic.Bool
+}
+
+type replicaSet struct {
+	replicas []*replica
+	next     atomic.Uint64
+	interval time.Duration
+	done     chan struct{}
+	wg       sync.WaitGroup
+}
+
+// Реплики открываются без обязательного Ping: недоступная при старте реплика не мешает запуску,
+// запросы идут на основную базу, пока проверка не отметит реплику доступной
+
+func openReplicas(cfg config.DatabaseConfig) (*replicaSet, error) {
+	if len(cfg.ReplicaURLs) == 0 {
+		return nil, nil
+	}
+
+	rs := &replicaSet{
+		interval: cfg.ReplicaCheck,
+		done:     make(chan struct{}),
+	}
+
+	for _, url := range cfg.ReplicaURLs {
+		db, err := sql.Open("postgres", url)
+		if err != nil {
+			rs.closeDBs()
+			return nil, fmt.Errorf("error during replica %s start: %w", config.MaskDSN(url), err)
+		}
+
+		db.SetMaxOpenConns(cfg.MaxOpenConns)
+		db.SetMaxIdleConns(cfg.MaxIdleConns)
+		db.SetConnMaxIdleTime(cfg.ConnMaxIdleTime)
+
+		rs.replicas = append(rs.replicas, &replica{name: config.MaskDSN(url), db: db})
+	}
+
+	rs.check()
+
+	rs.wg.Add(1)
+	go rs.watch()
+
+	return rs, nil
+}
+
+// Следующая доступная реплика по кругу, nil если доступных нет
+
+func (rs *replicaSet) pick() *replica {
+	if rs == nil {
+		return nil
+	}
+
+	n := uint64(len(rs.replicas))
+	start := rs.next.Add(1)
+	for i := uint64(0); i < n; i++ {
+		r := rs.replicas[(start+i)%n]
+		if r.healthy.Load() {
+			return r
+		}
+	}
+
+	return nil
+}
+
+func (rs *replicaSet) watch() {
+	defer rs.wg.Done()
+
+	ticker := time.NewTicker(rs.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-rs.done:
+			return
+		case <-ticker.C:
+			rs.check()
+		}
+	}
+}
+
+func (rs *replicaSet) check() {
+	var wg sync.WaitGroup
+	for _, r := range rs.replicas {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			ctx, cancel := context.WithTimeout(context.Background(), rs.interval)
+			defer cancel()
+
+			r.setHealthy(r.db.PingContext(ctx))
+		}()
+	}
+	wg.Wait()
+}
+
+// В лог попадают только смены состояния, а не каждая проверка
+
+func (r *replica) setHealthy(err error) {
+	healthy := err == nil
+	if r.healthy.Swap(healthy) == healthy {
+		return
+	}
+
+	if healthy {
+		slog.Info("database replica is available", "replica", r.name)
+	} else {
+		slog.Warn("database replica is unavailable, reads fall back to primary", "replica", r.name, "error", err)
+	}
+}
+
+func (rs *replicaSet) close() error {
+	if rs == nil {
+		return nil
+	}
+
+	close(rs.done)
+	rs.wg.Wait()
+
+	return rs.closeDBs()
+}
+
+func (rs *replicaSet) closeDBs() error {
+	var errs []error
+	for _, r := range rs.replicas {
+		errs = append(errs, r.db.Close())
+	}
+	return errors.Join(errs...)
+}
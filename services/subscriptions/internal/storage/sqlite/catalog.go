@@ -0,0 +1,187 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"subscriptions/internal/models"
+	"subscriptions/internal/store"
+)
+
+const (
+	createCatalogEntry = "INSERT INTO service_catalog (name, aliases, category, default_price, logo_url) VALUES (?, ?, ?, ?, ?) RETURNING id"
+	readCatalogEntry   = "SELECT id, name, aliases, category, default_price, logo_url FROM service_catalog WHERE id = ?"
+	readCatalog        = "SELECT id, name, aliases, category, default_price, logo_url FROM service_catalog ORDER BY name"
+	updateCatalogEntry = "UPDATE service_catalog SET name = ?, aliases = ?, category = ?, default_price = ?, logo_url = ? WHERE id = ?"
+	deleteCatalogEntry = "DELETE FROM service_catalog WHERE id = ?"
+	findCatalogEntry   = `SELECT id, name, aliases, category, default_price, logo_url FROM service_catalog
+		WHERE lower(name) = lower(?1) OR EXISTS (SELECT 1 FROM json_each(aliases) WHERE lower(value) = lower(?1))
+		ORDER BY lower(name) <> lower(?1), id LIMIT 1`
+
+	// Ключи передаются JSON массивом, как и псевдонимы
+	renameSubs = "UPDATE subscriptions SET service_name = ?1 WHERE lower(trim(service_name)) IN (SELECT value FROM json_each(?2)) AND service_name <> ?1"
+
+	// Бюджет переименовывается, только если он у пользователя один на все эти имена, иначе нарушится UNIQUE (user_id, service_name)
+	renameBudgets = `UPDATE budgets SET service_name = ?1 WHERE lower(trim(service_name)) IN (SELECT value FROM json_each(?2)) AND service_name <> ?1
+		AND (SELECT count(*) FROM budgets o WHERE o.user_id = budgets.user_id AND lower(trim(o.service_name)) IN (SELECT value FROM json_each(?2))) = 1`
+)
+
+// Псевдонимы хранятся JSON массивом, отсутствие псевдонимов — пустой массив
+
+func encodeAliases(aliases []string) (string, error) {
+	if aliases == nil {
+		aliases = []string{}
+	}
+	b, err := json.Marshal(aliases)
+	return string(b), err
+}
+
+func (s *Storage) readCatalogEntry(ctx context.Context, operation string, query string, arg any) (*models.CatalogEntry, error) {
+	var e models.CatalogEntry
+	var aliases string
+
+	err := s.queryRow(ctx, operation, query, []any{arg}, &e.Id, &e.Name, &aliases, &e.Category, &e.DefaultPrice, &e.LogoURL)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal([]byte(aliases), &e.Aliases); err != nil {
+		return nil, err
+	}
+
+	return &e, nil
+}
+
+// SQLite держит одно соединение, транзакции записи и так идут по очереди
+
+func (s *Storage) LockCatalogRequest(ctx context.Context) error {
+	return nil
+}
+
+func (s *Storage) CreateCatalogEntryRequest(ctx context.Context, e models.CatalogEntry) (int, error) {
+	aliases, err := encodeAliases(e.Aliases)
+	if err != nil {
+		return 0, err
+	}
+
+	var id int
+
+	err = s.queryRow(ctx, "create_catalog_entry", createCatalogEntry, []any{e.Name, aliases, e.Category, e.DefaultPrice, e.LogoURL}, &id)
+	if err != nil {
+		if isUniqueViolation(err, "service_catalog_name_key") {
+			return 0, fmt.Errorf("%w: %q", store.ErrCatalogConflict, e.Name)
+		}
+		slog.ErrorContext(ctx, "CreateCatalogEntryRequest: error during creation of catalog record", "error", err)
+		return 0, err
+	}
+
+	return id, nil
+}
+
+func (s *Storage) ReadCatalogEntryRequest(ctx context.Context, id int) (*models.CatalogEntry, error) {
+	e, err := s.readCatalogEntry(ctx, "read_catalog_entry", readCatalogEntry, id)
+
+	if err == sql.ErrNoRows {
+		slog.WarnContext(ctx, "ReadCatalogEntryRequest: catalog record not found", "id", id)
+		return nil, store.ErrCatalogEntryNotFound
+	}
+
+	if err != nil {
+		slog.ErrorContext(ctx, "ReadCatalogEntryRequest: error during read of catalog record", "error", err)
+		return nil, err
+	}
+
+	return e, nil
+}
+
+func (s *Storage) ReadCatalogRequest(ctx context.Context) ([]models.CatalogEntry, error) {
+	rows, err := s.query(ctx, "read_catalog", readCatalog)
+	if err != nil {
+		slog.ErrorContext(ctx, "ReadCatalogRequest: error during read of catalog records", "error", err)
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var entries []models.CatalogEntry
+	for rows.Next() {
+		var e models.CatalogEntry
+		var aliases string
+		if err := rows.Scan(&e.Id, &e.Name, &aliases, &e.Category, &e.DefaultPrice, &e.LogoURL); err != nil {
+			slog.ErrorContext(ctx, "ReadCatalogRequest: error during rowscan", "error", err)
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(aliases), &e.Aliases); err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+
+	return entries, rows.Err()
+}
+
+func (s *Storage) UpdateCatalogEntryRequest(ctx context.Context, e models.CatalogEntry) error {
+	aliases, err := encodeAliases(e.Aliases)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.exec(ctx, "update_catalog_entry", updateCatalogEntry, e.Name, aliases, e.Category, e.DefaultPrice, e.LogoURL, e.Id)
+	if err != nil {
+		if isUniqueViolation(err, "service_catalog_name_key") {
+			return fmt.Errorf("%w: %q", store.ErrCatalogConflict, e.Name)
+		}
+		slog.ErrorContext(ctx, "UpdateCatalogEntryRequest: error during update of catalog record", "error", err)
+		return err
+	}
+
+	return nil
+}
+
+func (s *Storage) DeleteCatalogEntryRequest(ctx context.Context, id int) error {
+	_, err := s.exec(ctx, "delete_catalog_entry", deleteCatalogEntry, id)
+	if err != nil {
+		slog.ErrorContext(ctx, "DeleteCatalogEntryRequest: error during delete of catalog record", "error", err)
+		return err
+	}
+
+	return nil
+}
+
+// lower в SQLite меняет регистр только латиницы, кириллические имена совпадают лишь в одинаковом регистре
+
+func (s *Storage) FindCatalogEntryRequest(ctx context.Context, name string) (*models.CatalogEntry, error) {
+	e, err := s.readCatalogEntry(ctx, "find_catalog_entry", findCatalogEntry, name)
+
+	if err == sql.ErrNoRows {
+		return nil, store.ErrCatalogEntryNotFound
+	}
+
+	if err != nil {
+		slog.ErrorContext(ctx, "FindCatalogEntryRequest: error during search of catalog record", "error", err)
+		return nil, err
+	}
+
+	return e, nil
+}
+
+func (s *Storage) RenameServiceRequest(ctx context.Context, keys []string, name string) error {
+	encoded, err := json.Marshal(keys)
+	if err != nil {
+		return err
+	}
+
+	if _, err := s.exec(ctx, "rename_service_subs", renameSubs, name, string(encoded)); err != nil {
+		slog.ErrorContext(ctx, "RenameServiceRequest: error during rename of subscriptions", "error", err)
+		return err
+	}
+
+	if _, err := s.exec(ctx, "rename_service_budgets", renameBudgets, name, string(encoded)); err != nil {
+		slog.ErrorContext(ctx, "RenameServiceRequest: error during rename of budgets", "error", err)
+		return err
+	}
+
+	return nil
+}
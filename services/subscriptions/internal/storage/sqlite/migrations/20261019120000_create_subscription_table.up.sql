@@ -0,0 +1,14 @@
+-- Даты хранятся текстом в UTC в формате 2006-01-02T15:04:05Z, поэтому строковое сравнение совпадает с хронологическим
+CREATE TABLE subscriptions(
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    service_name TEXT NOT NULL,
+    price INTEGER NOT NULL CHECK (price >= 0),
+    user_id TEXT NOT NULL CHECK (length(user_id) = 36),
+    start_date TEXT NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%SZ', 'now')),
+    end_date TEXT,
+    CHECK (end_date IS NULL OR end_date >= start_date)
+);
+
+CREATE INDEX subscriptions_user_start_idx ON subscriptions (user_id, start_date);
+
+CREATE INDEX subscriptions_user_service_period_idx ON subscriptions (user_id, service_name, start_date, end_date);
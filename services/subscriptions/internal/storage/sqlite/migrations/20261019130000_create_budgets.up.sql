@@ -0,0 +1,22 @@
+-- Месяц предупреждения хранится текстом YYYY-MM. Каскадное удаление работает при включённом PRAGMA foreign_keys
+CREATE TABLE budgets(
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id TEXT NOT NULL CHECK (length(user_id) = 36),
+    service_name TEXT NOT NULL DEFAULT '',
+    monthly_limit INTEGER NOT NULL CHECK (monthly_limit >= 0),
+    UNIQUE (user_id, service_name)
+);
+
+CREATE TABLE budget_alerts(
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    budget_id INTEGER NOT NULL REFERENCES budgets(id) ON DELETE CASCADE,
+    user_id TEXT NOT NULL,
+    service_name TEXT NOT NULL,
+    month TEXT NOT NULL,
+    monthly_limit INTEGER NOT NULL,
+    spent INTEGER NOT NULL,
+    created_at TEXT NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%SZ', 'now')),
+    UNIQUE (budget_id, month)
+);
+
+CREATE INDEX budget_alerts_user_month_idx ON budget_alerts (user_id, month);
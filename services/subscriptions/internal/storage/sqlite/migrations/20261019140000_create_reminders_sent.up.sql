@@ -0,0 +1,8 @@
+-- День события хранится текстом YYYY-MM-DD
+CREATE TABLE reminders_sent(
+    subscription_id INTEGER NOT NULL REFERENCES subscriptions(id) ON DELETE CASCADE,
+    kind TEXT NOT NULL,
+    due_date TEXT NOT NULL,
+    sent_at TEXT NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%SZ', 'now')),
+    PRIMARY KEY (subscription_id, kind, due_date)
+);
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS outbox_unpublished_idx;
+ALTER TABLE outbox DROP COLUMN published_at;
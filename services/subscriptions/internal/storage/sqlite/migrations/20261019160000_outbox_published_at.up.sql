@@ -0,0 +1,3 @@
+ALTER TABLE outbox ADD COLUMN published_at TEXT;
+
+CREATE INDEX outbox_unpublished_idx ON outbox (id) WHERE published_at IS NULL;
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS subscriptions_user_service_period_idx;
+CREATE INDEX subscriptions_user_service_period_idx ON subscriptions (user_id, service_name, start_date, end_date);
+
+DROP TABLE IF EXISTS service_catalog;
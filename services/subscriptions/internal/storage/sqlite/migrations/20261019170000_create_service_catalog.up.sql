@@ -0,0 +1,14 @@
+-- Псевдонимы хранятся JSON массивом строк
+CREATE TABLE service_catalog(
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT NOT NULL,
+    aliases TEXT NOT NULL DEFAULT '[]',
+    category TEXT NOT NULL DEFAULT '',
+    default_price INTEGER NOT NULL DEFAULT 0 CHECK (default_price >= 0),
+    logo_url TEXT NOT NULL DEFAULT ''
+);
+
+CREATE UNIQUE INDEX service_catalog_name_key ON service_catalog (lower(name));
+
+DROP INDEX IF EXISTS subscriptions_user_service_period_idx;
+CREATE INDEX subscriptions_user_service_period_idx ON subscriptions (user_id, lower(trim(service_name)), start_date, end_date);
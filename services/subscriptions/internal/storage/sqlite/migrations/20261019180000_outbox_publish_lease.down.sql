@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS outbox_published_idx;
+ALTER TABLE outbox DROP COLUMN publish_lease_until;
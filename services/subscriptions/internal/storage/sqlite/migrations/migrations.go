@@ -0,0 +1,8 @@
+package migrations
+
+import "embed"
+
+// Отдельный набор миграций для SQLite: типы и ограничения отличаются от Postgres
+
+//go:embed *.sql
+var FS embed.FS
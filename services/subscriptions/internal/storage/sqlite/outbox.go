@@ -0,0 +1,124 @@
+package sqlite
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+	"subscriptions/internal/models"
+	"time"
+)
+
+const (
+	// Как и у доставок, выбор и аренда — два запроса в одной транзакции. Транзакции SQLite и так идут по очереди
+	unpublishedEvents = `SELECT id, event_type, user_id, payload, created_at FROM outbox
+		WHERE published_at IS NULL AND (publish_lease_until IS NULL OR publish_lease_until <= ?) ORDER BY id LIMIT ?`
+	leaseEvents   = "UPDATE outbox SET publish_lease_until = ? WHERE id IN (%s)"
+	releaseEvents = "UPDATE outbox SET publish_lease_until = NULL WHERE published_at IS NULL AND id IN (%s)"
+	markPublished = "UPDATE outbox SET published_at = ?, publish_lease_until = NULL WHERE id IN (%s)"
+
+	// Вместе с событием каскадом удаляется журнал его доставок, поэтому события с недоставленными вебхуками остаются
+	deleteProcessedEvents = `DELETE FROM outbox WHERE id IN (
+			SELECT o.id FROM outbox o WHERE o.published_at < ?1 AND o.dispatched_at < ?1
+				AND NOT EXISTS (SELECT 1 FROM webhook_deliveries d WHERE d.event_id = o.id AND d.status = 'pending')
+			ORDER BY o.id LIMIT ?2)`
+)
+
+// Массивов в SQLite нет, id перечисляются в IN после остальных аргументов
+
+func withIds(query string, args []any, ids []int) (string, []any) {
+	for _, id := range ids {
+		args = append(args, id)
+	}
+	return fmt.Sprintf(query, strings.TrimSuffix(strings.Repeat("?, ", len(ids)), ", ")), args
+}
+
+func (s *Storage) ClaimEventsRequest(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]models.Event, error) {
+	var events []models.Event
+
+	err := s.inTx(ctx, func(tx *Storage) error {
+		rows, err := tx.query(ctx, "unpublished_events", unpublishedEvents, now.UTC().Format(dateLayout), limit)
+		if err != nil {
+			return err
+		}
+		for rows.Next() {
+			var e models.Event
+			var payload string
+			if err := rows.Scan(&e.Id, &e.Type, &e.UserId, &payload, &e.CreatedAt); err != nil {
+				rows.Close()
+				return err
+			}
+			if err := json.Unmarshal([]byte(payload), &e.Data); err != nil {
+				rows.Close()
+				return fmt.Errorf("invalid payload of event %d: %w", e.Id, err)
+			}
+			events = append(events, e)
+		}
+		if err := rows.Close(); err != nil {
+			return err
+		}
+
+		if len(events) == 0 {
+			return nil
+		}
+
+		ids := make([]int, len(events))
+		for i, e := range events {
+			ids[i] = e.Id
+		}
+		query, args := withIds(leaseEvents, []any{now.Add(lease).UTC().Format(dateLayout)}, ids)
+		_, err = tx.exec(ctx, "lease_events", query, args...)
+		return err
+	})
+
+	if err != nil {
+		slog.ErrorContext(ctx, "ClaimEventsRequest: error during claim of outbox events", "error", err)
+		return nil, err
+	}
+
+	return events, nil
+}
+
+func (s *Storage) ReleaseEventsRequest(ctx context.Context, ids []int) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	query, args := withIds(releaseEvents, nil, ids)
+
+	_, err := s.exec(ctx, "release_events", query, args...)
+	if err != nil {
+		slog.ErrorContext(ctx, "ReleaseEventsRequest: error during update of outbox events", "error", err)
+		return err
+	}
+
+	return nil
+}
+
+func (s *Storage) MarkPublishedRequest(ctx context.Context, ids []int) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	query, args := withIds(markPublished, []any{time.Now().UTC().Format(dateLayout)}, ids)
+
+	_, err := s.exec(ctx, "mark_published", query, args...)
+	if err != nil {
+		slog.ErrorContext(ctx, "MarkPublishedRequest: error during update of outbox events", "error", err)
+		return err
+	}
+
+	return nil
+}
+
+func (s *Storage) DeleteProcessedEventsRequest(ctx context.Context, before time.Time, limit int) (int, error) {
+	res, err := s.exec(ctx, "delete_processed_events", deleteProcessedEvents, before.UTC().Format(dateLayout), limit)
+	if err != nil {
+		slog.ErrorContext(ctx, "DeleteProcessedEventsRequest: error during delete of outbox events", "error", err)
+		return 0, err
+	}
+
+	n, err := res.RowsAffected()
+	return int(n), err
+}
@@ -0,0 +1,91 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"subscriptions/internal/models"
+	"subscriptions/internal/storage"
+	"time"
+)
+
+const (
+	// Из действующих в окне подписок берутся те, что в нём заканчиваются или продлеваются: день начала входит в ?3.
+	// Дни передаются JSON массивом
+	activeSubs = `SELECT id, service_name, price, user_id, start_date, end_date FROM subscriptions
+		WHERE start_date <= ?2 AND (end_date IS NULL OR end_date >= ?1)
+			AND (end_date <= ?2 OR CAST(strftime('%d', start_date) AS INTEGER) IN (SELECT value FROM json_each(?3))) ORDER BY id`
+	claimReminder   = "INSERT INTO reminders_sent (subscription_id, kind, due_date) VALUES (?, ?, ?) ON CONFLICT DO NOTHING"
+	releaseReminder = "DELETE FROM reminders_sent WHERE subscription_id = ? AND kind = ? AND due_date = ?"
+)
+
+// Подписки, у которых между from и to может быть продление или окончание. Точные даты считает планировщик
+
+func (s *Storage) ActiveSubsRequest(ctx context.Context, from time.Time, to time.Time) ([]models.ActiveSubscription, error) {
+	days, err := json.Marshal(storage.RenewalDays(from, to))
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := s.query(ctx, "active_subs", activeSubs, from.UTC().Format(dateLayout), to.UTC().Format(dateLayout), string(days))
+	if err != nil {
+		slog.ErrorContext(ctx, "ActiveSubsRequest: error during read of subscription records", "error", err)
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var subs []models.ActiveSubscription
+	for rows.Next() {
+		var sub models.ActiveSubscription
+		var startDate string
+		var endDate sql.NullString
+		if err := rows.Scan(&sub.Id, &sub.ServiceName, &sub.Price, &sub.UserId, &startDate, &endDate); err != nil {
+			slog.ErrorContext(ctx, "ActiveSubsRequest: error during rowscan", "error", err)
+			return nil, err
+		}
+
+		if sub.StartDate, err = time.Parse(dateLayout, startDate); err != nil {
+			return nil, fmt.Errorf("invalid start_date %q: %w", startDate, err)
+		}
+		if endDate.Valid {
+			end, err := time.Parse(dateLayout, endDate.String)
+			if err != nil {
+				return nil, fmt.Errorf("invalid end_date %q: %w", endDate.String, err)
+			}
+			sub.EndDate = &end
+		}
+		subs = append(subs, sub)
+	}
+
+	return subs, rows.Err()
+}
+
+// Отмечает напоминание отправленным. false — отметка уже есть
+
+func (s *Storage) ClaimReminderRequest(ctx context.Context, r models.Reminder) (bool, error) {
+	res, err := s.exec(ctx, "claim_reminder", claimReminder, r.SubscriptionId, r.Kind, r.Date)
+	if err != nil {
+		slog.ErrorContext(ctx, "ClaimReminderRequest: error during save of reminder", "error", err)
+		return false, err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return n == 1, nil
+}
+
+func (s *Storage) ReleaseReminderRequest(ctx context.Context, r models.Reminder) error {
+	_, err := s.exec(ctx, "release_reminder", releaseReminder, r.SubscriptionId, r.Kind, r.Date)
+	if err != nil {
+		slog.ErrorContext(ctx, "ReleaseReminderRequest: error during delete of reminder", "error", err)
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,442 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"subscriptions/internal/config"
+	"subscriptions/internal/metrics"
+	"subscriptions/internal/models"
+	"subscriptions/internal/storage"
+	"subscriptions/internal/storage/sqlite/migrations"
+	"subscriptions/internal/store"
+	"subscriptions/internal/tracing"
+	"time"
+
+	"github.com/golang-migrate/migrate/v4/database"
+	migratesqlite "github.com/golang-migrate/migrate/v4/database/sqlite"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	sqlitedrv "modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// Хранилище на SQLite для запуска без Postgres: один файл базы, драйвер на чистом Go
+
+const (
+	createSub        = "INSERT INTO subscriptions (service_name, price, user_id, start_date, end_date) VALUES (?, ?, ?, ?, ?) RETURNING id"
+	updateSub        = "UPDATE subscriptions SET service_name = ?, price = ?, user_id = ?, start_date = ?, end_date = ? WHERE id = ? RETURNING id, service_name, price, user_id, start_date, end_date"
+	deleteSub        = "DELETE FROM subscriptions WHERE id = ?"
+	readSubOwner     = "SELECT user_id FROM subscriptions WHERE id = ?"
+	readSub          = "SELECT id, service_name, price, user_id, start_date, end_date FROM subscriptions WHERE id = ?"
+	readSubs         = "SELECT id, service_name, price, user_id, start_date, end_date FROM subscriptions WHERE user_id = ? ORDER BY id"
+	showsubssum      = "SELECT id, service_name, price, user_id, start_date, end_date FROM subscriptions WHERE user_id = ?1 AND (?2 = '' OR lower(trim(service_name)) = lower(?2)) AND start_date >= ?3 AND end_date <= ?4 ORDER BY id"
+	showsubstotalsum = "SELECT COALESCE(SUM(price), 0) FROM subscriptions WHERE user_id = ?1 AND (?2 = '' OR lower(trim(service_name)) = lower(?2)) AND start_date >= ?3 AND end_date <= ?4"
+
+	// generate_series в SQLite нет, ряд месяцев строится рекурсивным CTE. Условие активности то же, что в Postgres
+	monthlySpending = `WITH RECURSIVE months(month) AS (
+			SELECT strftime('%Y-%m', ?2)
+			UNION ALL
+			SELECT strftime('%Y-%m', month || '-01', '+1 month') FROM months WHERE month < strftime('%Y-%m', ?3)
+		)
+		SELECT m.month, min(trim(s.service_name)), COALESCE(SUM(s.price), 0)
+		FROM months m
+		LEFT JOIN subscriptions s ON s.user_id = ?1
+			AND substr(s.start_date, 1, 7) <= m.month
+			AND (s.end_date IS NULL OR substr(s.end_date, 1, 7) >= m.month)
+		GROUP BY m.month, lower(trim(s.service_name))
+		ORDER BY m.month, lower(trim(s.service_name))`
+)
+
+// Формат хранения дат: фиксированная ширина и UTC, чтобы сравнение строк в SQL совпадало с хронологическим
+
+const dateLayout = "2006-01-02T15:04:05Z"
+
+type Storage struct {
+	*storage.Migrator
+	Db *sql.DB
+	q  storage.Querier // s.Db или открытая транзакция
+}
+
+func NewStorage(ctx context.Context, cfg config.DatabaseConfig) (*Storage, error) {
+	db, err := sql.Open("sqlite", DSN(cfg.URL))
+	if err != nil {
+		return nil, fmt.Errorf("error during database start: %w", err)
+	}
+
+	// SQLite допускает одного писателя, а база :memory: существует только внутри одного соединения
+	db.SetMaxOpenConns(1)
+	db.SetConnMaxIdleTime(0)
+
+	if err := storage.Connect(ctx, db, cfg); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &Storage{
+		Migrator: storage.NewMigrator(db, "sqlite", migrations.FS, cfg.MigrationsPath, migrationDriver),
+		Db:       db,
+		q:        db,
+	}, nil
+}
+
+// Переводит URL вида sqlite://path/to/file.db в DSN драйвера modernc.org/sqlite
+
+func DSN(url string) string {
+	path := strings.TrimPrefix(url, "sqlite://")
+
+	sep := "?"
+	if strings.Contains(path, "?") {
+		sep = "&"
+	}
+
+	return "file:" + path + sep + "_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)&_pragma=foreign_keys(1)"
+}
+
+// Close драйвера migrate для SQLite закрывает переданный *sql.DB, поэтому он подменяется пустым
+
+type sharedDriver struct {
+	database.Driver
+}
+
+func (sharedDriver) Close() error {
+	return nil
+}
+
+func migrationDriver(ctx context.Context, db *sql.DB) (database.Driver, error) {
+	driver, err := migratesqlite.WithInstance(db, &migratesqlite.Config{})
+	if err != nil {
+		return nil, err
+	}
+
+	return sharedDriver{driver}, nil
+}
+
+func (s *Storage) RegisterMetrics(m *metrics.Metrics) {
+	m.RegisterDB(s.Db, "sqlite")
+}
+
+func (s *Storage) Ping(ctx context.Context) error {
+	return s.Db.PingContext(ctx)
+}
+
+func (s *Storage) Close() error {
+	return s.Db.Close()
+}
+
+// SQLite сериализует транзакции сам, поэтому уровень изоляции не задаётся.
+// Все запросы внутри fn обязаны идти через tx: единственное соединение пула занято транзакцией
+
+func (s *Storage) WithTx(ctx context.Context, fn func(tx store.Storage) error) error {
+	if _, ok := s.q.(*sql.Tx); ok {
+		return fn(s)
+	}
+
+	return storage.RunTx(ctx, s.Db, nil, func(tx *sql.Tx) error {
+		return fn(&Storage{Migrator: s.Migrator, Db: s.Db, q: tx})
+	})
+}
+
+// Реплик у SQLite нет, чтение идёт в обычной транзакции
+
+func (s *Storage) WithReadTx(ctx context.Context, fn func(tx store.Storage) error) error {
+	return s.WithTx(ctx, fn)
+}
+
+func startQuery(ctx context.Context, operation string, query string) (context.Context, trace.Span) {
+	return tracing.Start(ctx, "db."+operation,
+		attribute.String("db.system", "sqlite"),
+		attribute.String("db.operation", operation),
+		attribute.String("db.statement", query),
+	)
+}
+
+func (s *Storage) exec(ctx context.Context, operation string, query string, args ...any) (sql.Result, error) {
+	ctx, span := startQuery(ctx, operation, query)
+	res, err := s.q.ExecContext(ctx, query, args...)
+	tracing.End(span, err)
+	return res, err
+}
+
+func (s *Storage) query(ctx context.Context, operation string, query string, args ...any) (*storage.Rows, error) {
+	ctx, span := startQuery(ctx, operation, query)
+	rows, err := s.q.QueryContext(ctx, query, args...)
+	if err != nil {
+		tracing.End(span, err)
+		return nil, err
+	}
+	return storage.NewRows(rows, span), nil
+}
+
+func (s *Storage) queryRow(ctx context.Context, operation string, query string, args []any, dest ...any) error {
+	ctx, span := startQuery(ctx, operation, query)
+	err := s.q.QueryRowContext(ctx, query, args...).Scan(dest...)
+	if err == sql.ErrNoRows {
+		tracing.End(span, nil)
+		return err
+	}
+	tracing.End(span, err)
+	return err
+}
+
+// Нарушение UNIQUE. SQLite не сообщает имя ограничения отдельно, поэтому key ищется в тексте ошибки:
+// это первый столбец ограничения в виде table.column или имя уникального индекса
+
+func isUniqueViolation(err error, key string) bool {
+	var e *sqlitedrv.Error
+	return errors.As(err, &e) && e.Code() == sqlite3.SQLITE_CONSTRAINT_UNIQUE && strings.Contains(e.Error(), key)
+}
+
+// В отличие от Postgres, SQLite не разбирает даты сам, поэтому они приводятся к dateLayout до запроса
+
+func formatDate(date string) (string, error) {
+	t, err := storage.ParseDate(date)
+	if err != nil {
+		return "", err
+	}
+	return t.Format(dateLayout), nil
+}
+
+func nullableDate(date string) (any, error) {
+	if date == "" {
+		return nil, nil
+	}
+	return formatDate(date)
+}
+
+func subArgs(sub models.Subscription) ([]any, error) {
+	start, err := formatDate(sub.StartDate)
+	if err != nil {
+		return nil, err
+	}
+
+	end, err := nullableDate(sub.EndDate)
+	if err != nil {
+		return nil, err
+	}
+
+	return []any{sub.ServiceName, sub.Price, sub.UserId, start, end}, nil
+}
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanSub(row scanner) (models.Subscription, error) {
+	var sub models.Subscription
+	var startDate string
+	var endDate sql.NullString
+
+	err := row.Scan(&sub.Id, &sub.ServiceName, &sub.Price, &sub.UserId, &startDate, &endDate)
+	if err != nil {
+		return sub, err
+	}
+
+	start, err := time.Parse(dateLayout, startDate)
+	if err != nil {
+		return sub, fmt.Errorf("invalid start_date %q: %w", startDate, err)
+	}
+
+	var end time.Time
+	if endDate.Valid {
+		end, err = time.Parse(dateLayout, endDate.String)
+		if err != nil {
+			return sub, fmt.Errorf("invalid end_date %q: %w", endDate.String, err)
+		}
+	}
+
+	sub.StartDate, sub.EndDate = storage.TimeForm(start, end)
+	if !endDate.Valid {
+		sub.EndDate = ""
+	}
+
+	return sub, nil
+}
+
+func (s *Storage) scanSubs(rows *storage.Rows) ([]models.Subscription, error) {
+	defer rows.Close()
+
+	var subs []models.Subscription
+	for rows.Next() {
+		sub, err := scanSub(rows)
+		if err != nil {
+			return nil, err
+		}
+		subs = append(subs, sub)
+	}
+
+	return subs, rows.Err()
+}
+
+func (s *Storage) CreateSubRequest(ctx context.Context, sub models.Subscription) (int, error) {
+	args, err := subArgs(sub)
+	if err != nil {
+		return 0, err
+	}
+
+	var id int
+
+	err = s.queryRow(ctx, "create_sub", createSub, args, &id)
+	if err != nil {
+		slog.ErrorContext(ctx, "CreateSubRequest: error during creation of subscription record", "error", err)
+		return 0, err
+	}
+
+	return id, nil
+}
+
+func (s *Storage) ReadSubRequest(ctx context.Context, id int) (*models.Subscription, error) {
+	ctx, span := startQuery(ctx, "read_sub", readSub)
+	sub, err := scanSub(s.q.QueryRowContext(ctx, readSub, id))
+
+	if err == sql.ErrNoRows {
+		tracing.End(span, nil)
+		slog.WarnContext(ctx, "ReadSubRequest: subscription record not found", "id", id)
+		return nil, store.ErrNotFound
+	}
+
+	tracing.End(span, err)
+	if err != nil {
+		slog.ErrorContext(ctx, "ReadSubRequest: error during read of subscription record", "error", err)
+		return nil, err
+	}
+
+	return &sub, nil
+}
+
+func (s *Storage) ReadSubsRequest(ctx context.Context, userId string) ([]models.Subscription, error) {
+	rows, err := s.query(ctx, "read_subs", readSubs, userId)
+	if err != nil {
+		slog.ErrorContext(ctx, "ReadSubsRequest: error during read of subscriptions records", "error", err)
+		return nil, err
+	}
+
+	subs, err := s.scanSubs(rows)
+	if err != nil {
+		slog.ErrorContext(ctx, "ReadSubsRequest: error during rowscan", "error", err)
+		return nil, err
+	}
+
+	return subs, nil
+}
+
+// RETURNING в SQLite не видит других таблиц запроса, поэтому прежний владелец читается отдельно в той же транзакции.
+// Запись в базу идёт по одной, и между чтением и UPDATE строку никто не изменит
+
+func (s *Storage) UpdateSubRequest(ctx context.Context, sub models.Subscription) (*models.Subscription, string, error) {
+	args, err := subArgs(sub)
+	if err != nil {
+		return nil, "", err
+	}
+
+	var updated models.Subscription
+	var prevUserId string
+
+	err = s.inTx(ctx, func(tx *Storage) error {
+		if err := tx.queryRow(ctx, "read_sub_owner", readSubOwner, []any{sub.Id}, &prevUserId); err != nil {
+			return err
+		}
+
+		ctx, span := startQuery(ctx, "update_sub", updateSub)
+		updated, err = scanSub(tx.q.QueryRowContext(ctx, updateSub, append(args, sub.Id)...))
+		tracing.End(span, err)
+		return err
+	})
+
+	if err == sql.ErrNoRows {
+		slog.WarnContext(ctx, "UpdateSubRequest: subscription record not found", "id", sub.Id)
+		return nil, "", store.ErrNotFound
+	}
+
+	if err != nil {
+		slog.ErrorContext(ctx, "UpdateSubRequest: error during update of subscription record", "error", err)
+		return nil, "", err
+	}
+
+	return &updated, prevUserId, nil
+}
+
+func (s *Storage) DeleteSubRequest(ctx context.Context, id int) error {
+	res, err := s.exec(ctx, "delete_sub", deleteSub, id)
+	if err != nil {
+		slog.ErrorContext(ctx, "DeleteSubRequest: error during delete of subscription record", "error", err)
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return store.ErrNotFound
+	}
+
+	return nil
+}
+
+// Пустой serviceName означает все сервисы пользователя, имя сравнивается без учёта регистра и пробелов по краям
+
+func (s *Storage) ShowSubscSumRequest(ctx context.Context, serviceName string, userId string, startPeriod string, endPeriod string) (*models.SubscriptionSum, error) {
+	from, err := formatDate(startPeriod)
+	if err != nil {
+		return nil, err
+	}
+
+	to, err := formatDate(endPeriod)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := s.query(ctx, "show_subs_sum", showsubssum, userId, serviceName, from, to)
+	if err != nil {
+		slog.ErrorContext(ctx, "ShowSubscSumRequest: error during read of subscriptions records", "error", err)
+		return nil, err
+	}
+
+	subs, err := s.scanSubs(rows)
+	if err != nil {
+		slog.ErrorContext(ctx, "ShowSubscSumRequest: error during rowscan", "error", err)
+		return nil, err
+	}
+
+	var total int
+
+	err = s.queryRow(ctx, "show_subs_total_sum", showsubstotalsum, []any{userId, serviceName, from, to}, &total)
+	if err != nil {
+		slog.ErrorContext(ctx, "ShowSubscSumRequest: error during read of sum", "error", err)
+		return nil, err
+	}
+
+	return &models.SubscriptionSum{Items: subs, Total: total}, nil
+}
+
+// Помесячные расходы пользователя за период от startPeriod до endPeriod включительно
+
+func (s *Storage) MonthlySpendingRequest(ctx context.Context, userId string, startPeriod string, endPeriod string) ([]models.MonthlySpending, error) {
+	from, err := formatDate(startPeriod)
+	if err != nil {
+		return nil, err
+	}
+
+	to, err := formatDate(endPeriod)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := s.query(ctx, "monthly_spending", monthlySpending, userId, from, to)
+	if err != nil {
+		slog.ErrorContext(ctx, "MonthlySpendingRequest: error during read of monthly spending", "error", err)
+		return nil, err
+	}
+
+	months, err := storage.ScanMonthly(rows)
+	if err != nil {
+		slog.ErrorContext(ctx, "MonthlySpendingRequest: error during rowscan", "error", err)
+		return nil, err
+	}
+
+	return months, nil
+}
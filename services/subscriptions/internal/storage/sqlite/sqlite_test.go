@@ -0,0 +1,469 @@
+package sqlite_test
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"slices"
+	"subscriptions/internal/config"
+	"subscriptions/internal/models"
+	"subscriptions/internal/storage/sqlite"
+	"subscriptions/internal/store"
+	"testing"
+	"time"
+)
+
+const userId = "60601fee-2bf1-4721-ae6f-7636e79a0cba"
+
+func newStorage(t *testing.T) *sqlite.Storage {
+	t.Helper()
+
+	cfg := config.Default().Database
+	cfg.URL = "sqlite://" + filepath.Join(t.TempDir(), "subs.db")
+
+	st, err := sqlite.NewStorage(context.Background(), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { st.Close() })
+
+	if err := st.MigrateUp(0); err != nil {
+		t.Fatal(err)
+	}
+
+	return st
+}
+
+func TestStorage(t *testing.T) {
+	ctx := context.Background()
+	st := newStorage(t)
+
+	if err := st.CheckMigrations(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	subs := []models.Subscription{
+		{ServiceName: "Netflix", Price: 400, UserId: userId, StartDate: "2025-01-01", EndDate: "2025-03-01"},
+		{ServiceName: "Netflix", Price: 500, UserId: userId, StartDate: "04-2025", EndDate: "2025-06-01T00:00:00Z"},
+		{ServiceName: "Netflix", Price: 700, UserId: userId, StartDate: "2025-06-01"},
+		{ServiceName: "Netflix", Price: 900, UserId: userId, StartDate: "2024-12-01", EndDate: "2025-02-01"},
+		{ServiceName: "Spotify", Price: 200, UserId: userId, StartDate: "2025-01-01", EndDate: "2025-02-01"},
+	}
+
+	for _, sub := range subs {
+		if _, err := st.CreateSubRequest(ctx, sub); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got, err := st.ReadSubRequest(ctx, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.StartDate != "04-2025" || got.EndDate != "06-2025" {
+		t.Fatalf("read: unexpected dates %+v", got)
+	}
+
+	got, err = st.ReadSubRequest(ctx, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.EndDate != "" {
+		t.Fatalf("read: end_date = %q, want empty", got.EndDate)
+	}
+
+	sum, err := st.ShowSubscSumRequest(ctx, "Netflix", userId, "2025-01-01", "2025-12-31")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sum.Items) != 2 || sum.Total != 900 {
+		t.Fatalf("sum: unexpected response %+v", sum)
+	}
+
+	// Без сервиса суммируются все сервисы пользователя
+	sum, err = st.ShowSubscSumRequest(ctx, "", userId, "2025-01-01", "2025-12-31")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sum.Items) != 3 || sum.Total != 1100 {
+		t.Fatalf("sum of all services: unexpected response %+v", sum)
+	}
+
+	if err := st.DeleteSubRequest(ctx, 1); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := st.ReadSubRequest(ctx, 1); err == nil {
+		t.Fatal("read after delete: expected error")
+	}
+	if err := st.DeleteSubRequest(ctx, 1); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("delete twice: err = %v, want ErrNotFound", err)
+	}
+	if _, _, err := st.UpdateSubRequest(ctx, models.Subscription{Id: 1, ServiceName: "Netflix", Price: 100, UserId: userId, StartDate: "2025-05-01"}); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("update after delete: err = %v, want ErrNotFound", err)
+	}
+
+	const newOwner = "0f6a6a54-5c1c-4b38-9a4e-0c1a2b3c4d5e"
+	updated, prevUserId, err := st.UpdateSubRequest(ctx, models.Subscription{Id: 3, ServiceName: "Spotify", Price: 250, UserId: newOwner, StartDate: "2025-05-01"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated.Id != 3 || updated.Price != 250 || updated.UserId != newOwner || updated.StartDate != "05-2025" || updated.EndDate != "" {
+		t.Fatalf("update: unexpected subscription %+v", updated)
+	}
+	if prevUserId != userId {
+		t.Fatalf("update: previous owner %q, want %q", prevUserId, userId)
+	}
+
+	bad := models.Subscription{ServiceName: "Netflix", Price: 100, UserId: userId, StartDate: "2025-05-01", EndDate: "2025-04-01"}
+	if _, err := st.CreateSubRequest(ctx, bad); err == nil {
+		t.Fatal("create: expected constraint error for end_date before start_date")
+	}
+}
+
+func TestWithTxRollback(t *testing.T) {
+	ctx := context.Background()
+	st := newStorage(t)
+
+	sub := models.Subscription{ServiceName: "Netflix", Price: 400, UserId: userId, StartDate: "2025-01-01", EndDate: "2025-03-01"}
+	errRollback := errors.New("rollback")
+
+	err := st.WithTx(ctx, func(tx store.Storage) error {
+		if _, err := tx.CreateSubRequest(ctx, sub); err != nil {
+			return err
+		}
+		return errRollback
+	})
+	if !errors.Is(err, errRollback) {
+		t.Fatalf("err = %v, want %v", err, errRollback)
+	}
+
+	subs, err := st.ReadSubsRequest(ctx, userId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(subs) != 0 {
+		t.Fatalf("got %d subscriptions after rollback, want 0", len(subs))
+	}
+}
+
+func TestMonthlySpending(t *testing.T) {
+	ctx := context.Background()
+	st := newStorage(t)
+
+	subs := []models.Subscription{
+		{ServiceName: "Netflix", Price: 400, UserId: userId, StartDate: "2025-01-15", EndDate: "2025-03-10"},
+		{ServiceName: "Spotify", Price: 200, UserId: userId, StartDate: "2025-02-01"},
+		{ServiceName: "Netflix", Price: 300, UserId: "0f6a6a54-5c1c-4b38-9a4e-0c1a2b3c4d5e", StartDate: "2025-01-01"},
+	}
+
+	for _, sub := range subs {
+		if _, err := st.CreateSubRequest(ctx, sub); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	months, err := st.MonthlySpendingRequest(ctx, userId, "2024-12-01", "2025-04-30")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]int{"2024-12": 0, "2025-01": 400, "2025-02": 600, "2025-03": 600, "2025-04": 200}
+	if len(months) != len(want) {
+		t.Fatalf("got %d months, want %d: %+v", len(months), len(want), months)
+	}
+	for _, m := range months {
+		if total, ok := want[m.Month]; !ok || m.Total != total {
+			t.Fatalf("month %s: total %d, want %d", m.Month, m.Total, total)
+		}
+	}
+	if feb := months[2]; len(feb.Services) != 2 || feb.Services[0].ServiceName != "Netflix" || feb.Services[1].Total != 200 {
+		t.Fatalf("2025-02: unexpected breakdown %+v", feb.Services)
+	}
+}
+
+func TestBudgets(t *testing.T) {
+	ctx := context.Background()
+	st := newStorage(t)
+
+	id, err := st.CreateBudgetRequest(ctx, models.Budget{UserId: userId, ServiceName: "Netflix", MonthlyLimit: 300})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := st.CreateBudgetRequest(ctx, models.Budget{UserId: userId, ServiceName: "Netflix", MonthlyLimit: 500}); !errors.Is(err, store.ErrBudgetExists) {
+		t.Fatalf("duplicate budget: err = %v, want ErrBudgetExists", err)
+	}
+
+	alert := models.BudgetAlert{BudgetId: id, UserId: userId, ServiceName: "Netflix", Month: "2025-05", MonthlyLimit: 300, Spent: 400}
+	if err := st.SaveAlertRequest(ctx, alert); err != nil {
+		t.Fatal(err)
+	}
+	alert.Spent = 700
+	if err := st.SaveAlertRequest(ctx, alert); err != nil {
+		t.Fatal(err)
+	}
+
+	alerts, err := st.ReadAlertsRequest(ctx, userId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(alerts) != 1 || alerts[0].Spent != 700 || alerts[0].Month != "2025-05" || alerts[0].CreatedAt == "" {
+		t.Fatalf("unexpected alerts %+v", alerts)
+	}
+
+	if err := st.DeleteAlertRequest(ctx, id, "2025-05"); err != nil {
+		t.Fatal(err)
+	}
+	if alerts, _ := st.ReadAlertsRequest(ctx, userId); len(alerts) != 0 {
+		t.Fatalf("alert must be deleted, got %+v", alerts)
+	}
+	if err := st.SaveAlertRequest(ctx, alert); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := st.DeleteBudgetRequest(ctx, id); err != nil {
+		t.Fatal(err)
+	}
+	if alerts, _ := st.ReadAlertsRequest(ctx, userId); len(alerts) != 0 {
+		t.Fatalf("alerts must be deleted with budget, got %+v", alerts)
+	}
+	if _, err := st.ReadBudgetRequest(ctx, id); !errors.Is(err, store.ErrBudgetNotFound) {
+		t.Fatalf("read deleted budget: err = %v, want ErrBudgetNotFound", err)
+	}
+}
+
+func TestReminders(t *testing.T) {
+	ctx := context.Background()
+	st := newStorage(t)
+
+	id, err := st.CreateSubRequest(ctx, models.Subscription{ServiceName: "Netflix", Price: 400, UserId: userId, StartDate: "2025-01-15", EndDate: "2025-06-01"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	subs, err := st.ActiveSubsRequest(ctx, time.Date(2025, 5, 30, 0, 0, 0, 0, time.UTC), time.Date(2025, 6, 2, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(subs) != 1 || subs[0].StartDate.Day() != 15 || subs[0].EndDate == nil || subs[0].EndDate.Month() != time.June {
+		t.Fatalf("unexpected active subscriptions %+v", subs)
+	}
+
+	r := models.Reminder{Kind: "ending", SubscriptionId: id, Date: "2025-06-01"}
+	for i, want := range []bool{true, false} {
+		claimed, err := st.ClaimReminderRequest(ctx, r)
+		if err != nil || claimed != want {
+			t.Fatalf("claim %d: claimed %v, error %v", i, claimed, err)
+		}
+	}
+	if err := st.ReleaseReminderRequest(ctx, r); err != nil {
+		t.Fatal(err)
+	}
+	if claimed, err := st.ClaimReminderRequest(ctx, r); err != nil || !claimed {
+		t.Fatalf("claim after release: claimed %v, error %v", claimed, err)
+	}
+
+	subs, err = st.ActiveSubsRequest(ctx, time.Date(2025, 6, 2, 0, 0, 0, 0, time.UTC), time.Date(2025, 6, 5, 0, 0, 0, 0, time.UTC))
+	if err != nil || len(subs) != 0 {
+		t.Fatalf("ended subscription must not be active: %+v, error %v", subs, err)
+	}
+
+	// Без окончания подписка попадает в выборку, только если в окне есть день её продления.
+	// Подписка с 31 числа продлевается в последний день короткого месяца
+	for _, start := range []string{"2025-01-10", "2025-01-31"} {
+		if _, err := st.CreateSubRequest(ctx, models.Subscription{ServiceName: "Spotify", Price: 200, UserId: userId, StartDate: start}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, tc := range []struct {
+		from, to time.Time
+		want     int
+	}{
+		{time.Date(2025, 7, 1, 0, 0, 0, 0, time.UTC), time.Date(2025, 7, 4, 0, 0, 0, 0, time.UTC), 0},
+		{time.Date(2025, 7, 8, 0, 0, 0, 0, time.UTC), time.Date(2025, 7, 11, 0, 0, 0, 0, time.UTC), 1},
+		{time.Date(2025, 2, 27, 0, 0, 0, 0, time.UTC), time.Date(2025, 3, 2, 0, 0, 0, 0, time.UTC), 1},
+	} {
+		subs, err := st.ActiveSubsRequest(ctx, tc.from, tc.to)
+		if err != nil || len(subs) != tc.want {
+			t.Fatalf("window %s - %s: %+v, error %v", tc.from.Format(time.DateOnly), tc.to.Format(time.DateOnly), subs, err)
+		}
+	}
+}
+
+func TestOutboxDeliveries(t *testing.T) {
+	ctx := context.Background()
+	st := newStorage(t)
+
+	whId, err := st.CreateWebhookRequest(ctx, models.Webhook{UserId: userId, URL: "https://example.com/hook", Secret: "secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sub := models.Subscription{Id: 1, ServiceName: "Netflix", Price: 400, UserId: userId, StartDate: "07-2025"}
+	if err := st.AddEventRequest(ctx, models.Event{Type: "subscription.created", UserId: userId, Data: sub}); err != nil {
+		t.Fatal(err)
+	}
+
+	for i, want := range []int{1, 0} {
+		if n, err := st.DispatchEventsRequest(ctx, 10); err != nil || n != want {
+			t.Fatalf("dispatch %d: %d events, error %v", i, n, err)
+		}
+	}
+
+	now := time.Now().Add(time.Second)
+	claimed, err := st.ClaimDeliveriesRequest(ctx, now, time.Minute, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(claimed) != 1 || claimed[0].URL != "https://example.com/hook" || claimed[0].Event.Data != sub {
+		t.Fatalf("unexpected claimed deliveries %+v", claimed)
+	}
+
+	// Взятая доставка отложена на время аренды
+	if again, err := st.ClaimDeliveriesRequest(ctx, now, time.Minute, 10); err != nil || len(again) != 0 {
+		t.Fatalf("leased delivery claimed again: %+v, error %v", again, err)
+	}
+
+	attempt := models.DeliveryAttempt{Status: "delivered", StatusCode: 200, At: now, NextAttemptAt: now}
+	if err := st.SaveAttemptRequest(ctx, claimed[0].Id, attempt); err != nil {
+		t.Fatal(err)
+	}
+
+	deliveries, err := st.ReadDeliveriesRequest(ctx, whId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(deliveries) != 1 || deliveries[0].Status != "delivered" || deliveries[0].Attempts != 1 || deliveries[0].LastStatusCode != 200 || deliveries[0].DeliveredAt == "" {
+		t.Fatalf("unexpected deliveries %+v", deliveries)
+	}
+}
+
+func TestOutboxPublished(t *testing.T) {
+	ctx := context.Background()
+	st := newStorage(t)
+
+	for _, typ := range []string{"subscription.created", "subscription.updated", "subscription.deleted"} {
+		sub := models.Subscription{Id: 1, ServiceName: "Netflix", Price: 400, UserId: userId, StartDate: "07-2025"}
+		if err := st.AddEventRequest(ctx, models.Event{Type: typ, UserId: userId, Data: sub}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	now := time.Now()
+
+	events, err := st.ClaimEventsRequest(ctx, now, time.Minute, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 2 || events[0].Type != "subscription.created" || events[1].Type != "subscription.updated" || events[0].Data.ServiceName != "Netflix" {
+		t.Fatalf("unexpected events %+v", events)
+	}
+
+	// Взятые события другой проход не получит, пока не истечёт аренда или её не снимут
+	again, err := st.ClaimEventsRequest(ctx, now, time.Minute, 10)
+	if err != nil || len(again) != 1 || again[0].Type != "subscription.deleted" {
+		t.Fatalf("unexpected events during lease %+v, error %v", again, err)
+	}
+	if err := st.ReleaseEventsRequest(ctx, []int{events[1].Id}); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.MarkPublishedRequest(ctx, []int{events[0].Id}); err != nil {
+		t.Fatal(err)
+	}
+
+	again, err = st.ClaimEventsRequest(ctx, now.Add(2*time.Minute), time.Minute, 10)
+	if err != nil || len(again) != 2 || again[0].Type != "subscription.updated" || again[1].Type != "subscription.deleted" {
+		t.Fatalf("unexpected events after publishing %+v, error %v", again, err)
+	}
+
+	// Удаляется только событие, которое и опубликовано, и разослано на вебхуки
+	if _, err := st.DispatchEventsRequest(ctx, 10); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := st.DeleteProcessedEventsRequest(ctx, now.Add(time.Hour), 10); err != nil || n != 1 {
+		t.Fatalf("deleted %d events, error %v", n, err)
+	}
+	if n, err := st.DeleteProcessedEventsRequest(ctx, now.Add(time.Hour), 10); err != nil || n != 0 {
+		t.Fatalf("deleted %d events again, error %v", n, err)
+	}
+}
+
+func TestCatalog(t *testing.T) {
+	ctx := context.Background()
+	st := newStorage(t)
+
+	netflix, err := st.CreateCatalogEntryRequest(ctx, models.CatalogEntry{Name: "Netflix", Aliases: []string{"NFLX", "Kino"}, DefaultPrice: 400})
+	if err != nil {
+		t.Fatal(err)
+	}
+	kino, err := st.CreateCatalogEntryRequest(ctx, models.CatalogEntry{Name: "Kino"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := st.CreateCatalogEntryRequest(ctx, models.CatalogEntry{Name: "netflix"}); !errors.Is(err, store.ErrCatalogConflict) {
+		t.Fatalf("duplicate name in another case: error %v", err)
+	}
+	if err := st.UpdateCatalogEntryRequest(ctx, models.CatalogEntry{Id: kino, Name: "NETFLIX"}); !errors.Is(err, store.ErrCatalogConflict) {
+		t.Fatalf("rename to existing name: error %v", err)
+	}
+
+	// Псевдоним ищется без учёта регистра, а каноническое имя важнее псевдонима другой записи
+	for name, want := range map[string]int{"nflx": netflix, "KINO": kino} {
+		e, err := st.FindCatalogEntryRequest(ctx, name)
+		if err != nil || e.Id != want {
+			t.Fatalf("find %q: %+v, error %v, want id %d", name, e, err, want)
+		}
+	}
+	if _, err := st.FindCatalogEntryRequest(ctx, "Spotify"); !errors.Is(err, store.ErrCatalogEntryNotFound) {
+		t.Fatalf("find unknown name: error %v", err)
+	}
+
+	// Сумма по сервису не зависит от регистра и пробелов по краям в записанных раньше именах
+	for _, name := range []string{"Netflix", "netflix ", "Spotify"} {
+		sub := models.Subscription{ServiceName: name, Price: 100, UserId: userId, StartDate: "2025-07-01", EndDate: "2025-08-01"}
+		if _, err := st.CreateSubRequest(ctx, sub); err != nil {
+			t.Fatal(err)
+		}
+	}
+	sum, err := st.ShowSubscSumRequest(ctx, "NETFLIX", userId, "2025-01-01", "2025-12-31")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sum.Items) != 2 || sum.Total != 200 {
+		t.Fatalf("unexpected sum %+v", sum)
+	}
+
+	// В помесячных расходах такие имена тоже одна группа
+	months, err := st.MonthlySpendingRequest(ctx, userId, "2025-07-01", "2025-07-31")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if services := months[0].Services; len(services) != 2 || services[0] != (models.ServiceSpending{ServiceName: "Netflix", Total: 200}) {
+		t.Fatalf("unexpected monthly breakdown %+v", services)
+	}
+
+	// Переименование по ключам затрагивает подписки и единственный у пользователя бюджет на эти имена
+	if _, err := st.CreateSubRequest(ctx, models.Subscription{ServiceName: "nflx", Price: 100, UserId: userId, StartDate: "2025-07-01"}); err != nil {
+		t.Fatal(err)
+	}
+	budget, err := st.CreateBudgetRequest(ctx, models.Budget{UserId: userId, ServiceName: "NFLX", MonthlyLimit: 300})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := st.RenameServiceRequest(ctx, []string{"netflix", "nflx", "kino"}, "Netflix"); err != nil {
+		t.Fatal(err)
+	}
+	subs, err := st.ReadSubsRequest(ctx, userId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, sub := range subs {
+		names = append(names, sub.ServiceName)
+	}
+	if want := []string{"Netflix", "Netflix", "Spotify", "Netflix"}; !slices.Equal(names, want) {
+		t.Fatalf("names after rename = %v, want %v", names, want)
+	}
+	if b, err := st.ReadBudgetRequest(ctx, budget); err != nil || b.ServiceName != "Netflix" {
+		t.Fatalf("budget after rename: %+v, error %v", b, err)
+	}
+}
@@ -0,0 +1,249 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"subscriptions/internal/models"
+	"subscriptions/internal/store"
+	"time"
+)
+
+const (
+	addEvent       = "INSERT INTO outbox (event_type, user_id, payload) VALUES (?, ?, ?)"
+	createWebhook  = "INSERT INTO webhooks (user_id, url, secret) VALUES (?, ?, ?) RETURNING id"
+	readWebhook    = "SELECT id, user_id, url, secret, created_at FROM webhooks WHERE id = ?"
+	readWebhooks   = "SELECT id, user_id, url, secret, created_at FROM webhooks WHERE user_id = ? ORDER BY id"
+	deleteWebhook  = "DELETE FROM webhooks WHERE id = ?"
+	readDeliveries = `SELECT d.id, d.webhook_id, d.event_id, e.event_type, d.status, d.attempts, COALESCE(d.last_status_code, 0), COALESCE(d.last_error, ''),
+			d.next_attempt_at, COALESCE(d.delivered_at, ''), d.created_at
+		FROM webhook_deliveries d JOIN outbox e ON e.id = d.event_id
+		WHERE d.webhook_id = ? ORDER BY d.id DESC LIMIT 100`
+
+	// UPDATE внутри WITH SQLite не поддерживает, поэтому рассылка и взятие доставок — несколько запросов в одной транзакции
+	undispatchedEvents = "SELECT id, user_id FROM outbox WHERE dispatched_at IS NULL ORDER BY id LIMIT ?"
+	createDeliveries   = "INSERT INTO webhook_deliveries (webhook_id, event_id) SELECT id, ? FROM webhooks WHERE user_id = ? ON CONFLICT DO NOTHING"
+	markDispatched     = "UPDATE outbox SET dispatched_at = ? WHERE id = ?"
+	dueDeliveries      = `SELECT d.id, d.attempts, w.url, w.secret, e.id, e.event_type, e.user_id, e.payload, e.created_at
+		FROM webhook_deliveries d JOIN webhooks w ON w.id = d.webhook_id JOIN outbox e ON e.id = d.event_id
+		WHERE d.status = 'pending' AND d.next_attempt_at <= ? ORDER BY d.next_attempt_at, d.id LIMIT ?`
+	leaseDelivery = "UPDATE webhook_deliveries SET next_attempt_at = ? WHERE id = ?"
+	saveAttempt   = `UPDATE webhook_deliveries SET status = ?2, attempts = attempts + 1, last_status_code = NULLIF(?3, 0), last_error = NULLIF(?4, ''),
+		next_attempt_at = ?5, delivered_at = CASE WHEN ?2 = 'delivered' THEN ?6 END WHERE id = ?1`
+)
+
+// Выполняет fn в транзакции хранилища, открывает её, если ещё не открыта
+
+func (s *Storage) inTx(ctx context.Context, fn func(tx *Storage) error) error {
+	return s.WithTx(ctx, func(tx store.Storage) error {
+		return fn(tx.(*Storage))
+	})
+}
+
+func (s *Storage) AddEventRequest(ctx context.Context, e models.Event) error {
+	payload, err := json.Marshal(e.Data)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.exec(ctx, "add_event", addEvent, e.Type, e.UserId, string(payload))
+	if err != nil {
+		slog.ErrorContext(ctx, "AddEventRequest: error during insert into outbox", "error", err)
+		return err
+	}
+
+	return nil
+}
+
+func (s *Storage) CreateWebhookRequest(ctx context.Context, wh models.Webhook) (int, error) {
+	var id int
+
+	err := s.queryRow(ctx, "create_webhook", createWebhook, []any{wh.UserId, wh.URL, wh.Secret}, &id)
+	if err != nil {
+		slog.ErrorContext(ctx, "CreateWebhookRequest: error during creation of webhook record", "error", err)
+		return 0, err
+	}
+
+	return id, nil
+}
+
+func (s *Storage) ReadWebhookRequest(ctx context.Context, id int) (*models.Webhook, error) {
+	var wh models.Webhook
+
+	err := s.queryRow(ctx, "read_webhook", readWebhook, []any{id}, &wh.Id, &wh.UserId, &wh.URL, &wh.Secret, &wh.CreatedAt)
+
+	if err == sql.ErrNoRows {
+		slog.WarnContext(ctx, "ReadWebhookRequest: webhook record not found", "id", id)
+		return nil, store.ErrWebhookNotFound
+	}
+
+	if err != nil {
+		slog.ErrorContext(ctx, "ReadWebhookRequest: error during read of webhook record", "error", err)
+		return nil, err
+	}
+
+	return &wh, nil
+}
+
+func (s *Storage) ReadWebhooksRequest(ctx context.Context, userId string) ([]models.Webhook, error) {
+	rows, err := s.query(ctx, "read_webhooks", readWebhooks, userId)
+	if err != nil {
+		slog.ErrorContext(ctx, "ReadWebhooksRequest: error during read of webhook records", "error", err)
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var webhooks []models.Webhook
+	for rows.Next() {
+		var wh models.Webhook
+		if err := rows.Scan(&wh.Id, &wh.UserId, &wh.URL, &wh.Secret, &wh.CreatedAt); err != nil {
+			slog.ErrorContext(ctx, "ReadWebhooksRequest: error during rowscan", "error", err)
+			return nil, err
+		}
+		webhooks = append(webhooks, wh)
+	}
+
+	return webhooks, rows.Err()
+}
+
+// Доставки удаляются каскадно
+
+func (s *Storage) DeleteWebhookRequest(ctx context.Context, id int) error {
+	_, err := s.exec(ctx, "delete_webhook", deleteWebhook, id)
+	if err != nil {
+		slog.ErrorContext(ctx, "DeleteWebhookRequest: error during delete of webhook record", "error", err)
+		return err
+	}
+
+	return nil
+}
+
+func (s *Storage) ReadDeliveriesRequest(ctx context.Context, webhookId int) ([]models.WebhookDelivery, error) {
+	rows, err := s.query(ctx, "read_deliveries", readDeliveries, webhookId)
+	if err != nil {
+		slog.ErrorContext(ctx, "ReadDeliveriesRequest: error during read of webhook deliveries", "error", err)
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var deliveries []models.WebhookDelivery
+	for rows.Next() {
+		var d models.WebhookDelivery
+		var nextAttemptAt string
+		err := rows.Scan(&d.Id, &d.WebhookId, &d.EventId, &d.EventType, &d.Status, &d.Attempts, &d.LastStatusCode, &d.LastError,
+			&nextAttemptAt, &d.DeliveredAt, &d.CreatedAt)
+		if err != nil {
+			slog.ErrorContext(ctx, "ReadDeliveriesRequest: error during rowscan", "error", err)
+			return nil, err
+		}
+		if d.Status == "pending" {
+			d.NextAttemptAt = nextAttemptAt
+		}
+		deliveries = append(deliveries, d)
+	}
+
+	return deliveries, rows.Err()
+}
+
+type outboxEvent struct {
+	id     int
+	userId string
+}
+
+func (s *Storage) DispatchEventsRequest(ctx context.Context, limit int) (int, error) {
+	var events []outboxEvent
+
+	err := s.inTx(ctx, func(tx *Storage) error {
+		rows, err := tx.query(ctx, "undispatched_events", undispatchedEvents, limit)
+		if err != nil {
+			return err
+		}
+		for rows.Next() {
+			var e outboxEvent
+			if err := rows.Scan(&e.id, &e.userId); err != nil {
+				rows.Close()
+				return err
+			}
+			events = append(events, e)
+		}
+		if err := rows.Close(); err != nil {
+			return err
+		}
+
+		now := time.Now().UTC().Format(dateLayout)
+		for _, e := range events {
+			if _, err := tx.exec(ctx, "create_deliveries", createDeliveries, e.id, e.userId); err != nil {
+				return err
+			}
+			if _, err := tx.exec(ctx, "mark_dispatched", markDispatched, now, e.id); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+
+	if err != nil {
+		slog.ErrorContext(ctx, "DispatchEventsRequest: error during dispatch of outbox events", "error", err)
+		return 0, err
+	}
+
+	return len(events), nil
+}
+
+func (s *Storage) ClaimDeliveriesRequest(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]models.PendingDelivery, error) {
+	var deliveries []models.PendingDelivery
+
+	err := s.inTx(ctx, func(tx *Storage) error {
+		rows, err := tx.query(ctx, "due_deliveries", dueDeliveries, now.UTC().Format(dateLayout), limit)
+		if err != nil {
+			return err
+		}
+		for rows.Next() {
+			var d models.PendingDelivery
+			var payload string
+			err := rows.Scan(&d.Id, &d.Attempts, &d.URL, &d.Secret, &d.Event.Id, &d.Event.Type, &d.Event.UserId, &payload, &d.Event.CreatedAt)
+			if err != nil {
+				rows.Close()
+				return err
+			}
+			if err := json.Unmarshal([]byte(payload), &d.Event.Data); err != nil {
+				rows.Close()
+				return fmt.Errorf("invalid payload of event %d: %w", d.Event.Id, err)
+			}
+			deliveries = append(deliveries, d)
+		}
+		if err := rows.Close(); err != nil {
+			return err
+		}
+
+		until := now.Add(lease).UTC().Format(dateLayout)
+		for _, d := range deliveries {
+			if _, err := tx.exec(ctx, "lease_delivery", leaseDelivery, until, d.Id); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+
+	if err != nil {
+		slog.ErrorContext(ctx, "ClaimDeliveriesRequest: error during claim of webhook deliveries", "error", err)
+		return nil, err
+	}
+
+	return deliveries, nil
+}
+
+func (s *Storage) SaveAttemptRequest(ctx context.Context, deliveryId int, a models.DeliveryAttempt) error {
+	_, err := s.exec(ctx, "save_attempt", saveAttempt, deliveryId, a.Status, a.StatusCode, a.Error,
+		a.NextAttemptAt.UTC().Format(dateLayout), a.At.UTC().Format(dateLayout))
+	if err != nil {
+		slog.ErrorContext(ctx, "SaveAttemptRequest: error during save of delivery attempt", "error", err)
+		return err
+	}
+
+	return nil
+}
@@ -1,113 +1,312 @@
 package storage
 
 import (
+	"context"
 	"database/sql"
+	"database/sql/driver"
+	"errors"
 	"fmt"
-	"log"
-	"os"
+	"log/slog"
+	"net"
+	"subscriptions/internal/config"
+	"subscriptions/internal/metrics"
+	"subscriptions/internal/migrations"
 	"subscriptions/internal/models"
+	"subscriptions/internal/store"
+	"subscriptions/internal/tracing"
 	"time"
 
-	"github.com/golang-migrate/migrate/v4"
-	"github.com/golang-migrate/migrate/v4/database/postgres"
 	_ "github.com/golang-migrate/migrate/v4/database/postgres"
-	_ "github.com/golang-migrate/migrate/v4/source/file"
+	"github.com/lib/pq"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 const (
-	createSub        = "INSERT INTO subscriptions (service_name, price, user_id, start_date, end_date) VALUES ($1, $2, $3, $4, $5) RETURNING user_id"
-	updateSub        = "UPDATE subscriptions SET service_name = $1, price = $2, user_id = $3, start_date = $4, end_date = $5 WHERE id = $6"
+	createSub        = "INSERT INTO subscriptions (service_name, price, user_id, start_date, end_date) VALUES ($1, $2, $3, $4, $5) RETURNING id"
 	deleteSub        = "DELETE FROM subscriptions WHERE id = $1"
 	readSub          = "SELECT id, service_name, price, user_id, start_date, end_date FROM subscriptions WHERE id = $1"
 	readSubs         = "SELECT id, service_name, price, user_id, start_date, end_date FROM subscriptions WHERE user_id = $1"
-	showsubssum      = "SELECT id, service_name, price, user_id, start_date, end_date FROM subscriptions WHERE user_id = $1 AND service_name = $2 AND start_date >= $3 AND end_date   <= $4 ORDER BY id"
-	showsubstotalsum = "SELECT COALESCE(SUM(price), 0) FROM subscriptions WHERE user_id = $1 AND service_name = $2 AND start_date >= $3 AND end_date   <= $4"
+	showsubssum      = "SELECT id, service_name, price, user_id, start_date, end_date FROM subscriptions WHERE user_id = $1 AND ($2::text = '' OR lower(btrim(service_name)) = lower($2)) AND start_date >= $3 AND end_date   <= $4 ORDER BY id"
+	showsubstotalsum = "SELECT COALESCE(SUM(price), 0) FROM subscriptions WHERE user_id = $1 AND ($2::text = '' OR lower(btrim(service_name)) = lower($2)) AND start_date >= $3 AND end_date   <= $4"
+
+	// Подписка действует в месяце, если началась не позже этого месяца и закончилась не раньше него.
+	// Месяцы считаются в UTC, LEFT JOIN оставляет в ответе месяцы без подписок.
+	// Сервисы группируются без учёта регистра и пробелов по краям, как store.ServiceKey
+	monthlySpending = `SELECT to_char(m.month, 'YYYY-MM'), min(btrim(s.service_name)), COALESCE(SUM(s.price), 0)
+		FROM generate_series(date_trunc('month', $2::timestamp), date_trunc('month', $3::timestamp), interval '1 month') AS m(month)
+		LEFT JOIN subscriptions s ON s.user_id = $1
+			AND date_trunc('month', s.start_date AT TIME ZONE 'UTC') <= m.month
+			AND (s.end_date IS NULL OR date_trunc('month', s.end_date AT TIME ZONE 'UTC') >= m.month)
+		GROUP BY m.month, lower(btrim(s.service_name))
+		ORDER BY m.month, lower(btrim(s.service_name))`
+
+	// Прежний владелец читается с блокировкой строки в том же запросе, поэтому он не может устареть до UPDATE
+	updateSub = `WITH prev AS (SELECT id, user_id FROM subscriptions WHERE id = $6 FOR UPDATE)
+		UPDATE subscriptions s SET service_name = $1, price = $2, user_id = $3, start_date = $4, end_date = $5 FROM prev WHERE s.id = prev.id
+		RETURNING s.id, s.service_name, s.price, s.user_id, s.start_date, s.end_date, prev.user_id`
 )
 
 type Storage struct {
-	Db *sql.DB
+	*Migrator
+	Db       *sql.DB
+	q        Querier // s.Db или открытая транзакция
+	replicas *replicaSet
+	state    *connState
 }
 
-func NewStorage() *Storage {
-	postUrl := os.Getenv("POSTGRES_DB_URL")
-	db, err := sql.Open("postgres", postUrl)
+func NewStorage(ctx context.Context, cfg config.DatabaseConfig) (*Storage, error) {
+	db, err := sql.Open("postgres", cfg.URL)
 	if err != nil {
-		log.Fatalf("error during database start, %v", err)
-		return nil
+		return nil, fmt.Errorf("error during database start: %w", err)
 	}
 
-	db.SetMaxOpenConns(50)
-	db.SetMaxIdleConns(25)
-	db.SetConnMaxIdleTime(5 * time.Minute)
+	db.SetMaxOpenConns(cfg.MaxOpenConns)
+	db.SetMaxIdleConns(cfg.MaxIdleConns)
+	db.SetConnMaxIdleTime(cfg.ConnMaxIdleTime)
+
+	if err := Connect(ctx, db, cfg); err != nil {
+		db.Close()
+		return nil, err
+	}
 
-	err = db.Ping()
+	replicas, err := openReplicas(cfg)
 	if err != nil {
-		log.Fatalf("error during connect to database, %v", err)
+		db.Close()
+		return nil, err
+	}
+
+	return &Storage{
+		Migrator: NewMigrator(db, "postgres", migrations.FS, cfg.MigrationsPath, postgresDriver),
+		Db:       db,
+		q:        db,
+		replicas: replicas,
+		state:    &connState{},
+	}, nil
+}
+
+func (s *Storage) RegisterMetrics(m *metrics.Metrics) {
+	m.RegisterDB(s.Db, "postgres")
+
+	if s.replicas != nil {
+		for i, r := range s.replicas.replicas {
+			m.RegisterDB(r.db, fmt.Sprintf("postgres_replica_%d", i))
+		}
+	}
+}
+
+// Используется проверкой готовности: пока база недоступна, /readyz отвечает 503
+
+func (s *Storage) Ping(ctx context.Context) error {
+	return s.state.observe(s.Db.PingContext(ctx))
+}
+
+func (s *Storage) Close() error {
+	return errors.Join(s.replicas.close(), s.Db.Close())
+}
+
+// Транзакция в REPEATABLE READ: все запросы внутри fn видят один снимок данных.
+// Вложенный вызов на хранилище транзакции переиспользует уже открытую транзакцию
+
+func (s *Storage) WithTx(ctx context.Context, fn func(tx store.Storage) error) error {
+	if s.inTx() {
+		return fn(s)
+	}
+
+	return RunTx(ctx, s.Db, &sql.TxOptions{Isolation: sql.LevelRepeatableRead}, func(tx *sql.Tx) error {
+		return fn(s.withQuerier(tx))
+	})
+}
+
+// Транзакция только на чтение, может выполняться на реплике
+
+func (s *Storage) WithReadTx(ctx context.Context, fn func(tx store.Storage) error) error {
+	if s.inTx() {
+		return fn(s)
+	}
+
+	opts := &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true}
+	run := func(tx *sql.Tx) error {
+		return fn(s.withQuerier(tx))
+	}
+
+	if r := s.reader(); r != nil {
+		err := RunTx(ctx, r.db, opts, run)
+		if !s.failover(ctx, r, err) {
+			return err
+		}
+	}
+
+	return RunTx(ctx, s.Db, opts, run)
+}
+
+func (s *Storage) inTx() bool {
+	_, ok := s.q.(*sql.Tx)
+	return ok
+}
+
+func (s *Storage) withQuerier(q Querier) *Storage {
+	return &Storage{Migrator: s.Migrator, Db: s.Db, q: q, replicas: s.replicas, state: s.state}
+}
+
+// Реплика для чтения. Внутри транзакции чтение остаётся в ней, чтобы видеть тот же снимок
+
+func (s *Storage) reader() *replica {
+	if s.inTx() {
 		return nil
 	}
+	return s.replicas.pick()
+}
+
+// При потере соединения с репликой она помечается недоступной до следующей проверки, а запрос повторяется
+// на основной базе. Остальные ошибки, в том числе ошибки самого запроса и fn в WithReadTx, возвращаются как есть:
+// на основной базе они повторились бы так же
+
+func (s *Storage) failover(ctx context.Context, r *replica, err error) bool {
+	if ctx.Err() != nil || !isConnError(err) {
+		return false
+	}
+
+	r.setHealthy(err)
+
+	return true
+}
+
+// Ошибка соединения, а не запроса: обрыв, сетевая ошибка, классы 08 (connection exception)
+// и 57P01-57P03 (сервер останавливается или ещё не готов)
+
+func isConnError(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, driver.ErrBadConn) || errors.Is(err, sql.ErrConnDone) {
+		return true
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
 
-	return &Storage{Db: db}
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		switch pqErr.Code {
+		case "57P01", "57P02", "57P03":
+			return true
+		}
+		return pqErr.Code.Class() == "08"
+	}
+
+	return false
 }
 
-func TimeForm(start time.Time, end time.Time) (string, string) {
-	return start.Format("01-2006"), end.Format("01-2006")
+func (s *Storage) readQuery(ctx context.Context, operation string, query string, args ...any) (*Rows, error) {
+	if r := s.reader(); r != nil {
+		rows, err := s.withQuerier(r.db).query(ctx, operation, query, args...)
+		if !s.failover(ctx, r, err) {
+			return rows, err
+		}
+	}
+
+	return s.query(ctx, operation, query, args...)
 }
 
-func (s *Storage) RunMigrations() {
-	driver, err := postgres.WithInstance(s.Db, &postgres.Config{})
-	if err != nil {
-		log.Fatalf("error during database driver initialization %v", err)
+func (s *Storage) readQueryRow(ctx context.Context, operation string, query string, args []any, dest ...any) error {
+	if r := s.reader(); r != nil {
+		err := s.withQuerier(r.db).queryRow(ctx, operation, query, args, dest...)
+		if !s.failover(ctx, r, err) {
+			return err
+		}
 	}
-	m, err := migrate.NewWithDatabaseInstance("file://internal/migrations", "postgres", driver)
 
+	return s.queryRow(ctx, operation, query, args, dest...)
+}
+
+// Каждый запрос к базе оборачивается в отдельный спан
+
+func startQuery(ctx context.Context, operation string, query string) (context.Context, trace.Span) {
+	return tracing.Start(ctx, "db."+operation,
+		attribute.String("db.system", "postgresql"),
+		attribute.String("db.operation", operation),
+		attribute.String("db.statement", query),
+	)
+}
+
+func (s *Storage) exec(ctx context.Context, operation string, query string, args ...any) (sql.Result, error) {
+	ctx, span := startQuery(ctx, operation, query)
+	res, err := s.q.ExecContext(ctx, query, args...)
+	tracing.End(span, err)
+	return res, err
+}
+
+func (s *Storage) query(ctx context.Context, operation string, query string, args ...any) (*Rows, error) {
+	ctx, span := startQuery(ctx, operation, query)
+	rows, err := s.q.QueryContext(ctx, query, args...)
 	if err != nil {
-		log.Fatalf("error during migration initialization: %v", err)
+		tracing.End(span, err)
+		return nil, err
 	}
+	return NewRows(rows, span), nil
+}
+
+// Нарушение уникального ограничения или индекса constraint, например при одновременной вставке двух одинаковых записей
+
+func isUniqueViolation(err error, constraint string) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505" && pqErr.Constraint == constraint
+}
 
-	if err := m.Up(); err != nil && err != migrate.ErrNoChange {
-		log.Fatalf("file application error migration: %v", err)
+func (s *Storage) queryRow(ctx context.Context, operation string, query string, args []any, dest ...any) error {
+	ctx, span := startQuery(ctx, operation, query)
+	err := s.q.QueryRowContext(ctx, query, args...).Scan(dest...)
+	if err == sql.ErrNoRows {
+		tracing.End(span, nil)
+		return err
 	}
+	tracing.End(span, err)
+	return err
 }
 
-func (s *Storage) CreateSubRequest(sub models.Subscription) (string, error) {
-	var id string
+func (s *Storage) CreateSubRequest(ctx context.Context, sub models.Subscription) (int, error) {
+	var id int
 
-	err := s.Db.QueryRow(createSub, sub.ServiceName, sub.Price, sub.UserId, sub.StartDate, sub.EndDate).Scan(&id)
+	err := s.queryRow(ctx, "create_sub", createSub, []any{sub.ServiceName, sub.Price, sub.UserId, sub.StartDate, nullableDate(sub.EndDate)}, &id)
 	if err != nil {
-		log.Printf("CreateSubRequest:error during creation of subscription record, error: %v", err.Error())
-		return "", err
+		slog.ErrorContext(ctx, "CreateSubRequest: error during creation of subscription record", "error", err)
+		return 0, err
 	}
 
 	return id, nil
 }
 
-func (s *Storage) ReadSubRequest(id int) (*models.Subscription, error) {
+func (s *Storage) ReadSubRequest(ctx context.Context, id int) (*models.Subscription, error) {
 	var sub models.Subscription
-	var startDate, endDate time.Time
+	var startDate time.Time
+	var endDate sql.NullTime
 
-	err := s.Db.QueryRow(readSub, id).Scan(&sub.Id, &sub.ServiceName, &sub.Price, &sub.UserId, &startDate, &endDate)
+	err := s.readQueryRow(ctx, "read_sub", readSub, []any{id}, &sub.Id, &sub.ServiceName, &sub.Price, &sub.UserId, &startDate, &endDate)
 
 	if err == sql.ErrNoRows {
-		log.Printf("ReadSubRequest: error during read of subscription record, error: %v", err.Error())
-		return nil, fmt.Errorf("no subscription record in database")
+		slog.WarnContext(ctx, "ReadSubRequest: subscription record not found", "id", id)
+		return nil, store.ErrNotFound
 	}
 
 	if err != nil {
-		log.Printf("ReadSubRequest: error during read of subscription record %v", err.Error())
+		slog.ErrorContext(ctx, "ReadSubRequest: error during read of subscription record", "error", err)
 		return nil, err
 	}
 
-	sub.StartDate, sub.EndDate = TimeForm(startDate, endDate)
+	sub.StartDate, sub.EndDate = formDates(startDate, endDate)
 
 	return &sub, nil
 }
 
-func (s *Storage) ReadSubsRequest(userId string) ([]models.Subscription, error) {
+func (s *Storage) ReadSubsRequest(ctx context.Context, userId string) ([]models.Subscription, error) {
 	var subs []models.Subscription
 
-	rows, err := s.Db.Query(readSubs, userId)
+	rows, err := s.readQuery(ctx, "read_subs", readSubs, userId)
 	if err != nil {
-		log.Printf("ReadSubsRequest: error during read of subscriptions records, error: %v", err.Error())
+		slog.ErrorContext(ctx, "ReadSubsRequest: error during read of subscriptions records", "error", err)
 		return nil, err
 	}
 
@@ -115,49 +314,70 @@ func (s *Storage) ReadSubsRequest(userId string) ([]models.Subscription, error)
 
 	for rows.Next() {
 		var sub models.Subscription
-		var startDate, endDate time.Time
+		var startDate time.Time
+		var endDate sql.NullTime
 		err = rows.Scan(&sub.Id, &sub.ServiceName, &sub.Price, &sub.UserId, &startDate, &endDate)
 		if err != nil {
-			log.Printf("ReadSubsRequest: error during rowscan, error: %v", err.Error())
+			slog.ErrorContext(ctx, "ReadSubsRequest: error during rowscan", "error", err)
 			return nil, err
 		}
-		sub.StartDate, sub.EndDate = TimeForm(startDate, endDate)
+		sub.StartDate, sub.EndDate = formDates(startDate, endDate)
 		subs = append(subs, sub)
 	}
 
 	return subs, nil
 }
 
-func (s *Storage) UpdateSubRequest(sub models.Subscription) error {
+func (s *Storage) UpdateSubRequest(ctx context.Context, sub models.Subscription) (*models.Subscription, string, error) {
+	var updated models.Subscription
+	var startDate time.Time
+	var endDate sql.NullTime
+	var prevUserId string
 
-	_, err := s.Db.Exec(updateSub, sub.ServiceName, sub.Price, sub.UserId, sub.StartDate, sub.EndDate, sub.Id)
+	args := []any{sub.ServiceName, sub.Price, sub.UserId, sub.StartDate, nullableDate(sub.EndDate), sub.Id}
+	err := s.queryRow(ctx, "update_sub", updateSub, args, &updated.Id, &updated.ServiceName, &updated.Price, &updated.UserId, &startDate, &endDate, &prevUserId)
+
+	if err == sql.ErrNoRows {
+		slog.WarnContext(ctx, "UpdateSubRequest: subscription record not found", "id", sub.Id)
+		return nil, "", store.ErrNotFound
+	}
 
 	if err != nil {
-		log.Printf("UpdateSubRequest: error during update of subscription record, error: %v", err.Error())
-		return err
+		slog.ErrorContext(ctx, "UpdateSubRequest: error during update of subscription record", "error", err)
+		return nil, "", err
 	}
 
-	return nil
-}
+	updated.StartDate, updated.EndDate = formDates(startDate, endDate)
 
-func (s *Storage) DeleteSubRequest(id int) error {
+	return &updated, prevUserId, nil
+}
 
-	_, err := s.Db.Exec(deleteSub, id)
+func (s *Storage) DeleteSubRequest(ctx context.Context, id int) error {
+	res, err := s.exec(ctx, "delete_sub", deleteSub, id)
+	if err != nil {
+		slog.ErrorContext(ctx, "DeleteSubRequest: error during delete of subscription record", "error", err)
+		return err
+	}
 
+	n, err := res.RowsAffected()
 	if err != nil {
-		log.Printf("DeleteSubRequest: error during delete of subscription record, error: %v", err.Error())
 		return err
 	}
+	if n == 0 {
+		return store.ErrNotFound
+	}
 
 	return nil
 }
 
-func (s *Storage) ShowSubscSumRequest(serviceName string, userId string, startPeriod string, endPeriod string) ([]models.Subscription, error) {
+// Пустой serviceName означает все сервисы пользователя, имя сравнивается без учёта регистра и пробелов по краям
+
+func (s *Storage) ShowSubscSumRequest(ctx context.Context, serviceName string, userId string, startPeriod string, endPeriod string) (*models.SubscriptionSum, error) {
 	var subs []models.Subscription
 
-	rows, err := s.Db.Query(showsubssum, userId, serviceName, startPeriod, endPeriod)
+	rows, err := s.readQuery(ctx, "show_subs_sum", showsubssum, userId, serviceName, startPeriod, endPeriod)
 	if err != nil {
-		log.Printf("ShowSubscSumRequest: error during read of subscriptions records, error: %v", err.Error())
+		slog.ErrorContext(ctx, "ShowSubscSumRequest: error during read of subscriptions records", "error", err)
 		return nil, err
 	}
 
@@ -165,15 +385,16 @@ func (s *Storage) ShowSubscSumRequest(serviceName string, userId string, startPe
 
 	for rows.Next() {
 		var sub models.Subscription
-		var startDate, endDate time.Time
+		var startDate time.Time
+		var endDate sql.NullTime
 		err = rows.Scan(&sub.Id, &sub.ServiceName, &sub.Price, &sub.UserId, &startDate, &endDate)
 
 		if err != nil {
-			log.Printf("ShowSubscSumRequest: error during rowscan, error: %v", err.Error())
+			slog.ErrorContext(ctx, "ShowSubscSumRequest: error during rowscan", "error", err)
 			return nil, err
 		}
 
-		sub.StartDate, sub.EndDate = TimeForm(startDate, endDate)
+		sub.StartDate, sub.EndDate = formDates(startDate, endDate)
 
 		subs = append(subs, sub)
 	}
@@ -185,15 +406,29 @@ func (s *Storage) ShowSubscSumRequest(serviceName string, userId string, startPe
 
 	var total int
 
-	err = s.Db.QueryRow(showsubstotalsum, userId, serviceName, startPeriod, endPeriod).Scan(&total)
+	err = s.readQueryRow(ctx, "show_subs_total_sum", showsubstotalsum, []any{userId, serviceName, startPeriod, endPeriod}, &total)
 	if err != nil {
-		log.Printf("ShowSubscSumRequest: error during read of sum, error: %v", err.Error())
+		slog.ErrorContext(ctx, "ShowSubscSumRequest: error during read of sum", "error", err)
 		return nil, err
 	}
-	subs = append(subs, models.Subscription{
-		ServiceName: "Итого",
-		TotalSum:    total,
-	})
 
-	return subs, nil
+	return &models.SubscriptionSum{Items: subs, Total: total}, nil
+}
+
+// Помесячные расходы пользователя за период от startPeriod до endPeriod включительно
+
+func (s *Storage) MonthlySpendingRequest(ctx context.Context, userId string, startPeriod string, endPeriod string) ([]models.MonthlySpending, error) {
+	rows, err := s.readQuery(ctx, "monthly_spending", monthlySpending, userId, startPeriod, endPeriod)
+	if err != nil {
+		slog.ErrorContext(ctx, "MonthlySpendingRequest: error during read of monthly spending", "error", err)
+		return nil, err
+	}
+
+	months, err := ScanMonthly(rows)
+	if err != nil {
+		slog.ErrorContext(ctx, "MonthlySpendingRequest: error during rowscan", "error", err)
+		return nil, err
+	}
+
+	return months, nil
 }
@@ -0,0 +1,76 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"subscriptions/internal/tracing"
+	"sync"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Общий интерфейс *sql.DB и *sql.Tx: запросы хранилища выполняются либо напрямую, либо внутри транзакции
+
+type Querier interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// Строки результата запроса вместе с его спаном. Запрос выполняется, пока строки читаются,
+// поэтому спан закрывается в Close, а не сразу после QueryContext
+
+type Rows struct {
+	*sql.Rows
+	span trace.Span
+	once sync.Once
+}
+
+func NewRows(rows *sql.Rows, span trace.Span) *Rows {
+	return &Rows{Rows: rows, span: span}
+}
+
+func (r *Rows) Close() error {
+	err := r.Rows.Close()
+	r.once.Do(func() {
+		rowsErr := r.Rows.Err()
+		if rowsErr == nil {
+			rowsErr = err
+		}
+		tracing.End(r.span, rowsErr)
+	})
+	return err
+}
+
+// Выполняет fn в транзакции: коммит при успехе, откат при ошибке или панике
+
+func RunTx(ctx context.Context, db *sql.DB, opts *sql.TxOptions, fn func(tx *sql.Tx) error) (err error) {
+	ctx, span := tracing.Start(ctx, "db.transaction")
+	defer func() { tracing.End(span, err) }()
+
+	tx, err := db.BeginTx(ctx, opts)
+	if err != nil {
+		return fmt.Errorf("error during transaction begin: %w", err)
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			tx.Rollback()
+			panic(p)
+		}
+	}()
+
+	if err = fn(tx); err != nil {
+		if rbErr := tx.Rollback(); rbErr != nil {
+			return fmt.Errorf("%w (rollback: %v)", err, rbErr)
+		}
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("error during transaction commit: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,219 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"log/slog"
+	"subscriptions/internal/models"
+	"subscriptions/internal/store"
+	"time"
+)
+
+const (
+	addEvent       = "INSERT INTO outbox (event_type, user_id, payload) VALUES ($1, $2, $3)"
+	createWebhook  = "INSERT INTO webhooks (user_id, url, secret) VALUES ($1, $2, $3) RETURNING id"
+	readWebhook    = "SELECT id, user_id, url, secret, created_at FROM webhooks WHERE id = $1"
+	readWebhooks   = "SELECT id, user_id, url, secret, created_at FROM webhooks WHERE user_id = $1 ORDER BY id"
+	deleteWebhook  = "DELETE FROM webhooks WHERE id = $1"
+	readDeliveries = `SELECT d.id, d.webhook_id, d.event_id, e.event_type, d.status, d.attempts, COALESCE(d.last_status_code, 0), COALESCE(d.last_error, ''),
+			d.next_attempt_at, d.delivered_at, d.created_at
+		FROM webhook_deliveries d JOIN outbox e ON e.id = d.event_id
+		WHERE d.webhook_id = $1 ORDER BY d.id DESC LIMIT 100`
+
+	// Событие помечается разосланным одновременно с созданием доставок на все вебхуки пользователя.
+	// SKIP LOCKED не даёт двум инстансам разослать одно событие
+	dispatchEvents = `WITH ev AS (
+			UPDATE outbox SET dispatched_at = now()
+			WHERE id IN (SELECT id FROM outbox WHERE dispatched_at IS NULL ORDER BY id LIMIT $1 FOR UPDATE SKIP LOCKED)
+			RETURNING id, user_id
+		), deliveries AS (
+			INSERT INTO webhook_deliveries (webhook_id, event_id)
+			SELECT w.id, ev.id FROM ev JOIN webhooks w ON w.user_id = ev.user_id
+			ON CONFLICT DO NOTHING
+		)
+		SELECT count(*) FROM ev`
+
+	// Взятая доставка откладывается на время аренды: если отправитель упадёт, её возьмут снова после lease
+	claimDeliveries = `WITH claimed AS (
+			UPDATE webhook_deliveries SET next_attempt_at = $2
+			WHERE id IN (SELECT id FROM webhook_deliveries WHERE status = 'pending' AND next_attempt_at <= $1
+				ORDER BY next_attempt_at, id LIMIT $3 FOR UPDATE SKIP LOCKED)
+			RETURNING id, webhook_id, event_id, attempts
+		)
+		SELECT c.id, c.attempts, w.url, w.secret, e.id, e.event_type, e.user_id, e.payload, e.created_at
+		FROM claimed c JOIN webhooks w ON w.id = c.webhook_id JOIN outbox e ON e.id = c.event_id
+		ORDER BY c.id`
+	saveAttempt = `UPDATE webhook_deliveries SET status = $2, attempts = attempts + 1, last_status_code = NULLIF($3, 0), last_error = NULLIF($4, ''),
+		next_attempt_at = $5, delivered_at = CASE WHEN $2 = 'delivered' THEN $6::timestamptz END WHERE id = $1`
+)
+
+func (s *Storage) AddEventRequest(ctx context.Context, e models.Event) error {
+	payload, err := json.Marshal(e.Data)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.exec(ctx, "add_event", addEvent, e.Type, e.UserId, payload)
+	if err != nil {
+		slog.ErrorContext(ctx, "AddEventRequest: error during insert into outbox", "error", err)
+		return err
+	}
+
+	return nil
+}
+
+func (s *Storage) CreateWebhookRequest(ctx context.Context, wh models.Webhook) (int, error) {
+	var id int
+
+	err := s.queryRow(ctx, "create_webhook", createWebhook, []any{wh.UserId, wh.URL, wh.Secret}, &id)
+	if err != nil {
+		slog.ErrorContext(ctx, "CreateWebhookRequest: error during creation of webhook record", "error", err)
+		return 0, err
+	}
+
+	return id, nil
+}
+
+func (s *Storage) ReadWebhookRequest(ctx context.Context, id int) (*models.Webhook, error) {
+	var wh models.Webhook
+	var createdAt time.Time
+
+	err := s.readQueryRow(ctx, "read_webhook", readWebhook, []any{id}, &wh.Id, &wh.UserId, &wh.URL, &wh.Secret, &createdAt)
+
+	if err == sql.ErrNoRows {
+		slog.WarnContext(ctx, "ReadWebhookRequest: webhook record not found", "id", id)
+		return nil, store.ErrWebhookNotFound
+	}
+
+	if err != nil {
+		slog.ErrorContext(ctx, "ReadWebhookRequest: error during read of webhook record", "error", err)
+		return nil, err
+	}
+
+	wh.CreatedAt = createdAt.UTC().Format(time.RFC3339)
+
+	return &wh, nil
+}
+
+func (s *Storage) ReadWebhooksRequest(ctx context.Context, userId string) ([]models.Webhook, error) {
+	rows, err := s.readQuery(ctx, "read_webhooks", readWebhooks, userId)
+	if err != nil {
+		slog.ErrorContext(ctx, "ReadWebhooksRequest: error during read of webhook records", "error", err)
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var webhooks []models.Webhook
+	for rows.Next() {
+		var wh models.Webhook
+		var createdAt time.Time
+		if err := rows.Scan(&wh.Id, &wh.UserId, &wh.URL, &wh.Secret, &createdAt); err != nil {
+			slog.ErrorContext(ctx, "ReadWebhooksRequest: error during rowscan", "error", err)
+			return nil, err
+		}
+		wh.CreatedAt = createdAt.UTC().Format(time.RFC3339)
+		webhooks = append(webhooks, wh)
+	}
+
+	return webhooks, rows.Err()
+}
+
+func (s *Storage) DeleteWebhookRequest(ctx context.Context, id int) error {
+	_, err := s.exec(ctx, "delete_webhook", deleteWebhook, id)
+	if err != nil {
+		slog.ErrorContext(ctx, "DeleteWebhookRequest: error during delete of webhook record", "error", err)
+		return err
+	}
+
+	return nil
+}
+
+// Последние 100 доставок, сначала новые
+
+func (s *Storage) ReadDeliveriesRequest(ctx context.Context, webhookId int) ([]models.WebhookDelivery, error) {
+	rows, err := s.readQuery(ctx, "read_deliveries", readDeliveries, webhookId)
+	if err != nil {
+		slog.ErrorContext(ctx, "ReadDeliveriesRequest: error during read of webhook deliveries", "error", err)
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var deliveries []models.WebhookDelivery
+	for rows.Next() {
+		var d models.WebhookDelivery
+		var nextAttemptAt, createdAt time.Time
+		var deliveredAt sql.NullTime
+		err := rows.Scan(&d.Id, &d.WebhookId, &d.EventId, &d.EventType, &d.Status, &d.Attempts, &d.LastStatusCode, &d.LastError,
+			&nextAttemptAt, &deliveredAt, &createdAt)
+		if err != nil {
+			slog.ErrorContext(ctx, "ReadDeliveriesRequest: error during rowscan", "error", err)
+			return nil, err
+		}
+		if d.Status == "pending" {
+			d.NextAttemptAt = nextAttemptAt.UTC().Format(time.RFC3339)
+		}
+		if deliveredAt.Valid {
+			d.DeliveredAt = deliveredAt.Time.UTC().Format(time.RFC3339)
+		}
+		d.CreatedAt = createdAt.UTC().Format(time.RFC3339)
+		deliveries = append(deliveries, d)
+	}
+
+	return deliveries, rows.Err()
+}
+
+// Создаёт доставки для limit ещё не разосланных событий. Возвращает число разосланных событий
+
+func (s *Storage) DispatchEventsRequest(ctx context.Context, limit int) (int, error) {
+	var n int
+
+	err := s.queryRow(ctx, "dispatch_events", dispatchEvents, []any{limit}, &n)
+	if err != nil {
+		slog.ErrorContext(ctx, "DispatchEventsRequest: error during dispatch of outbox events", "error", err)
+		return 0, err
+	}
+
+	return n, nil
+}
+
+func (s *Storage) ClaimDeliveriesRequest(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]models.PendingDelivery, error) {
+	rows, err := s.query(ctx, "claim_deliveries", claimDeliveries, now, now.Add(lease), limit)
+	if err != nil {
+		slog.ErrorContext(ctx, "ClaimDeliveriesRequest: error during claim of webhook deliveries", "error", err)
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var deliveries []models.PendingDelivery
+	for rows.Next() {
+		var d models.PendingDelivery
+		var payload []byte
+		var createdAt time.Time
+		err := rows.Scan(&d.Id, &d.Attempts, &d.URL, &d.Secret, &d.Event.Id, &d.Event.Type, &d.Event.UserId, &payload, &createdAt)
+		if err != nil {
+			slog.ErrorContext(ctx, "ClaimDeliveriesRequest: error during rowscan", "error", err)
+			return nil, err
+		}
+		if err := json.Unmarshal(payload, &d.Event.Data); err != nil {
+			return nil, err
+		}
+		d.Event.CreatedAt = createdAt.UTC().Format(time.RFC3339)
+		deliveries = append(deliveries, d)
+	}
+
+	return deliveries, rows.Err()
+}
+
+func (s *Storage) SaveAttemptRequest(ctx context.Context, deliveryId int, a models.DeliveryAttempt) error {
+	_, err := s.exec(ctx, "save_attempt", saveAttempt, deliveryId, a.Status, a.StatusCode, a.Error, a.NextAttemptAt, a.At)
+	if err != nil {
+		slog.ErrorContext(ctx, "SaveAttemptRequest: error during save of delivery attempt", "error", err)
+		return err
+	}
+
+	return nil
+}
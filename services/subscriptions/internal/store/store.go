@@ -0,0 +1,69 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"subscriptions/internal/models"
+)
+
+// Интерфейс хранилища и его ошибки. Пакет не зависит ни от сервиса, ни от реализаций,
+// поэтому их импортируют и сервис, и хранилища
+
+// Хранилища возвращают ErrNotFound, ErrBudgetNotFound, ErrWebhookNotFound и ErrCatalogEntryNotFound,
+// когда записи с таким id нет
+
+var ErrNotFound = errors.New("no subscription record in database")
+
+var ErrBudgetNotFound = errors.New("no budget record in database")
+
+var ErrWebhookNotFound = errors.New("no webhook record in database")
+
+var ErrCatalogEntryNotFound = errors.New("no catalog record in database")
+
+var ErrBudgetExists = errors.New("budget for this service already exists")
+
+// ErrCatalogConflict возвращается и при нарушении уникального индекса по имени записи каталога
+
+var ErrCatalogConflict = errors.New("service name or alias is already in the catalog")
+
+// Ключ, по которому суммы группируются по сервису: имя без учёта регистра и пробелов по краям,
+// как lower(btrim(service_name)) в SQL
+
+func ServiceKey(name string) string {
+	return strings.ToLower(strings.TrimSpace(name))
+}
+
+type Storage interface {
+	CreateSubRequest(ctx context.Context, sub models.Subscription) (int, error)
+	ReadSubRequest(ctx context.Context, id int) (*models.Subscription, error)
+	ReadSubsRequest(ctx context.Context, userId string) ([]models.Subscription, error)
+	UpdateSubRequest(ctx context.Context, sub models.Subscription) (*models.Subscription, string, error) // Возвращает запись после изменения и прежнего владельца, ErrNotFound если её нет
+	DeleteSubRequest(ctx context.Context, id int) error                                                  // ErrNotFound, если удалять было нечего
+	ShowSubscSumRequest(ctx context.Context, serviceName string, userId string, startPeriod string, EndPeriod string) (*models.SubscriptionSum, error)
+	MonthlySpendingRequest(ctx context.Context, userId string, startPeriod string, endPeriod string) ([]models.MonthlySpending, error)
+	CreateBudgetRequest(ctx context.Context, b models.Budget) (int, error)
+	ReadBudgetRequest(ctx context.Context, id int) (*models.Budget, error)
+	ReadBudgetsRequest(ctx context.Context, userId string) ([]models.Budget, error)
+	UpdateBudgetRequest(ctx context.Context, b models.Budget) error
+	DeleteBudgetRequest(ctx context.Context, id int) error
+	SaveAlertRequest(ctx context.Context, a models.BudgetAlert) error // Создаёт предупреждение или обновляет сумму в существующем за тот же месяц
+	DeleteAlertRequest(ctx context.Context, budgetId int, month string) error
+	ReadAlertsRequest(ctx context.Context, userId string) ([]models.BudgetAlert, error)
+	AddEventRequest(ctx context.Context, e models.Event) error // Запись события в outbox, вызывается в транзакции изменения подписки
+	CreateWebhookRequest(ctx context.Context, wh models.Webhook) (int, error)
+	ReadWebhookRequest(ctx context.Context, id int) (*models.Webhook, error)
+	ReadWebhooksRequest(ctx context.Context, userId string) ([]models.Webhook, error)
+	DeleteWebhookRequest(ctx context.Context, id int) error
+	ReadDeliveriesRequest(ctx context.Context, webhookId int) ([]models.WebhookDelivery, error)
+	LockCatalogRequest(ctx context.Context) error // Блокирует изменения каталога другими транзакциями до конца текущей
+	CreateCatalogEntryRequest(ctx context.Context, e models.CatalogEntry) (int, error)
+	ReadCatalogEntryRequest(ctx context.Context, id int) (*models.CatalogEntry, error)
+	ReadCatalogRequest(ctx context.Context) ([]models.CatalogEntry, error)
+	FindCatalogEntryRequest(ctx context.Context, name string) (*models.CatalogEntry, error) // По имени или псевдониму без учёта регистра
+	UpdateCatalogEntryRequest(ctx context.Context, e models.CatalogEntry) error
+	DeleteCatalogEntryRequest(ctx context.Context, id int) error
+	RenameServiceRequest(ctx context.Context, keys []string, name string) error // Переименовывает в name подписки и бюджеты, чей ServiceKey входит в keys
+	WithTx(ctx context.Context, fn func(tx Storage) error) error                // Выполняет fn в одной транзакции, tx действует только внутри fn
+	WithReadTx(ctx context.Context, fn func(tx Storage) error) error            // То же только для чтения, может выполняться на реплике
+}
@@ -0,0 +1,7 @@
+package webhooks
+
+import "time"
+
+func (d *Dispatcher) SetNow(now func() time.Time) {
+	d.now = now
+}
@@ -0,0 +1,108 @@
+package webhooks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"subscriptions/internal/config"
+	"syscall"
+	"time"
+)
+
+var (
+	ErrInvalidURL       = errors.New("webhook url must be an absolute http or https URL")
+	ErrForbiddenAddress = errors.New("webhook address is not allowed")
+)
+
+// Общее адресное пространство провайдеров (RFC 6598), в нём же метаданные некоторых облаков, например 100.100.100.200
+
+var sharedAddressSpace = netip.MustParsePrefix("100.64.0.0/10")
+
+// Не пускает запросы к вебхукам во внутреннюю сеть: loopback, частные и link-local адреса, в том числе
+// метаданные облака 169.254.169.254 и fd00:ec2::254. Адрес проверяется при регистрации вебхука и ещё раз
+// при каждом соединении, потому что DNS имени может смениться после регистрации
+
+type Guard struct {
+	allowPrivate bool
+	resolver     *net.Resolver
+}
+
+func NewGuard(cfg config.WebhooksConfig) *Guard {
+	return &Guard{
+		allowPrivate: cfg.AllowPrivateNetworks,
+		resolver:     net.DefaultResolver,
+	}
+}
+
+func (g *Guard) allowed(ip netip.Addr) bool {
+	ip = ip.Unmap()
+	return !ip.IsLoopback() && !ip.IsPrivate() && !ip.IsLinkLocalUnicast() && !ip.IsLinkLocalMulticast() &&
+		!ip.IsInterfaceLocalMulticast() && !ip.IsMulticast() && !ip.IsUnspecified() && !sharedAddressSpace.Contains(ip)
+}
+
+// Проверяет адрес при регистрации: схема http или https и все адреса имени вне внутренней сети
+
+func (g *Guard) CheckURL(ctx context.Context, rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Scheme != "http" && u.Scheme != "https" || u.Hostname() == "" {
+		return ErrInvalidURL
+	}
+
+	if g.allowPrivate {
+		return nil
+	}
+
+	addrs, err := g.resolver.LookupNetIP(ctx, "ip", u.Hostname())
+	if err != nil {
+		return fmt.Errorf("error during resolve of webhook host: %w", err)
+	}
+
+	for _, ip := range addrs {
+		if !g.allowed(ip) {
+			return fmt.Errorf("%w: %s resolves to %s", ErrForbiddenAddress, u.Hostname(), ip)
+		}
+	}
+
+	return nil
+}
+
+// Вызывается для каждого соединения после разрешения имени, поэтому проверяет тот адрес, к которому идёт запрос
+
+func (g *Guard) control(network string, address string, _ syscall.RawConn) error {
+	if g.allowPrivate {
+		return nil
+	}
+
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrForbiddenAddress, address)
+	}
+	if !g.allowed(addrPort.Addr()) {
+		return fmt.Errorf("%w: %s", ErrForbiddenAddress, addrPort.Addr())
+	}
+
+	return nil
+}
+
+// HTTP клиент для доставок: без прокси из окружения, чтобы соединение шло прямо на проверенный адрес,
+// и без переходов по редиректам, которые могли бы увести запрос во внутреннюю сеть
+
+func (g *Guard) client(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{Timeout: timeout, Control: g.control}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: transport,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
@@ -0,0 +1,223 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"strconv"
+	"subscriptions/internal/config"
+	"subscriptions/internal/models"
+	"sync"
+	"time"
+)
+
+// Статусы доставки
+
+const (
+	StatusPending   = "pending"
+	StatusDelivered = "delivered"
+	StatusFailed    = "failed"
+)
+
+// Заголовки запроса с событием. Подпись — HMAC-SHA256 секретом вебхука от "<timestamp>.<тело запроса>"
+
+const (
+	HeaderEvent     = "X-Webhook-Event"
+	HeaderDelivery  = "X-Webhook-Delivery"
+	HeaderTimestamp = "X-Webhook-Timestamp"
+	HeaderSignature = "X-Webhook-Signature"
+)
+
+// События outbox и журнал доставок. Хранилище само следит, чтобы событие разослали и доставку взяли один раз
+
+type Store interface {
+	DispatchEventsRequest(ctx context.Context, limit int) (int, error)
+	ClaimDeliveriesRequest(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]models.PendingDelivery, error)
+	SaveAttemptRequest(ctx context.Context, deliveryId int, a models.DeliveryAttempt) error
+}
+
+type Dispatcher struct {
+	store  Store
+	client *http.Client
+	cfg    config.WebhooksConfig
+	now    func() time.Time
+}
+
+func NewDispatcher(store Store, cfg config.WebhooksConfig) *Dispatcher {
+	return &Dispatcher{
+		store:  store,
+		client: NewGuard(cfg).client(cfg.Timeout),
+		cfg:    cfg,
+		now:    time.Now,
+	}
+}
+
+// Подпись тела запроса, получатель проверяет её тем же секретом
+
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Раз в poll_interval рассылает новые события и отправляет доставки, у которых подошло время, пока не отменён ctx
+
+func (d *Dispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.cfg.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := d.Process(ctx); err != nil && ctx.Err() == nil {
+			slog.ErrorContext(ctx, "Dispatcher: error during processing of webhook deliveries", "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Один проход: создаёт доставки для новых событий и выполняет одну попытку для каждой подошедшей доставки
+
+func (d *Dispatcher) Process(ctx context.Context) error {
+	if _, err := d.store.DispatchEventsRequest(ctx, d.cfg.BatchSize); err != nil {
+		return err
+	}
+
+	// Аренды хватает на весь пакет, даже если каждый запрос упрётся в таймаут
+	lease := time.Duration(d.cfg.BatchSize/d.cfg.Concurrency+1) * d.cfg.Timeout
+
+	deliveries, err := d.store.ClaimDeliveriesRequest(ctx, d.now(), lease, d.cfg.BatchSize)
+	if err != nil {
+		return err
+	}
+
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		errs []error
+	)
+	sem := make(chan struct{}, d.cfg.Concurrency)
+
+	for _, pd := range deliveries {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer func() { <-sem; wg.Done() }()
+
+			a := d.attempt(ctx, pd)
+			// При остановке попытка не засчитывается, доставку возьмут снова после аренды
+			if ctx.Err() != nil {
+				return
+			}
+			if err := d.store.SaveAttemptRequest(ctx, pd.Id, a); err != nil {
+				mu.Lock()
+				errs = append(errs, err)
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	return errors.Join(errs...)
+}
+
+// Отправляет событие и решает, что делать дальше: ответ 2xx завершает доставку,
+// иначе следующая попытка через backoff, удваивающийся с каждой попыткой, пока не кончатся max_attempts
+
+func (d *Dispatcher) attempt(ctx context.Context, pd models.PendingDelivery) models.DeliveryAttempt {
+	code, err := d.send(ctx, pd)
+	at := d.now()
+	a := models.DeliveryAttempt{Status: StatusDelivered, StatusCode: code, At: at, NextAttemptAt: at}
+
+	if err == nil {
+		return a
+	}
+
+	a.Error = describeError(code, err)
+	attempts := pd.Attempts + 1
+
+	if attempts >= d.cfg.MaxAttempts {
+		a.Status = StatusFailed
+		slog.WarnContext(ctx, "webhook delivery failed", "delivery_id", pd.Id, "event_id", pd.Event.Id, "attempts", attempts, "error", err)
+		return a
+	}
+
+	a.Status = StatusPending
+	a.NextAttemptAt = at.Add(Backoff(d.cfg.Backoff, d.cfg.MaxBackoff, attempts))
+	slog.InfoContext(ctx, "webhook delivery will be retried", "delivery_id", pd.Id, "attempts", attempts, "next_attempt_at", a.NextAttemptAt, "error", err)
+
+	return a
+}
+
+// В журнал доставок, который видит пользователь, пишется только вид ошибки: текст ошибки соединения
+// раскрывает адреса и устройство сети. Полная ошибка остаётся в логе
+
+func describeError(code int, err error) string {
+	var netErr net.Error
+	switch {
+	case code != 0:
+		return fmt.Sprintf("unexpected status %d", code)
+	case errors.Is(err, ErrForbiddenAddress):
+		return "destination address is not allowed"
+	case errors.As(err, &netErr) && netErr.Timeout():
+		return "request timed out"
+	default:
+		return "request failed"
+	}
+}
+
+// Пауза после attempts неудачных попыток: base, 2*base, 4*base ... но не больше limit
+
+func Backoff(base time.Duration, limit time.Duration, attempts int) time.Duration {
+	backoff := base
+	for i := 1; i < attempts && backoff < limit; i++ {
+		backoff *= 2
+	}
+	return min(backoff, limit)
+}
+
+func (d *Dispatcher) send(ctx context.Context, pd models.PendingDelivery) (int, error) {
+	body, err := json.Marshal(pd.Event)
+	if err != nil {
+		return 0, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, pd.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+
+	timestamp := d.now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderEvent, pd.Event.Type)
+	req.Header.Set(HeaderDelivery, strconv.Itoa(pd.Id))
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(HeaderSignature, Sign(pd.Secret, timestamp, body))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	return resp.StatusCode, nil
+}
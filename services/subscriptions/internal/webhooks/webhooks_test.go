@@ -0,0 +1,213 @@
+package webhooks_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"subscriptions/internal/config"
+	"subscriptions/internal/models"
+	"subscriptions/internal/service"
+	"subscriptions/internal/storage/memory"
+	"subscriptions/internal/webhooks"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const userId = "60601fee-2bf1-4721-ae6f-7636e79a0cba"
+
+var cfg = config.WebhooksConfig{
+	Timeout:     time.Second,
+	MaxAttempts: 3,
+	Backoff:     10 * time.Second,
+	MaxBackoff:  time.Minute,
+	BatchSize:   10,
+	Concurrency: 2,
+
+	// Тестовые получатели слушают 127.0.0.1
+	AllowPrivateNetworks: true,
+}
+
+func TestDispatcher(t *testing.T) {
+	ctx := context.Background()
+	st := memory.NewStorage()
+	s := service.NewService(st)
+
+	var failing atomic.Bool
+	type request struct {
+		header http.Header
+		body   []byte
+	}
+	received := make(chan request, 10)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if failing.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		received <- request{r.Header, body}
+	}))
+	t.Cleanup(srv.Close)
+
+	wh, err := s.CreateWebhook(ctx, models.Webhook{UserId: userId, URL: srv.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	created, err := s.CreateSub(ctx, models.Subscription{ServiceName: "Netflix", Price: 400, UserId: userId, StartDate: "2025-07-01"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := created.Id
+
+	// Доставки создаются со временем попытки по часам хранилища, часы отправителя идут от него
+	now := time.Now().Add(time.Second)
+	d := webhooks.NewDispatcher(st, cfg)
+	d.SetNow(func() time.Time { return now })
+
+	if err := d.Process(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	var r request
+	select {
+	case r = <-received:
+	default:
+		t.Fatal("event was not delivered")
+	}
+	body := r.body
+
+	if r.header.Get(webhooks.HeaderEvent) != service.EventSubCreated {
+		t.Fatalf("event header %q", r.header.Get(webhooks.HeaderEvent))
+	}
+	ts, _ := strconv.ParseInt(r.header.Get(webhooks.HeaderTimestamp), 10, 64)
+	if got, want := r.header.Get(webhooks.HeaderSignature), webhooks.Sign(wh.Secret, ts, body); got != want {
+		t.Fatalf("signature %q, want %q", got, want)
+	}
+
+	var e models.Event
+	if err := json.Unmarshal(body, &e); err != nil {
+		t.Fatal(err)
+	}
+	if e.Type != service.EventSubCreated || e.Data.Id != id || e.Data.ServiceName != "Netflix" {
+		t.Fatalf("unexpected event %+v", e)
+	}
+
+	// Недоступный получатель: повтор через 10s, затем 20s, после третьей попытки доставка прекращается
+	failing.Store(true)
+	if _, err := s.DeleteSub(ctx, id); err != nil {
+		t.Fatal(err)
+	}
+	for _, wait := range []time.Duration{0, 10 * time.Second, 20 * time.Second} {
+		now = now.Add(wait)
+		if err := d.Process(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	deliveries, err := st.ReadDeliveriesRequest(ctx, wh.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(deliveries) != 2 {
+		t.Fatalf("got %d deliveries, want 2", len(deliveries))
+	}
+	if got := deliveries[0]; got.EventType != service.EventSubDeleted || got.Status != webhooks.StatusFailed || got.Attempts != 3 || got.LastStatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("unexpected failed delivery %+v", got)
+	}
+	if got := deliveries[1]; got.Status != webhooks.StatusDelivered || got.Attempts != 1 || got.DeliveredAt == "" {
+		t.Fatalf("unexpected delivered delivery %+v", got)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	want := []time.Duration{10 * time.Second, 20 * time.Second, 40 * time.Second, time.Minute, time.Minute}
+	for i, w := range want {
+		if got := webhooks.Backoff(10*time.Second, time.Minute, i+1); got != w {
+			t.Fatalf("attempt %d: backoff %v, want %v", i+1, got, w)
+		}
+	}
+}
+
+func TestGuard(t *testing.T) {
+	ctx := context.Background()
+	g := webhooks.NewGuard(config.WebhooksConfig{})
+
+	for url, want := range map[string]error{
+		"ftp://example.com":                        webhooks.ErrInvalidURL,
+		"https:///hook":                            webhooks.ErrInvalidURL,
+		"http://127.0.0.1:8080/hook":               webhooks.ErrForbiddenAddress,
+		"http://[::1]/hook":                        webhooks.ErrForbiddenAddress,
+		"http://10.0.0.5/hook":                     webhooks.ErrForbiddenAddress,
+		"http://192.168.1.1/hook":                  webhooks.ErrForbiddenAddress,
+		"http://169.254.169.254/latest/meta-data/": webhooks.ErrForbiddenAddress,
+		"http://[fd00:ec2::254]/":                  webhooks.ErrForbiddenAddress,
+		"http://100.100.100.200/":                  webhooks.ErrForbiddenAddress,
+		"http://[::ffff:127.0.0.1]/":               webhooks.ErrForbiddenAddress,
+		"http://0.0.0.0/":                          webhooks.ErrForbiddenAddress,
+		"https://93.184.215.14/hook":               nil,
+	} {
+		if err := g.CheckURL(ctx, url); !errors.Is(err, want) {
+			t.Errorf("%s: error %v, want %v", url, err, want)
+		}
+	}
+}
+
+// Проверка при соединении не даёт доставить событие на внутренний адрес, даже если он прошёл регистрацию,
+// а редирект не уводит запрос на другой адрес
+
+func TestDispatcherAddressChecks(t *testing.T) {
+	ctx := context.Background()
+
+	var hits atomic.Int32
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { hits.Add(1) }))
+	t.Cleanup(target.Close)
+	redirect := httptest.NewServer(http.RedirectHandler(target.URL, http.StatusFound))
+	t.Cleanup(redirect.Close)
+
+	for _, tc := range []struct {
+		name      string
+		url       string
+		allow     bool
+		code      int
+		lastError string
+	}{
+		{"private address", target.URL, false, 0, "destination address is not allowed"},
+		{"redirect", redirect.URL, true, http.StatusFound, "unexpected status 302"},
+	} {
+		st := memory.NewStorage()
+		s := service.NewService(st)
+
+		wh, err := s.CreateWebhook(ctx, models.Webhook{UserId: userId, URL: tc.url})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := s.CreateSub(ctx, models.Subscription{ServiceName: "Netflix", Price: 400, UserId: userId, StartDate: "2025-07-01"}); err != nil {
+			t.Fatal(err)
+		}
+
+		c := cfg
+		c.AllowPrivateNetworks = tc.allow
+		d := webhooks.NewDispatcher(st, c)
+		d.SetNow(func() time.Time { return time.Now().Add(time.Second) })
+		if err := d.Process(ctx); err != nil {
+			t.Fatal(err)
+		}
+
+		deliveries, err := st.ReadDeliveriesRequest(ctx, wh.Id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(deliveries) != 1 || deliveries[0].LastStatusCode != tc.code || deliveries[0].LastError != tc.lastError {
+			t.Fatalf("%s: unexpected deliveries %+v", tc.name, deliveries)
+		}
+	}
+
+	if n := hits.Load(); n != 0 {
+		t.Fatalf("target got %d requests", n)
+	}
+}